@@ -0,0 +1,132 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/property"
+)
+
+// Catalog formats, from oldest to newest.  Each format is reached by migrating a catalog of the previous format.
+const (
+	// FormatBundleObject is the original format, in which each bundle carries its manifests as olm.bundle.object
+	// properties
+	FormatBundleObject = "bundle-object"
+	// FormatCSVMetadata replaces the olm.bundle.object properties of each bundle with a single olm.csv.metadata
+	// property, holding only the metadata of its ClusterServiceVersion
+	FormatCSVMetadata = "csv-metadata"
+
+	// CurrentFormat is the newest catalog format
+	CurrentFormat = FormatCSVMetadata
+)
+
+type formatMigration struct {
+	format  string
+	migrate func(*declcfg.DeclarativeConfig) error
+}
+
+// formatMigrations lists each catalog format with the migration from the previous one, oldest first
+var formatMigrations = []formatMigration{
+	{format: FormatBundleObject},
+	{format: FormatCSVMetadata, migrate: bundleObjectToCSVMetadata},
+}
+
+// MigrateConfig rewrites the deprecated property shapes of cfg in place, applying each migration up to and including
+// the one to the target format.  Migrations leave objects which are already in a newer shape unchanged, so migrating
+// a catalog which is already current is a no-op.
+func MigrateConfig(cfg *declcfg.DeclarativeConfig, target string) error {
+	found := false
+	for _, m := range formatMigrations {
+		found = found || m.format == target
+	}
+	if !found {
+		formats := make([]string, 0, len(formatMigrations))
+		for _, m := range formatMigrations {
+			formats = append(formats, m.format)
+		}
+		return fmt.Errorf("unknown catalog format %q, expected one of %q", target, formats)
+	}
+
+	for _, m := range formatMigrations {
+		if m.migrate != nil {
+			if err := m.migrate(cfg); err != nil {
+				return fmt.Errorf("migrate to catalog format %q: %v", m.format, err)
+			}
+		}
+		if m.format == target {
+			break
+		}
+	}
+	return nil
+}
+
+// bundleObjectToCSVMetadata replaces the olm.bundle.object properties of each bundle with an olm.csv.metadata
+// property built from its CSV.  Bundles which already have an olm.csv.metadata property, or whose CSV is not available
+// (as when their bundle objects are file references), are left unchanged.
+func bundleObjectToCSVMetadata(cfg *declcfg.DeclarativeConfig) error {
+	for i := range cfg.Bundles {
+		b := &cfg.Bundles[i]
+		props, err := property.Parse(b.Properties)
+		if err != nil {
+			return fmt.Errorf("parse properties for bundle %q: %v", b.Name, err)
+		}
+		if len(props.CSVMetadatas) != 0 {
+			continue
+		}
+		csv, err := bundleCSV(b, props.BundleObjects)
+		if err != nil {
+			return err
+		}
+		if csv == nil {
+			continue
+		}
+
+		migrated := make([]property.Property, 0, len(b.Properties))
+		for _, p := range b.Properties {
+			if p.Type != property.TypeBundleObject {
+				migrated = append(migrated, p)
+			}
+		}
+		b.Properties = append(migrated, property.MustBuildCSVMetadata(*csv))
+	}
+	return nil
+}
+
+// bundleCSV returns the CSV of a bundle, from its rendered CSV if set, or else from its inline bundle objects
+func bundleCSV(b *declcfg.Bundle, objects []property.BundleObject) (*v1alpha1.ClusterServiceVersion, error) {
+	data := []byte(b.CsvJSON)
+	for _, obj := range objects {
+		if len(data) != 0 {
+			break
+		}
+		if obj.IsRef() {
+			continue
+		}
+		// inline data is returned without reading the filesystem
+		objData, err := obj.GetData(nil, "")
+		if err != nil {
+			return nil, fmt.Errorf("read object of bundle %q: %v", b.Name, err)
+		}
+		var meta struct {
+			Kind string `json:"kind"`
+		}
+		if err := json.Unmarshal(objData, &meta); err != nil {
+			return nil, fmt.Errorf("parse object of bundle %q: %v", b.Name, err)
+		}
+		if meta.Kind == v1alpha1.ClusterServiceVersionKind {
+			data = objData
+		}
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var csv v1alpha1.ClusterServiceVersion
+	if err := json.Unmarshal(data, &csv); err != nil {
+		return nil, fmt.Errorf("parse CSV of bundle %q: %v", b.Name, err)
+	}
+	return &csv, nil
+}
@@ -0,0 +1,103 @@
+package action_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/operator-framework/operator-registry/alpha/action"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/property"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	csv := v1alpha1.ClusterServiceVersion{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.ClusterServiceVersionAPIVersion, Kind: v1alpha1.ClusterServiceVersionKind},
+		ObjectMeta: metav1.ObjectMeta{Name: "foo.v0.1.0", Annotations: map[string]string{"capabilities": "Basic Install"}},
+		Spec:       v1alpha1.ClusterServiceVersionSpec{DisplayName: "Foo Operator", Keywords: []string{"foo"}},
+	}
+	csvJSON, err := json.Marshal(csv)
+	require.NoError(t, err)
+	crdJSON := []byte(`{"apiVersion":"apiextensions.k8s.io/v1","kind":"CustomResourceDefinition","metadata":{"name":"foos.test.foo"}}`)
+	other := property.Property{Type: "example.com/other", Value: json.RawMessage(`{"v":1}`)}
+
+	bundle := func(csvJSON string, props ...property.Property) declcfg.Bundle {
+		return declcfg.Bundle{
+			Schema:     "olm.bundle",
+			Name:       "foo.v0.1.0",
+			Package:    "foo",
+			Image:      "test.registry/foo-operator/foo-bundle:v0.1.0",
+			Properties: append([]property.Property{property.MustBuildPackage("foo", "0.1.0")}, props...),
+			CsvJSON:    csvJSON,
+		}
+	}
+	bundleObjects := bundle("", property.MustBuildBundleObjectData(crdJSON), property.MustBuildBundleObjectData(csvJSON), other)
+	migrated := bundle("", other, property.MustBuildCSVMetadata(csv))
+
+	type spec struct {
+		name      string
+		target    string
+		input     declcfg.Bundle
+		expected  declcfg.Bundle
+		expectErr string
+	}
+	specs := []spec{
+		{
+			name:     "BundleObject/NoOp",
+			target:   action.FormatBundleObject,
+			input:    bundleObjects,
+			expected: bundleObjects,
+		},
+		{
+			name:     "CSVMetadata/FromBundleObjects",
+			target:   action.FormatCSVMetadata,
+			input:    bundleObjects,
+			expected: migrated,
+		},
+		{
+			name:     "CSVMetadata/FromRenderedCSV",
+			target:   action.CurrentFormat,
+			input:    bundle(string(csvJSON), property.MustBuildBundleObjectData(csvJSON), other),
+			expected: func() declcfg.Bundle { b := migrated; b.CsvJSON = string(csvJSON); return b }(),
+		},
+		{
+			name:     "CSVMetadata/AlreadyCurrent",
+			target:   action.FormatCSVMetadata,
+			input:    migrated,
+			expected: migrated,
+		},
+		{
+			name:     "CSVMetadata/ObjectReferences",
+			target:   action.FormatCSVMetadata,
+			input:    bundle("", property.MustBuildBundleObjectRef("objects/csv.yaml")),
+			expected: bundle("", property.MustBuildBundleObjectRef("objects/csv.yaml")),
+		},
+		{
+			name:      "Error/UnknownFormat",
+			target:    "v2",
+			input:     bundleObjects,
+			expectErr: `unknown catalog format "v2", expected one of ["bundle-object" "csv-metadata"]`,
+		},
+	}
+	for _, s := range specs {
+		t.Run(s.name, func(t *testing.T) {
+			input := s.input
+			input.Properties = append([]property.Property{}, s.input.Properties...)
+			cfg := &declcfg.DeclarativeConfig{Bundles: []declcfg.Bundle{input}}
+			err := action.MigrateConfig(cfg, s.target)
+			if s.expectErr != "" {
+				require.EqualError(t, err, s.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, []declcfg.Bundle{s.expected}, cfg.Bundles)
+
+			// migrations are idempotent
+			require.NoError(t, action.MigrateConfig(cfg, s.target))
+			require.Equal(t, []declcfg.Bundle{s.expected}, cfg.Bundles)
+		})
+	}
+}
@@ -38,6 +38,7 @@ const (
 	RefSqliteFile
 	RefDCImage
 	RefDCDir
+	RefBundleDir
 
 	RefAll = 0
 )
@@ -97,6 +98,12 @@ func (r Render) Run(ctx context.Context) (*declcfg.DeclarativeConfig, error) {
 }
 
 func (r Render) createRegistry() (*containerdregistry.Registry, error) {
+	return CreateRegistry()
+}
+
+// CreateRegistry creates the registry used by Render when none is set, caching images in a new temporary directory
+// which is removed when the registry is destroyed
+func CreateRegistry() (*containerdregistry.Registry, error) {
 	cacheDir, err := os.MkdirTemp("", "render-registry-")
 	if err != nil {
 		return nil, fmt.Errorf("create tempdir: %v", err)
@@ -119,6 +126,17 @@ func (r Render) createRegistry() (*containerdregistry.Registry, error) {
 func (r Render) renderReference(ctx context.Context, ref string) (*declcfg.DeclarativeConfig, error) {
 	if stat, serr := os.Stat(ref); serr == nil {
 		if stat.IsDir() {
+			// bundle directories are only rendered when explicitly requested, so that the directories rendered by
+			// default are always loaded as declarative configs
+			if r.AllowedRefMask != RefAll && r.AllowedRefMask.Allowed(RefBundleDir) {
+				isBundleDir, err := isBundleDirectory(ref)
+				if err != nil {
+					return nil, err
+				}
+				if isBundleDir {
+					return renderBundleDirectory(ref)
+				}
+			}
 			if !r.AllowedRefMask.Allowed(RefDCDir) {
 				return nil, fmt.Errorf("cannot render declarative config directory: %w", ErrNotAllowed)
 			}
@@ -138,6 +156,30 @@ func (r Render) renderReference(ctx context.Context, ref string) (*declcfg.Decla
 	return r.imageToDeclcfg(ctx, ref)
 }
 
+// isBundleDirectory reports whether dir is an unpacked bundle, with manifests and metadata subdirectories
+func isBundleDirectory(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+	subdirs := sets.NewString()
+	for _, e := range entries {
+		if e.IsDir() {
+			subdirs.Insert(e.Name())
+		}
+	}
+	return subdirs.HasAll("manifests", "metadata"), nil
+}
+
+// renderBundleDirectory renders an unpacked bundle directory, which has no bundle image
+func renderBundleDirectory(dir string) (*declcfg.DeclarativeConfig, error) {
+	img, err := registry.NewImageInput(image.SimpleReference(""), dir)
+	if err != nil {
+		return nil, err
+	}
+	return bundleToDeclcfg(img.Bundle)
+}
+
 func (r Render) imageToDeclcfg(ctx context.Context, imageRef string) (*declcfg.DeclarativeConfig, error) {
 	ref := image.SimpleReference(imageRef)
 	if err := r.Registry.Pull(ctx, ref); err != nil {
@@ -354,7 +396,7 @@ func getRelatedImages(b *registry.Bundle) ([]declcfg.RelatedImage, error) {
 		allImages = allImages.Insert(ri.Image)
 	}
 
-	if !allImages.Has(b.BundleImage) {
+	if b.BundleImage != "" && !allImages.Has(b.BundleImage) {
 		relatedImages = append(relatedImages, declcfg.RelatedImage{
 			Image: b.BundleImage,
 		})
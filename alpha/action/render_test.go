@@ -665,6 +665,24 @@ func TestAllowRefMask(t *testing.T) {
 			},
 			expectErr: action.ErrNotAllowed,
 		},
+		{
+			name: "BundleDir/Allowed",
+			render: action.Render{
+				Refs:           []string{"testdata/foo-bundle-v0.2.0"},
+				Registry:       reg,
+				AllowedRefMask: action.RefBundleDir,
+			},
+			expectErr: nil,
+		},
+		{
+			name: "BundleDir/NotAllowed",
+			render: action.Render{
+				Refs:           []string{"testdata/foo-bundle-v0.2.0"},
+				Registry:       reg,
+				AllowedRefMask: action.RefDCImage | action.RefSqliteImage | action.RefSqliteFile | action.RefBundleImage,
+			},
+			expectErr: action.ErrNotAllowed,
+		},
 		{
 			name: "All/Allowed",
 			render: action.Render{
@@ -688,6 +706,20 @@ func TestAllowRefMask(t *testing.T) {
 	}
 }
 
+func TestRenderBundleDirOnlyWhenRequested(t *testing.T) {
+	// a declarative config directory which happens to have manifests and metadata subdirectories
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "manifests"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "metadata"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.json"), []byte(`{"schema": "olm.package", "name": "foo"}`), 0644))
+
+	for _, mask := range []action.RefType{action.RefAll, action.RefDCDir} {
+		cfg, err := action.Render{Refs: []string{dir}, AllowedRefMask: mask}.Run(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []declcfg.Package{{Schema: declcfg.SchemaPackage, Name: "foo"}}, cfg.Packages)
+	}
+}
+
 func TestAllowRefMaskAllowed(t *testing.T) {
 	type spec struct {
 		name   string
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -265,6 +266,13 @@ func TestParse(t *testing.T) {
 			},
 			assertion: assert.Error,
 		},
+		{
+			name: "Error/InvalidCSVMetadata",
+			input: []Property{
+				{Type: TypeCSVMetadata, Value: json.RawMessage(`{`)},
+			},
+			assertion: assert.Error,
+		},
 		{
 			name: "Error/InvalidOther",
 			input: []Property{
@@ -285,6 +293,7 @@ func TestParse(t *testing.T) {
 				MustBuildGVKRequired("other", "v2", "Kind4"),
 				MustBuildBundleObjectRef("testref1"),
 				MustBuildBundleObjectData([]byte("testdata2")),
+				MustBuildCSVMetadata(v1alpha1.ClusterServiceVersion{Spec: v1alpha1.ClusterServiceVersionSpec{DisplayName: "Package 1"}}),
 				{Type: "otherType1", Value: json.RawMessage(`{"v":"otherValue1"}`)},
 				{Type: "otherType2", Value: json.RawMessage(`["otherValue2"]`)},
 			},
@@ -309,6 +318,9 @@ func TestParse(t *testing.T) {
 					{File: File{ref: "testref1"}},
 					{File: File{data: []byte("testdata2")}},
 				},
+				CSVMetadatas: []CSVMetadata{
+					{DisplayName: "Package 1"},
+				},
 				Others: []Property{
 					{Type: "otherType1", Value: json.RawMessage(`{"v":"otherValue1"}`)},
 					{Type: "otherType2", Value: json.RawMessage(`["otherValue2"]`)},
@@ -393,6 +405,12 @@ func TestBuild(t *testing.T) {
 			assertion:        require.NoError,
 			expectedProperty: propPtr(MustBuildBundleObjectRef("test")),
 		},
+		{
+			name:             "Success/CSVMetadata",
+			input:            &CSVMetadata{DisplayName: "Package 1", Keywords: []string{"test"}},
+			assertion:        require.NoError,
+			expectedProperty: &Property{Type: TypeCSVMetadata, Value: json.RawMessage(`{"apiServiceDefinitions":{},"crdDescriptions":{},"displayName":"Package 1","keywords":["test"],"provider":{}}`)},
+		},
 		{
 			name:             "Success/Property",
 			input:            &Property{Type: "foo", Value: json.RawMessage(`"bar"`)},
@@ -0,0 +1,162 @@
+package semver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/distribution/reference"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/pkg/image"
+)
+
+// BundleCache stores rendered bundle images by the digest of their manifest, so that repeated renders of the same
+// images (as across the templates of a test suite or pipeline) need not unpack them again.  Since an image's digest
+// identifies its content, a cached render remains correct when a tag is moved to another image.
+type BundleCache interface {
+	// Get returns the rendered image of the digest, and whether the cache holds it
+	Get(ctx context.Context, dgst digest.Digest) (*declcfg.DeclarativeConfig, bool, error)
+	// Put stores the rendered image of the digest
+	Put(ctx context.Context, dgst digest.Digest, cfg *declcfg.DeclarativeConfig) error
+}
+
+// bundleCacheKey returns the digest under which the rendered image is cached, and whether it has one.  Images referenced
+// by digest are keyed by it directly; those referenced by tag are keyed by the digest the template's Registry resolves
+// from the remote registry, if it implements image.RemoteDigestResolver, so that cached images are never pulled.
+func (t Template) bundleCacheKey(ctx context.Context, img string) (digest.Digest, bool, error) {
+	ref, err := reference.ParseNormalizedNamed(img)
+	if err != nil {
+		return "", false, err
+	}
+	if digested, ok := ref.(reference.Digested); ok {
+		return digested.Digest(), true, nil
+	}
+	resolver, ok := t.Registry.(image.RemoteDigestResolver)
+	if !ok {
+		return "", false, nil
+	}
+	dgst, err := resolver.ResolveDigest(ctx, image.SimpleReference(img))
+	if err != nil {
+		return "", false, err
+	}
+	return dgst, true, nil
+}
+
+// cachedRender returns the rendered bundle image from the template's BundleCache, or renders and caches it.  Images
+// which cannot be keyed by digest are rendered as usual.
+func (t Template) cachedRender(ctx context.Context, img string, render func() (*declcfg.DeclarativeConfig, error)) (*declcfg.DeclarativeConfig, error) {
+	dgst, ok, err := t.bundleCacheKey(ctx, img)
+	if err != nil {
+		return nil, fmt.Errorf("render: resolve digest of bundle image %q: %v", img, err)
+	}
+	if !ok {
+		return render()
+	}
+	cfg, ok, err := t.BundleCache.Get(ctx, dgst)
+	if err != nil {
+		return nil, fmt.Errorf("render: bundle cache: %v", err)
+	}
+	if ok {
+		cfg = cloneConfig(cfg)
+		// the image may have been cached under another reference to the same digest
+		for i := range cfg.Bundles {
+			cfg.Bundles[i].Image = img
+		}
+		return cfg, nil
+	}
+	if cfg, err = render(); err != nil {
+		return nil, err
+	}
+	if err := t.BundleCache.Put(ctx, dgst, cfg); err != nil {
+		return nil, fmt.Errorf("render: bundle cache: %v", err)
+	}
+	return cfg, nil
+}
+
+// FSBundleCache is a BundleCache which stores each rendered image as a JSON file in a directory, named for its digest
+type FSBundleCache struct {
+	Dir string
+}
+
+// fsCacheEntry is the content of a cache file.  Bundles are stored with their CSV and objects, which are not part of
+// their file-based catalog form.
+type fsCacheEntry struct {
+	Bundles []fsCacheBundle `json:"bundles"`
+}
+
+type fsCacheBundle struct {
+	declcfg.Bundle
+	CsvJSON string   `json:"csvJson,omitempty"`
+	Objects []string `json:"objects,omitempty"`
+}
+
+func (c FSBundleCache) path(dgst digest.Digest) (string, error) {
+	if err := dgst.Validate(); err != nil {
+		return "", err
+	}
+	return filepath.Join(c.Dir, dgst.Algorithm().String(), dgst.Encoded()+".json"), nil
+}
+
+func (c FSBundleCache) Get(_ context.Context, dgst digest.Digest) (*declcfg.DeclarativeConfig, bool, error) {
+	path, err := c.path(dgst)
+	if err != nil {
+		return nil, false, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var entry fsCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false, fmt.Errorf("read %s: %v", path, err)
+	}
+	cfg := &declcfg.DeclarativeConfig{}
+	for _, b := range entry.Bundles {
+		b.Bundle.CsvJSON = b.CsvJSON
+		b.Bundle.Objects = b.Objects
+		cfg.Bundles = append(cfg.Bundles, b.Bundle)
+	}
+	return cfg, true, nil
+}
+
+// Put stores the bundles of the rendered image, which is all a rendered bundle image holds.  The file is written in
+// place atomically, so that concurrent renders sharing the directory never read a partial file.
+func (c FSBundleCache) Put(_ context.Context, dgst digest.Digest, cfg *declcfg.DeclarativeConfig) error {
+	path, err := c.path(dgst)
+	if err != nil {
+		return err
+	}
+	entry := fsCacheEntry{Bundles: []fsCacheBundle{}}
+	for _, b := range cfg.Bundles {
+		entry.Bundles = append(entry.Bundles, fsCacheBundle{Bundle: b, CsvJSON: b.CsvJSON, Objects: b.Objects})
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,58 @@
+package semver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/pkg/image"
+)
+
+func TestBundleCache(t *testing.T) {
+	bundles := testBundles("a", "0.1.0", "0.1.1")
+	data := fmt.Sprintf("schema: olm.semver\nstable:\n  bundles:\n  - image: %s\n  - image: %s\n", bundles[0].image, bundles[1].image)
+	cache := FSBundleCache{Dir: t.TempDir()}
+	render := func(reg *image.MockRegistry) (*declcfg.DeclarativeConfig, error) {
+		return Template{Data: strings.NewReader(data), Registry: reg, BundleCache: cache}.Render(context.Background())
+	}
+
+	reg := newTestRegistry(bundles...)
+	for _, b := range bundles {
+		reg.RemoteImages[image.SimpleReference(b.image)].Digest = digest.FromString(b.image)
+	}
+	expected, err := render(reg)
+	require.NoError(t, err)
+
+	cached, ok, err := cache.Get(context.Background(), digest.FromString(bundles[0].image))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NotEmpty(t, cached.Bundles[0].CsvJSON, "the CSV should be cached with the bundle")
+
+	// images of cached digests are not unpacked again
+	for _, b := range bundles {
+		reg.RemoteImages[image.SimpleReference(b.image)].FS = nil
+	}
+	reg = &image.MockRegistry{RemoteImages: reg.RemoteImages}
+	out, err := render(reg)
+	require.NoError(t, err)
+	require.Equal(t, expected, out)
+	// nor pulled, since their digests are resolved from the remote registry
+	for _, b := range bundles {
+		_, err := reg.Digest(context.Background(), image.SimpleReference(b.image))
+		require.EqualError(t, err, "not found")
+	}
+
+	// a tag moved to another image is rendered anew
+	moved := testBundles("a", "0.1.2")[0]
+	reg.RemoteImages[image.SimpleReference(bundles[1].image)] = newTestRegistry(moved).RemoteImages[image.SimpleReference(moved.image)]
+	reg.RemoteImages[image.SimpleReference(bundles[1].image)].Digest = digest.FromString(moved.image)
+	out, err = render(reg)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a.v0.1.0", "a.v0.1.2"}, []string{out.Bundles[0].Name, out.Bundles[1].Name})
+	require.Equal(t, bundles[1].image, out.Bundles[1].Image)
+}
@@ -0,0 +1,108 @@
+package semver
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/blang/semver/v4"
+	"k8s.io/apimachinery/pkg/util/errors"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+// deprecationsSchema is the schema of the object listing a package's deprecated bundles, which OLM surfaces to users
+const deprecationsSchema = "olm.deprecations"
+
+type deprecations struct {
+	Schema  string             `json:"schema"`
+	Package string             `json:"package"`
+	Entries []deprecationEntry `json:"entries"`
+}
+
+type deprecationEntry struct {
+	Reference deprecationReference `json:"reference"`
+	Message   string               `json:"message"`
+}
+
+type deprecationReference struct {
+	Schema string `json:"schema"`
+	Name   string `json:"name"`
+}
+
+// deprecations resolves the template's deprecations against the output bundles, returning the package's
+// olm.deprecations object.  Every deprecation must match at least one output bundle, and no bundle may be deprecated
+// more than once.
+func (sv *semverTemplate) deprecations(out *declcfg.DeclarativeConfig, versions *bundleVersions) (*declcfg.Meta, error) {
+	bundleVersion := make(map[string]semver.Version, len(out.Bundles))
+	for _, bundles := range *versions {
+		for name, v := range bundles {
+			bundleVersion[name] = v
+		}
+	}
+	outputNames := make(map[string]semver.Version, len(out.Bundles))
+	for _, b := range out.Bundles {
+		if v, ok := bundleVersion[b.Name]; ok {
+			outputNames[b.Name] = v
+		}
+	}
+
+	messages := make(map[string]string)
+	errs := []error{}
+	deprecate := func(name string, d semverTemplateDeprecation) {
+		if _, ok := messages[name]; ok {
+			errs = append(errs, fmt.Errorf("bundle %q is deprecated more than once", name))
+			return
+		}
+		messages[name] = d.Message
+	}
+	for _, d := range sv.Deprecations {
+		switch {
+		case d.Bundle != "" && d.Versions != "":
+			errs = append(errs, fmt.Errorf("deprecation of bundle %q and versions %q must specify exactly one of them", d.Bundle, d.Versions))
+		case d.Bundle == "" && d.Versions == "":
+			errs = append(errs, fmt.Errorf("deprecation specifies neither a bundle nor versions"))
+		case d.Message == "":
+			errs = append(errs, fmt.Errorf("deprecation of %q has no message", d.Bundle+d.Versions))
+		case d.Bundle != "":
+			if _, ok := outputNames[d.Bundle]; !ok {
+				errs = append(errs, fmt.Errorf("deprecated bundle %q is not in the output", d.Bundle))
+				continue
+			}
+			deprecate(d.Bundle, d)
+		default:
+			r, err := semver.ParseRange(d.Versions)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid deprecated versions %q: %v", d.Versions, err))
+				continue
+			}
+			matched := false
+			for _, name := range sortedBundleNames(outputNames) {
+				if r(outputNames[name]) {
+					matched = true
+					deprecate(name, d)
+				}
+			}
+			if !matched {
+				errs = append(errs, fmt.Errorf("deprecated versions %q match no bundle in the output", d.Versions))
+			}
+		}
+	}
+	if len(errs) != 0 {
+		return nil, fmt.Errorf("invalid deprecations: %v", errors.NewAggregate(errs))
+	}
+
+	deps := deprecations{Schema: deprecationsSchema, Package: out.Packages[0].Name, Entries: []deprecationEntry{}}
+	for _, name := range sortedBundleNames(outputNames) {
+		if msg, ok := messages[name]; ok {
+			deps.Entries = append(deps.Entries, deprecationEntry{
+				Reference: deprecationReference{Schema: declcfg.SchemaBundle, Name: name},
+				Message:   msg,
+			})
+		}
+	}
+	blob, err := json.Marshal(deps)
+	if err != nil {
+		return nil, err
+	}
+	return &declcfg.Meta{Schema: deprecationsSchema, Package: out.Packages[0].Name, Blob: blob}, nil
+}
@@ -0,0 +1,81 @@
+package semver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+func TestDeprecations(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.0.1", "1.1.0", "1.2.0")
+	render := func(deprecations string) (*declcfg.DeclarativeConfig, error) {
+		data := "schema: olm.semver\ngenerateMajorChannels: true\ngenerateMinorChannels: false\ndeprecations:\n" + deprecations + "stable:\n  bundles:\n"
+		for _, b := range bundles {
+			data += fmt.Sprintf("  - image: %s\n", b.image)
+		}
+		return Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+	}
+
+	out, err := render(`- versions: "<1.1.0"
+  message: 1.0 is no longer supported
+- bundle: a.v1.1.0
+  message: 1.1.0 has a known data loss bug
+`)
+	require.NoError(t, err)
+	require.Len(t, out.Others, 1)
+	require.Equal(t, "olm.deprecations", out.Others[0].Schema)
+	require.JSONEq(t, `{
+		"schema": "olm.deprecations",
+		"package": "a",
+		"entries": [
+			{"reference": {"schema": "olm.bundle", "name": "a.v1.0.0"}, "message": "1.0 is no longer supported"},
+			{"reference": {"schema": "olm.bundle", "name": "a.v1.0.1"}, "message": "1.0 is no longer supported"},
+			{"reference": {"schema": "olm.bundle", "name": "a.v1.1.0"}, "message": "1.1.0 has a known data loss bug"}
+		]
+	}`, string(out.Others[0].Blob))
+	// deprecated bundles remain in the upgrade graph
+	require.Len(t, out.Bundles, 4)
+	require.Equal(t, "a.v1.1.0", out.Channels[0].Entries[3].Replaces)
+
+	for _, tt := range []struct {
+		name         string
+		deprecations string
+		err          string
+	}{
+		{
+			name:         "unknown bundle",
+			deprecations: "- bundle: a.v2.0.0\n  message: gone\n",
+			err:          `render: invalid deprecations: deprecated bundle "a.v2.0.0" is not in the output`,
+		},
+		{
+			name:         "unmatched versions",
+			deprecations: "- versions: \">=2.0.0\"\n  message: gone\n",
+			err:          `render: invalid deprecations: deprecated versions ">=2.0.0" match no bundle in the output`,
+		},
+		{
+			name:         "bundle and versions",
+			deprecations: "- bundle: a.v1.0.0\n  versions: \"<1.1.0\"\n  message: gone\n",
+			err:          `render: invalid deprecations: deprecation of bundle "a.v1.0.0" and versions "<1.1.0" must specify exactly one of them`,
+		},
+		{
+			name:         "no message",
+			deprecations: "- bundle: a.v1.0.0\n",
+			err:          `render: invalid deprecations: deprecation of "a.v1.0.0" has no message`,
+		},
+		{
+			name:         "deprecated twice",
+			deprecations: "- versions: \"<1.1.0\"\n  message: old\n- bundle: a.v1.0.1\n  message: buggy\n",
+			err:          `render: invalid deprecations: bundle "a.v1.0.1" is deprecated more than once`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := render(tt.deprecations)
+			require.EqualError(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package semver
+
+import (
+	"context"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+// Default channel selection strategies, as reported by DefaultChannelDiagnostics
+const (
+	// DefaultChannelStrategyHighWaterMark selects the channel of the most stable archetype or the highest version
+	DefaultChannelStrategyHighWaterMark = "highWaterMark"
+	// DefaultChannelStrategyCascading selects the highest channel of the most stable archetype, see
+	// Template.CascadingArchetypeDefault
+	DefaultChannelStrategyCascading = "cascadingArchetype"
+	// DefaultChannelStrategyLowestTail selects the most stable channel with the lowest tail, see DefaultChannelEntryTail
+	DefaultChannelStrategyLowestTail = "lowestTail"
+	// DefaultChannelStrategyOverride selects the channel named by the template's defaultChannel
+	DefaultChannelStrategyOverride = "override"
+	// DefaultChannelStrategyDeclared selects the last (most stable) of the template's declared channels
+	DefaultChannelStrategyDeclared = "declaredChannels"
+)
+
+// Diagnostics explains the decisions made while rendering a template, for debugging surprising output
+type Diagnostics struct {
+	DefaultChannel DefaultChannelDiagnostics `json:"defaultChannel"`
+}
+
+// DefaultChannelDiagnostics explains the selection of the package's default channel
+type DefaultChannelDiagnostics struct {
+	// Strategy is the DefaultChannelStrategy* by which the channel was selected
+	Strategy string `json:"strategy"`
+	// Selected is the name of the default channel, if any was selected
+	Selected string `json:"selected,omitempty"`
+	// Archetype and Version are those of the selected channel as they were compared: the version is the lowest of the
+	// channel's bundles, by which channels of the same archetype are ranked
+	Archetype string `json:"archetype,omitempty"`
+	Version   string `json:"version,omitempty"`
+	// Candidates lists the generated channels, in the order in which they were compared
+	Candidates []DefaultChannelCandidate `json:"candidates,omitempty"`
+}
+
+// DefaultChannelCandidate describes a generated channel compared during default channel selection
+type DefaultChannelCandidate struct {
+	Channel   string `json:"channel"`
+	Archetype string `json:"archetype"`
+	Priority  int    `json:"priority"`
+	Kind      string `json:"kind"`
+	Version   string `json:"version"`
+	Head      string `json:"head"`
+	// Ignored, when set, is the reason the channel was not eligible
+	Ignored string `json:"ignored,omitempty"`
+}
+
+// RenderWithDiagnostics renders the template like Render, and additionally returns diagnostics explaining how the
+// output was generated.  For a template of several packages, they describe the last package.
+func (t Template) RenderWithDiagnostics(ctx context.Context) (*declcfg.DeclarativeConfig, *Diagnostics, error) {
+	sv, out, err := t.renderBundles(ctx, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	sv.diagnostics = &Diagnostics{}
+	out, _, err = t.generate(ctx, sv, out, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, sv.diagnostics, nil
+}
+
+// defaultChannelStrategy returns the strategy by which the default channel of generated channels is selected
+func (sv *semverTemplate) defaultChannelStrategy() string {
+	switch {
+	case sv.DefaultChannelOverride != "":
+		return DefaultChannelStrategyOverride
+	case sv.defaultEntryTail:
+		return DefaultChannelStrategyLowestTail
+	case sv.cascadingDefault:
+		return DefaultChannelStrategyCascading
+	}
+	return DefaultChannelStrategyHighWaterMark
+}
@@ -0,0 +1,45 @@
+package semver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderWithDiagnostics(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.1.0", "2.0.0-rc.1")
+	data := fmt.Sprintf("schema: olm.semver\ncandidate:\n  bundles:\n  - image: %s\nstable:\n  bundles:\n  - image: %s\n  - image: %s\n", bundles[2].image, bundles[0].image, bundles[1].image)
+
+	out, diag, err := Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...), ExcludeChannels: []string{"stable-v1.0"}}.RenderWithDiagnostics(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, out.Packages[0].DefaultChannel, diag.DefaultChannel.Selected)
+	require.Equal(t, DefaultChannelDiagnostics{
+		Strategy:  DefaultChannelStrategyHighWaterMark,
+		Selected:  "stable-v1.1",
+		Archetype: "stable",
+		Version:   "1.1.0",
+		Candidates: []DefaultChannelCandidate{
+			{Channel: "candidate-v2.0", Archetype: "candidate", Priority: 0, Kind: "minor", Version: "2.0.0-rc.1", Head: "2.0.0-rc.1"},
+			{Channel: "stable-v1.0", Archetype: "stable", Priority: 2, Kind: "minor", Version: "1.0.0", Head: "1.0.0", Ignored: "excluded from the output"},
+			{Channel: "stable-v1.1", Archetype: "stable", Priority: 2, Kind: "minor", Version: "1.1.0", Head: "1.1.0"},
+		},
+	}, diag.DefaultChannel)
+
+	// diagnostics are serializable for review
+	encoded, err := json.Marshal(diag)
+	require.NoError(t, err)
+	var decoded Diagnostics
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	require.Equal(t, *diag, decoded)
+
+	// an override is reported as such, with the candidates compared until it was found
+	_, diag, err = Template{Data: strings.NewReader("defaultChannel: candidate-v2.0\n" + data), Registry: newTestRegistry(bundles...)}.RenderWithDiagnostics(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, DefaultChannelStrategyOverride, diag.DefaultChannel.Strategy)
+	require.Equal(t, "candidate-v2.0", diag.DefaultChannel.Selected)
+	require.Len(t, diag.DefaultChannel.Candidates, 1)
+}
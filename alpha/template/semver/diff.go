@@ -0,0 +1,143 @@
+package semver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+// CatalogDiff lists the packages, channels, and bundles which differ between two catalogs.  Channels and bundles are
+// identified as "<package>/<name>".
+type CatalogDiff struct {
+	Packages ObjectDiff `json:"packages"`
+	Channels ObjectDiff `json:"channels"`
+	Bundles  ObjectDiff `json:"bundles"`
+}
+
+// ObjectDiff lists the names of the objects of one schema which were added, removed, or changed, in sorted order
+type ObjectDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+}
+
+// DiffConfigs compares every package, channel, and bundle of two catalogs, as when an updated template is rendered to
+// replace a published catalog.  Objects of the same name are changed if any of their attributes differ; the order of
+// channel entries and of their skips is ignored.
+func DiffConfigs(from, to *declcfg.DeclarativeConfig) (*CatalogDiff, error) {
+	diff := &CatalogDiff{}
+	var err error
+	if diff.Packages, err = diffObjects(packagesByName(from), packagesByName(to)); err != nil {
+		return nil, fmt.Errorf("compare packages: %v", err)
+	}
+	if diff.Channels, err = diffObjects(channelsByName(from), channelsByName(to)); err != nil {
+		return nil, fmt.Errorf("compare channels: %v", err)
+	}
+	if diff.Bundles, err = diffObjects(bundlesByName(from), bundlesByName(to)); err != nil {
+		return nil, fmt.Errorf("compare bundles: %v", err)
+	}
+	return diff, nil
+}
+
+// Empty reports whether the catalogs compared are the same
+func (d CatalogDiff) Empty() bool {
+	return d.Packages.empty() && d.Channels.empty() && d.Bundles.empty()
+}
+
+// WriteText writes the differences for people to read, one object per line, each prefixed with "+" if it was added,
+// "-" if it was removed, or "~" if it was changed
+func (d CatalogDiff) WriteText(w io.Writer) error {
+	if d.Empty() {
+		_, err := fmt.Fprintln(w, "no changes")
+		return err
+	}
+	for _, kind := range []struct {
+		name string
+		diff ObjectDiff
+	}{{"package", d.Packages}, {"channel", d.Channels}, {"bundle", d.Bundles}} {
+		for _, change := range []struct {
+			prefix string
+			names  []string
+		}{{"+", kind.diff.Added}, {"-", kind.diff.Removed}, {"~", kind.diff.Changed}} {
+			for _, name := range change.names {
+				if _, err := fmt.Fprintf(w, "%s %s %s\n", change.prefix, kind.name, name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (d ObjectDiff) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// diffObjects compares the objects of one schema by name, as serialized
+func diffObjects(from, to map[string]interface{}) (ObjectDiff, error) {
+	diff := ObjectDiff{}
+	for name, obj := range to {
+		prev, ok := from[name]
+		if !ok {
+			diff.Added = append(diff.Added, name)
+			continue
+		}
+		// marshaling normalizes the formatting of property values
+		prevData, err := json.Marshal(prev)
+		if err != nil {
+			return diff, err
+		}
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return diff, err
+		}
+		if string(prevData) != string(data) {
+			diff.Changed = append(diff.Changed, name)
+		}
+	}
+	for name := range from {
+		if _, ok := to[name]; !ok {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff, nil
+}
+
+func packagesByName(cfg *declcfg.DeclarativeConfig) map[string]interface{} {
+	objs := make(map[string]interface{}, len(cfg.Packages))
+	for _, p := range cfg.Packages {
+		objs[p.Name] = p
+	}
+	return objs
+}
+
+func channelsByName(cfg *declcfg.DeclarativeConfig) map[string]interface{} {
+	objs := make(map[string]interface{}, len(cfg.Channels))
+	for _, c := range cfg.Channels {
+		// the order of a channel's entries, and of their skips, has no meaning
+		entries := make([]declcfg.ChannelEntry, 0, len(c.Entries))
+		for _, e := range c.Entries {
+			e.Skips = append([]string(nil), e.Skips...)
+			sort.Strings(e.Skips)
+			entries = append(entries, e)
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		c.Entries = entries
+		objs[c.Package+"/"+c.Name] = c
+	}
+	return objs
+}
+
+func bundlesByName(cfg *declcfg.DeclarativeConfig) map[string]interface{} {
+	objs := make(map[string]interface{}, len(cfg.Bundles))
+	for _, b := range cfg.Bundles {
+		objs[b.Package+"/"+b.Name] = b
+	}
+	return objs
+}
@@ -0,0 +1,113 @@
+package semver
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/property"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	bundle := func(name, version string, value string) declcfg.Bundle {
+		return declcfg.Bundle{
+			Schema:  "olm.bundle",
+			Name:    name,
+			Package: "a",
+			Properties: []property.Property{
+				property.MustBuildPackage("a", version),
+				{Type: "example.com/support", Value: json.RawMessage(value)},
+			},
+		}
+	}
+	from := &declcfg.DeclarativeConfig{
+		Packages: []declcfg.Package{{Schema: "olm.package", Name: "a", DefaultChannel: "stable-v0"}},
+		Channels: []declcfg.Channel{
+			{Schema: "olm.channel", Name: "stable-v0", Package: "a", Entries: []declcfg.ChannelEntry{{Name: "a.v0.1.0"}}},
+		},
+		Bundles: []declcfg.Bundle{
+			bundle("a.v0.1.0", "0.1.0", `{"tier": "basic"}`),
+			bundle("a.v0.2.0", "0.2.0", `{"tier": "basic"}`),
+		},
+	}
+
+	// only the formatting of the property values differs
+	to := &declcfg.DeclarativeConfig{
+		Packages: from.Packages,
+		Channels: from.Channels,
+		Bundles: []declcfg.Bundle{
+			bundle("a.v0.1.0", "0.1.0", `{"tier":"basic"}`),
+			bundle("a.v0.2.0", "0.2.0", "{\n  \"tier\": \"basic\"\n}"),
+		},
+	}
+	diff, err := DiffConfigs(from, to)
+	require.NoError(t, err)
+	require.True(t, diff.Empty())
+	var buf bytes.Buffer
+	require.NoError(t, diff.WriteText(&buf))
+	require.Equal(t, "no changes\n", buf.String())
+
+	to = &declcfg.DeclarativeConfig{
+		Packages: []declcfg.Package{{Schema: "olm.package", Name: "a", DefaultChannel: "stable-v1"}},
+		Channels: []declcfg.Channel{
+			{Schema: "olm.channel", Name: "stable-v0", Package: "a", Entries: []declcfg.ChannelEntry{{Name: "a.v0.1.0"}}},
+			{Schema: "olm.channel", Name: "stable-v1", Package: "a", Entries: []declcfg.ChannelEntry{{Name: "a.v1.0.0"}}},
+		},
+		Bundles: []declcfg.Bundle{
+			bundle("a.v0.1.0", "0.1.0", `{"tier":"extended"}`),
+			bundle("a.v1.0.0", "1.0.0", `{"tier":"basic"}`),
+		},
+	}
+	diff, err = DiffConfigs(from, to)
+	require.NoError(t, err)
+	require.Equal(t, &CatalogDiff{
+		Packages: ObjectDiff{Changed: []string{"a"}},
+		Channels: ObjectDiff{Added: []string{"a/stable-v1"}},
+		Bundles:  ObjectDiff{Added: []string{"a/a.v1.0.0"}, Removed: []string{"a/a.v0.2.0"}, Changed: []string{"a/a.v0.1.0"}},
+	}, diff)
+
+	buf.Reset()
+	require.NoError(t, diff.WriteText(&buf))
+	require.Equal(t, `~ package a
++ channel a/stable-v1
++ bundle a/a.v1.0.0
+- bundle a/a.v0.2.0
+~ bundle a/a.v0.1.0
+`, buf.String())
+}
+
+func TestDiffConfigsReorderedEntries(t *testing.T) {
+	channel := func(entries ...declcfg.ChannelEntry) *declcfg.DeclarativeConfig {
+		return &declcfg.DeclarativeConfig{
+			Channels: []declcfg.Channel{{Schema: "olm.channel", Name: "stable-v1", Package: "a", Entries: entries}},
+		}
+	}
+	from := channel(
+		declcfg.ChannelEntry{Name: "a.v1.0.0"},
+		declcfg.ChannelEntry{Name: "a.v1.0.1", Skips: []string{"a.v1.0.0"}},
+		declcfg.ChannelEntry{Name: "a.v1.1.0", Replaces: "a.v1.0.1", Skips: []string{"a.v1.0.0", "a.v1.0.1"}},
+	)
+
+	// only the order of the entries and of their skips differs
+	diff, err := DiffConfigs(from, channel(
+		declcfg.ChannelEntry{Name: "a.v1.1.0", Replaces: "a.v1.0.1", Skips: []string{"a.v1.0.1", "a.v1.0.0"}},
+		declcfg.ChannelEntry{Name: "a.v1.0.0"},
+		declcfg.ChannelEntry{Name: "a.v1.0.1", Skips: []string{"a.v1.0.0"}},
+	))
+	require.NoError(t, err)
+	require.True(t, diff.Empty())
+	// the compared channels are left in their own order
+	require.Equal(t, "a.v1.0.0", from.Channels[0].Entries[0].Name)
+	require.Equal(t, []string{"a.v1.0.0", "a.v1.0.1"}, from.Channels[0].Entries[2].Skips)
+
+	diff, err = DiffConfigs(from, channel(
+		declcfg.ChannelEntry{Name: "a.v1.1.0", Replaces: "a.v1.0.0", Skips: []string{"a.v1.0.1", "a.v1.0.0"}},
+		declcfg.ChannelEntry{Name: "a.v1.0.0"},
+		declcfg.ChannelEntry{Name: "a.v1.0.1", Skips: []string{"a.v1.0.0"}},
+	))
+	require.NoError(t, err)
+	require.Equal(t, ObjectDiff{Changed: []string{"a/stable-v1"}}, diff.Channels)
+}
@@ -0,0 +1,181 @@
+package semver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrorCode is a stable, machine-readable identifier for a class of semver template error
+type ErrorCode string
+
+const (
+	CodeBundleNotRendered        ErrorCode = "BundleNotRendered"
+	CodeInvalidVersion           ErrorCode = "InvalidVersion"
+	CodeBuildMetadataConflict    ErrorCode = "BuildMetadataConflict"
+	CodeUnknownSchema            ErrorCode = "UnknownSchema"
+	CodeUnsupportedSchemaVersion ErrorCode = "UnsupportedSchemaVersion"
+	CodeTemplateTooLarge         ErrorCode = "TemplateTooLarge"
+	CodeTemplateReadTimeout      ErrorCode = "TemplateReadTimeout"
+	CodeDuplicateVersion         ErrorCode = "DuplicateVersion"
+	CodePolicyViolation          ErrorCode = "PolicyViolation"
+	CodeVersionCollision         ErrorCode = "VersionCollision"
+	CodeNoBundleEntries          ErrorCode = "NoBundleEntries"
+)
+
+// codedError is implemented by all typed errors in this package
+type codedError interface {
+	error
+	Code() ErrorCode
+}
+
+// ErrorCodeOf returns the code of the first typed semver template error in err's chain, including the errors
+// aggregated within it, if any
+func ErrorCodeOf(err error) (ErrorCode, bool) {
+	var ce codedError
+	if errors.As(err, &ce) {
+		return ce.Code(), true
+	}
+	// errors.As does not descend into aggregated errors
+	for ; err != nil; err = errors.Unwrap(err) {
+		if agg, ok := err.(interface{ Errors() []error }); ok {
+			for _, e := range agg.Errors() {
+				if code, ok := ErrorCodeOf(e); ok {
+					return code, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// ErrBundleNotRendered indicates that a bundle image referenced by the template is absent from the rendered bundles
+type ErrBundleNotRendered struct {
+	Image string
+}
+
+func (e *ErrBundleNotRendered) Error() string {
+	return fmt.Sprintf("supplied bundle image name %q not found in rendered bundle images", e.Image)
+}
+
+func (e *ErrBundleNotRendered) Code() ErrorCode { return CodeBundleNotRendered }
+
+// ErrInvalidVersion indicates that a rendered bundle's olm.package version is not valid semver
+type ErrInvalidVersion struct {
+	Bundle  string
+	Version string
+	Err     error
+}
+
+func (e *ErrInvalidVersion) Error() string {
+	return fmt.Sprintf("bundle %q has invalid version %q: %v", e.Bundle, e.Version, e.Err)
+}
+
+func (e *ErrInvalidVersion) Unwrap() error   { return e.Err }
+func (e *ErrInvalidVersion) Code() ErrorCode { return CodeInvalidVersion }
+
+// ErrBuildMetadataConflict indicates that two or more bundle versions differ only by build metadata, and so cannot be ordered
+type ErrBuildMetadataConflict struct {
+	Versions []string
+	Err      error
+}
+
+func (e *ErrBuildMetadataConflict) Error() string {
+	return fmt.Sprintf("encountered bundle versions which differ only by build metadata, which cannot be ordered: %v", e.Err)
+}
+
+func (e *ErrBuildMetadataConflict) Unwrap() error   { return e.Err }
+func (e *ErrBuildMetadataConflict) Code() ErrorCode { return CodeBuildMetadataConflict }
+
+// ErrUnknownSchema indicates that the template input does not declare the semver template schema
+type ErrUnknownSchema struct {
+	Schema string
+}
+
+func (e *ErrUnknownSchema) Error() string {
+	return fmt.Sprintf("readFile: input file has unknown schema, should be %q", schema)
+}
+
+func (e *ErrUnknownSchema) Code() ErrorCode { return CodeUnknownSchema }
+
+// ErrUnsupportedSchemaVersion indicates that the template declares a schema version outside the supported range
+type ErrUnsupportedSchemaVersion struct {
+	Version int
+}
+
+func (e *ErrUnsupportedSchemaVersion) Error() string {
+	if minSchemaVersion == currentSchemaVersion {
+		return fmt.Sprintf("readFile: unsupported schema version %d, the supported version is %d", e.Version, currentSchemaVersion)
+	}
+	return fmt.Sprintf("readFile: unsupported schema version %d, the supported versions are %d through %d", e.Version, minSchemaVersion, currentSchemaVersion)
+}
+
+func (e *ErrUnsupportedSchemaVersion) Code() ErrorCode { return CodeUnsupportedSchemaVersion }
+
+// ErrTemplateTooLarge indicates that the template input exceeds the configured size limit
+type ErrTemplateTooLarge struct {
+	Limit int64
+}
+
+func (e *ErrTemplateTooLarge) Error() string {
+	return fmt.Sprintf("readFile: input file exceeds the maximum template size of %d bytes", e.Limit)
+}
+
+func (e *ErrTemplateTooLarge) Code() ErrorCode { return CodeTemplateTooLarge }
+
+// ErrTemplateReadTimeout indicates that the template input could not be read within the configured time limit
+type ErrTemplateReadTimeout struct {
+	Timeout time.Duration
+}
+
+func (e *ErrTemplateReadTimeout) Error() string {
+	return fmt.Sprintf("readFile: timed out after %v reading input file", e.Timeout)
+}
+
+func (e *ErrTemplateReadTimeout) Code() ErrorCode { return CodeTemplateReadTimeout }
+
+// ErrDuplicateVersion indicates that distinct bundle images within one channel archetype have the same version
+type ErrDuplicateVersion struct {
+	Images  []string
+	Version string
+}
+
+func (e *ErrDuplicateVersion) Error() string {
+	return fmt.Sprintf("bundle images %q share version %q", e.Images, e.Version)
+}
+
+func (e *ErrDuplicateVersion) Code() ErrorCode { return CodeDuplicateVersion }
+
+// ErrVersionCollision indicates that differently-named bundles have the same version in different channel archetypes
+type ErrVersionCollision struct {
+	Version    string
+	Bundles    []string
+	Archetypes []string
+}
+
+func (e *ErrVersionCollision) Error() string {
+	return fmt.Sprintf("version %q is bundle %q in %s, but bundle %q in %s", e.Version, e.Bundles[0], e.Archetypes[0], e.Bundles[1], e.Archetypes[1])
+}
+
+func (e *ErrVersionCollision) Code() ErrorCode { return CodeVersionCollision }
+
+// ErrNoBundleEntries indicates that an otherwise valid template lists no bundles to render
+type ErrNoBundleEntries struct{}
+
+func (e *ErrNoBundleEntries) Error() string {
+	return "template contains no bundle entries"
+}
+
+func (e *ErrNoBundleEntries) Code() ErrorCode { return CodeNoBundleEntries }
+
+// ErrPolicyViolation indicates that the template does not satisfy its policy
+type ErrPolicyViolation struct {
+	Violations []string
+}
+
+func (e *ErrPolicyViolation) Error() string {
+	return fmt.Sprintf("policy violations: [%s]", strings.Join(e.Violations, ", "))
+}
+
+func (e *ErrPolicyViolation) Code() ErrorCode { return CodePolicyViolation }
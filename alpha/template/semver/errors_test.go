@@ -0,0 +1,211 @@
+package semver
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/property"
+)
+
+func TestTypedErrors(t *testing.T) {
+	t.Run("unknown schema", func(t *testing.T) {
+		_, err := Template{}.readFile(strings.NewReader("schema: olm.foo\n"))
+		var target *ErrUnknownSchema
+		require.True(t, errors.As(err, &target))
+		require.Equal(t, "olm.foo", target.Schema)
+		require.EqualError(t, err, `readFile: input file has unknown schema, should be "olm.semver"`)
+
+		code, ok := ErrorCodeOf(err)
+		require.True(t, ok)
+		require.Equal(t, CodeUnknownSchema, code)
+	})
+
+	t.Run("unknown schema through Render", func(t *testing.T) {
+		tmpl := Template{Data: strings.NewReader("schema: olm.foo\n")}
+		_, err := tmpl.Render(context.Background())
+		var target *ErrUnknownSchema
+		require.True(t, errors.As(err, &target))
+	})
+
+	t.Run("unsupported schema version", func(t *testing.T) {
+		// a newer template is reported by its version, not by the attributes this version does not know
+		_, err := Template{}.readFile(strings.NewReader("schema: olm.semver\nschemaVersion: 2\nchannelGroups: []\n"))
+		var target *ErrUnsupportedSchemaVersion
+		require.True(t, errors.As(err, &target))
+		require.Equal(t, 2, target.Version)
+		require.EqualError(t, err, "readFile: unsupported schema version 2, the supported version is 1")
+
+		code, ok := ErrorCodeOf(err)
+		require.True(t, ok)
+		require.Equal(t, CodeUnsupportedSchemaVersion, code)
+
+		_, err = Template{}.readFile(strings.NewReader("schema: olm.semver\nschemaVersion: 0\n"))
+		require.EqualError(t, err, "readFile: unsupported schema version 0, the supported version is 1")
+
+		// an unversioned template is of the current version
+		sv, err := Template{}.readFile(strings.NewReader("schema: olm.semver\n"))
+		require.NoError(t, err)
+		require.Equal(t, currentSchemaVersion, sv.SchemaVersion)
+		sv, err = Template{}.readFile(strings.NewReader("schema: olm.semver\nschemaVersion: 1\n"))
+		require.NoError(t, err)
+		require.Equal(t, currentSchemaVersion, sv.SchemaVersion)
+	})
+
+	t.Run("bundle not rendered", func(t *testing.T) {
+		sv := semverTemplate{}
+		_, err := sv.getVersionsFromChannel([]semverTemplateBundleEntry{{Image: "repo/origin/a-v0.1.0"}}, &declcfg.DeclarativeConfig{})
+		var target *ErrBundleNotRendered
+		require.True(t, errors.As(err, &target))
+		require.Equal(t, "repo/origin/a-v0.1.0", target.Image)
+		require.EqualError(t, err, `supplied bundle image name "repo/origin/a-v0.1.0" not found in rendered bundle images`)
+	})
+
+	t.Run("invalid version", func(t *testing.T) {
+		sv := semverTemplate{}
+		dc := declcfg.DeclarativeConfig{
+			Bundles: []declcfg.Bundle{
+				{Schema: "olm.bundle", Image: "repo/origin/a-v0.1.0", Name: "a-v0.1.0", Properties: []property.Property{property.MustBuildPackage("a", "0.1.x")}},
+			},
+		}
+		_, err := sv.getVersionsFromChannel([]semverTemplateBundleEntry{{Image: "repo/origin/a-v0.1.0"}}, &dc)
+		var target *ErrInvalidVersion
+		require.True(t, errors.As(err, &target))
+		require.Equal(t, "a-v0.1.0", target.Bundle)
+		require.Equal(t, "0.1.x", target.Version)
+		require.NotNil(t, errors.Unwrap(err))
+	})
+
+	t.Run("build metadata conflict", func(t *testing.T) {
+		sv := semverTemplate{
+			Stable: semverTemplateChannelBundles{
+				Bundles: []semverTemplateBundleEntry{
+					{Image: "repo/origin/a-v0.1.0+1"},
+					{Image: "repo/origin/a-v0.1.0+2"},
+				},
+			},
+		}
+		dc := declcfg.DeclarativeConfig{
+			Bundles: []declcfg.Bundle{
+				{Schema: "olm.bundle", Image: "repo/origin/a-v0.1.0+1", Name: "a-v0.1.0+1", Properties: []property.Property{property.MustBuildPackage("a", "0.1.0+1")}},
+				{Schema: "olm.bundle", Image: "repo/origin/a-v0.1.0+2", Name: "a-v0.1.0+2", Properties: []property.Property{property.MustBuildPackage("a", "0.1.0+2")}},
+			},
+		}
+		_, err := sv.getVersionsFromStandardChannels(&dc)
+		var target *ErrBuildMetadataConflict
+		require.True(t, errors.As(err, &target))
+		require.Len(t, target.Versions, 1)
+		require.Contains(t, err.Error(), "encountered bundle versions which differ only by build metadata, which cannot be ordered")
+
+		code, ok := ErrorCodeOf(err)
+		require.True(t, ok)
+		require.Equal(t, CodeBuildMetadataConflict, code)
+	})
+
+	t.Run("untyped errors have no code", func(t *testing.T) {
+		_, ok := ErrorCodeOf(errors.New("boom"))
+		require.False(t, ok)
+	})
+}
+
+func TestDuplicateVersionWithinArchetype(t *testing.T) {
+	sv := semverTemplate{
+		Stable: semverTemplateChannelBundles{
+			Bundles: []semverTemplateBundleEntry{
+				{Image: "repo/origin/a-v0.1.0"},
+				{Image: "repo/rebuild/a-v0.1.0"},
+			},
+		},
+	}
+	dc := declcfg.DeclarativeConfig{
+		Bundles: []declcfg.Bundle{
+			{Schema: "olm.bundle", Image: "repo/origin/a-v0.1.0", Name: "a-v0.1.0", Properties: []property.Property{property.MustBuildPackage("a", "0.1.0")}},
+			{Schema: "olm.bundle", Image: "repo/rebuild/a-v0.1.0", Name: "a-v0.1.0-rebuild", Properties: []property.Property{property.MustBuildPackage("a", "0.1.0")}},
+		},
+	}
+	_, err := sv.getVersionsFromStandardChannels(&dc)
+	var target *ErrDuplicateVersion
+	require.ErrorAs(t, err, &target)
+	require.Equal(t, []string{"repo/origin/a-v0.1.0", "repo/rebuild/a-v0.1.0"}, target.Images)
+	require.EqualError(t, err, `bundle images ["repo/origin/a-v0.1.0" "repo/rebuild/a-v0.1.0"] share version "0.1.0"`)
+
+	// versions are compared without their build metadata
+	dc.Bundles[0].Properties = []property.Property{property.MustBuildPackage("a", "0.1.0+a")}
+	dc.Bundles[1].Properties = []property.Property{property.MustBuildPackage("a", "0.1.0+b")}
+	_, err = sv.getVersionsFromStandardChannels(&dc)
+	require.ErrorAs(t, err, &target)
+	require.Equal(t, []string{"repo/origin/a-v0.1.0", "repo/rebuild/a-v0.1.0"}, target.Images)
+	require.EqualError(t, err, `encountered bundle versions which differ only by build metadata, which cannot be ordered: bundle images ["repo/origin/a-v0.1.0" "repo/rebuild/a-v0.1.0"] share version "0.1.0"`)
+
+	// unless they are ordered by it
+	sv.BuildMetadataPolicy = buildMetadataPolicyOrder
+	_, err = sv.getVersionsFromStandardChannels(&dc)
+	require.NoError(t, err)
+}
+
+func TestVersionCollisionAcrossArchetypes(t *testing.T) {
+	sv := semverTemplate{
+		Fast: semverTemplateChannelBundles{
+			Bundles: []semverTemplateBundleEntry{{Image: "repo/fast/a-v0.1.0"}},
+		},
+		Stable: semverTemplateChannelBundles{
+			Bundles: []semverTemplateBundleEntry{{Image: "repo/stable/a-v0.1.0"}},
+		},
+	}
+	dc := declcfg.DeclarativeConfig{
+		Bundles: []declcfg.Bundle{
+			{Schema: "olm.bundle", Image: "repo/fast/a-v0.1.0", Name: "a-v0.1.0", Properties: []property.Property{property.MustBuildPackage("a", "0.1.0")}},
+			{Schema: "olm.bundle", Image: "repo/stable/a-v0.1.0", Name: "a-v0.1.0-copy", Properties: []property.Property{property.MustBuildPackage("a", "0.1.0")}},
+		},
+	}
+	_, err := sv.getVersionsFromStandardChannels(&dc)
+	var target *ErrVersionCollision
+	require.ErrorAs(t, err, &target)
+	require.Equal(t, CodeVersionCollision, target.Code())
+	require.EqualError(t, err, `version "0.1.0" is bundle "a-v0.1.0" in fast, but bundle "a-v0.1.0-copy" in stable`)
+
+	// the same bundle may be in several archetypes
+	dc.Bundles = dc.Bundles[:1]
+	sv.Stable.Bundles[0].Image = "repo/fast/a-v0.1.0"
+	_, err = sv.getVersionsFromStandardChannels(&dc)
+	require.NoError(t, err)
+}
+
+func TestErrorsAccumulatedAcrossArchetypes(t *testing.T) {
+	sv := semverTemplate{
+		Candidate: semverTemplateChannelBundles{
+			Bundles: []semverTemplateBundleEntry{{Image: "repo/candidate/a-v0.2.0"}},
+		},
+		Stable: semverTemplateChannelBundles{
+			Bundles: []semverTemplateBundleEntry{
+				{Image: "repo/origin/a-v0.1.0"},
+				{Image: "repo/rebuild/a-v0.1.0"},
+			},
+		},
+	}
+	dc := declcfg.DeclarativeConfig{
+		Bundles: []declcfg.Bundle{
+			{Schema: "olm.bundle", Image: "repo/candidate/a-v0.2.0", Name: "a-v0.2.0", Properties: []property.Property{property.MustBuildPackage("a", "0.2.x")}},
+			{Schema: "olm.bundle", Image: "repo/origin/a-v0.1.0", Name: "a-v0.1.0", Properties: []property.Property{property.MustBuildPackage("a", "0.1.0")}},
+			{Schema: "olm.bundle", Image: "repo/rebuild/a-v0.1.0", Name: "a-v0.1.0-rebuild", Properties: []property.Property{property.MustBuildPackage("a", "0.1.0")}},
+		},
+	}
+	_, err := sv.getVersionsFromStandardChannels(&dc)
+	require.EqualError(t, err, `[bundle "a-v0.2.0" has invalid version "0.2.x": Invalid character(s) found in patch number "x", bundle images ["repo/origin/a-v0.1.0" "repo/rebuild/a-v0.1.0"] share version "0.1.0"]`)
+	code, ok := ErrorCodeOf(err)
+	require.True(t, ok)
+	require.Equal(t, CodeInvalidVersion, code)
+}
+
+func TestNoBundleEntries(t *testing.T) {
+	// a registry is never needed, since the template is rejected before rendering
+	_, err := Template{Data: strings.NewReader("schema: olm.semver\ngenerateMajorChannels: true\nstable:\n  bundles: []\n")}.Render(context.Background())
+	require.EqualError(t, err, "render: template contains no bundle entries")
+	code, ok := ErrorCodeOf(err)
+	require.True(t, ok)
+	require.Equal(t, CodeNoBundleEntries, code)
+}
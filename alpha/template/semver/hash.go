@@ -0,0 +1,76 @@
+package semver
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+// canonicalGraph is the canonical serialization of an upgrade graph: each package's default channel, and its channels
+// with their entries and edges, all in a deterministic order
+type canonicalGraph struct {
+	Packages []canonicalPackage `json:"packages"`
+}
+
+type canonicalPackage struct {
+	Name           string             `json:"name"`
+	DefaultChannel string             `json:"defaultChannel"`
+	Channels       []canonicalChannel `json:"channels"`
+}
+
+type canonicalChannel struct {
+	Name    string                 `json:"name"`
+	Entries []declcfg.ChannelEntry `json:"entries"`
+}
+
+// GraphHash renders the template and returns a deterministic hash of the resulting upgrade graph: channels, their
+// entries and edges, and default channels.  Bundle contents do not contribute to the hash, so it changes only when
+// the graph does.
+func (t Template) GraphHash(ctx context.Context) (string, error) {
+	out, err := t.Render(ctx)
+	if err != nil {
+		return "", err
+	}
+	return graphHash(out)
+}
+
+func graphHash(cfg *declcfg.DeclarativeConfig) (string, error) {
+	packages := make(map[string]*canonicalPackage)
+	pkg := func(name string) *canonicalPackage {
+		if _, ok := packages[name]; !ok {
+			packages[name] = &canonicalPackage{Name: name, Channels: []canonicalChannel{}}
+		}
+		return packages[name]
+	}
+	for _, p := range cfg.Packages {
+		pkg(p.Name).DefaultChannel = p.DefaultChannel
+	}
+	for _, ch := range cfg.Channels {
+		entries := make([]declcfg.ChannelEntry, 0, len(ch.Entries))
+		for _, e := range ch.Entries {
+			skips := append([]string{}, e.Skips...)
+			sort.Strings(skips)
+			entries = append(entries, declcfg.ChannelEntry{Name: e.Name, Replaces: e.Replaces, Skips: skips, SkipRange: e.SkipRange})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		p := pkg(ch.Package)
+		p.Channels = append(p.Channels, canonicalChannel{Name: ch.Name, Entries: entries})
+	}
+
+	graph := canonicalGraph{Packages: []canonicalPackage{}}
+	for _, p := range packages {
+		sort.Slice(p.Channels, func(i, j int) bool { return p.Channels[i].Name < p.Channels[j].Name })
+		graph.Packages = append(graph.Packages, *p)
+	}
+	sort.Slice(graph.Packages, func(i, j int) bool { return graph.Packages[i].Name < graph.Packages[j].Name })
+
+	data, err := json.Marshal(graph)
+	if err != nil {
+		return "", err
+	}
+	return digest.FromBytes(data).String(), nil
+}
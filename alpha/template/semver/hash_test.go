@@ -0,0 +1,41 @@
+package semver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphHash(t *testing.T) {
+	bundles := testBundles("a", "0.1.0", "0.1.1", "0.2.0", "1.0.0")
+	newTemplate := func(bundles ...testBundle) Template {
+		data := "---\nschema: olm.semver\ngenerateMajorChannels: true\ngenerateMinorChannels: true\nstable:\n  bundles:\n"
+		for _, b := range bundles {
+			data += fmt.Sprintf("  - image: %s\n", b.image)
+		}
+		return Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}
+	}
+
+	first, err := newTemplate(bundles[:3]...).GraphHash(context.Background())
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(first, "sha256:"))
+
+	// rendering is not ordered, but the hash is
+	for i := 0; i < 5; i++ {
+		again, err := newTemplate(bundles[:3]...).GraphHash(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, first, again)
+	}
+
+	added, err := newTemplate(bundles...).GraphHash(context.Background())
+	require.NoError(t, err)
+	require.NotEqual(t, first, added)
+	for i := 0; i < 5; i++ {
+		again, err := newTemplate(bundles...).GraphHash(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, added, again)
+	}
+}
@@ -0,0 +1,148 @@
+package semver
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"testing/fstest"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/operator-framework/operator-registry/pkg/image"
+	"github.com/operator-framework/operator-registry/pkg/lib/bundle"
+)
+
+type testBundle struct {
+	image   string
+	pkg     string
+	version string
+	csvName string // defaults to <pkg>.v<version>
+	labels  map[string]string
+}
+
+func testImage(pkg string, version string) string {
+	return fmt.Sprintf("test.registry/%s-operator/%s-bundle:v%s", pkg, pkg, version)
+}
+
+// testBundles builds one test bundle per version for the named package, using testImage references
+func testBundles(pkg string, versions ...string) []testBundle {
+	bundles := make([]testBundle, 0, len(versions))
+	for _, v := range versions {
+		bundles = append(bundles, testBundle{image: testImage(pkg, v), pkg: pkg, version: v})
+	}
+	return bundles
+}
+
+const testCSV = `apiVersion: operators.coreos.com/v1alpha1
+kind: ClusterServiceVersion
+metadata:
+  name: %s
+  labels: %s
+spec:
+  version: %s
+`
+
+const testAnnotations = `annotations:
+  operators.operatorframework.io.bundle.package.v1: %s
+  operators.operatorframework.io.bundle.channels.v1: stable
+`
+
+// testBundleFS returns the contents of a minimal bundle for the test bundle
+func testBundleFS(b testBundle) fstest.MapFS {
+	csvName := b.csvName
+	if csvName == "" {
+		csvName = fmt.Sprintf("%s.v%s", b.pkg, b.version)
+	}
+	labels, err := json.Marshal(b.labels)
+	if err != nil {
+		panic(err)
+	}
+	return fstest.MapFS{
+		"metadata/annotations.yaml": &fstest.MapFile{Data: []byte(fmt.Sprintf(testAnnotations, b.pkg))},
+		"manifests/csv.yaml":        &fstest.MapFile{Data: []byte(fmt.Sprintf(testCSV, csvName, labels, b.version))},
+	}
+}
+
+// newTestRegistry returns a mock registry serving a minimal bundle image for each of the supplied bundles
+func newTestRegistry(bundles ...testBundle) *image.MockRegistry {
+	reg := &image.MockRegistry{RemoteImages: map[image.Reference]*image.MockImage{}}
+	for _, b := range bundles {
+		reg.RemoteImages[image.SimpleReference(b.image)] = &image.MockImage{
+			Labels: map[string]string{bundle.PackageLabel: b.pkg},
+			FS:     testBundleFS(b),
+		}
+	}
+	return reg
+}
+
+// writeTestBundleDir unpacks a minimal bundle for the test bundle into a temporary directory, returning its path
+func writeTestBundleDir(t *testing.T, b testBundle) string {
+	dir := t.TempDir()
+	for name, f := range testBundleFS(b) {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), f.Data, 0644))
+	}
+	return dir
+}
+
+// writeTestBundleOCILayout writes the test bundle as a single-layer image to an OCI image layout in a temporary
+// directory, returning its path
+func writeTestBundleOCILayout(t *testing.T, b testBundle) string {
+	fsys := testBundleFS(b)
+	names := make([]string, 0, len(fsys))
+	for name := range fsys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var layer bytes.Buffer
+	tw := tar.NewWriter(&layer)
+	dirs := map[string]bool{}
+	for _, name := range names {
+		if dir := filepath.Dir(name); dir != "." && !dirs[dir] {
+			require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: dir + "/", Mode: 0755}))
+			dirs[dir] = true
+		}
+		require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: int64(len(fsys[name].Data))}))
+		_, err := tw.Write(fsys[name].Data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0755))
+	writeBlob := func(mediaType string, data []byte) ocispec.Descriptor {
+		d := digest.FromBytes(data)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "blobs", "sha256", d.Encoded()), data, 0644))
+		return ocispec.Descriptor{MediaType: mediaType, Digest: d, Size: int64(len(data))}
+	}
+	config, err := json.Marshal(ocispec.Image{
+		OS:     "linux",
+		RootFS: ocispec.RootFS{Type: "layers", DiffIDs: []digest.Digest{digest.FromBytes(layer.Bytes())}},
+		Config: ocispec.ImageConfig{Labels: map[string]string{bundle.PackageLabel: b.pkg}},
+	})
+	require.NoError(t, err)
+	manifest, err := json.Marshal(ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		Config:    writeBlob(ocispec.MediaTypeImageConfig, config),
+		Layers:    []ocispec.Descriptor{writeBlob(ocispec.MediaTypeImageLayer, layer.Bytes())},
+	})
+	require.NoError(t, err)
+	index, err := json.Marshal(ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		Manifests: []ocispec.Descriptor{writeBlob(ocispec.MediaTypeImageManifest, manifest)},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.json"), index, 0644))
+	layout, err := json.Marshal(ocispec.ImageLayout{Version: ocispec.ImageLayoutVersion})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ocispec.ImageLayoutFile), layout, 0644))
+	return dir
+}
@@ -0,0 +1,144 @@
+package semver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/operator-framework/operator-registry/alpha/action"
+	"github.com/operator-framework/operator-registry/pkg/image"
+)
+
+// DefaultLoadTimeout bounds the time LoadTemplate spends fetching a template
+const DefaultLoadTimeout = 30 * time.Second
+
+// templateContentTypes are the media types accepted for templates fetched over http(s).  Servers of raw files commonly
+// report text/plain or application/octet-stream, which are accepted as well.
+var templateContentTypes = []string{
+	"application/yaml",
+	"application/x-yaml",
+	"text/yaml",
+	"text/x-yaml",
+	"application/json",
+	"text/plain",
+	"application/octet-stream",
+}
+
+// LoadTemplate fetches a template from a location, which may be a local path, a file:// or http(s):// URL, or an
+// oci:// reference to an artifact image holding a single template file (named *.yaml, *.yml, or *.json) at its root.
+// The returned template reads the fetched content as its Data, and renders its bundles with a new registry, created as
+// action.Render creates one (and also used to pull an oci:// artifact); callers should Destroy its Registry when done.
+// Fetching is limited to DefaultLoadTimeout and DefaultMaxTemplateSize.
+func LoadTemplate(ctx context.Context, location string) (*Template, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultLoadTimeout)
+	defer cancel()
+
+	reg, err := action.CreateRegistry()
+	if err != nil {
+		return nil, fmt.Errorf("load template %q: create registry: %v", location, err)
+	}
+
+	var data []byte
+	u, err := url.Parse(location)
+	switch {
+	case err != nil || u.Scheme == "":
+		// not a URL, or a URL without a scheme, as a relative path
+		data, err = loadFile(location)
+	case u.Scheme == "file":
+		data, err = loadFile(u.Path)
+	case u.Scheme == "http" || u.Scheme == "https":
+		data, err = loadHTTP(ctx, location)
+	case u.Scheme == "oci":
+		data, err = loadArtifact(ctx, reg, strings.TrimPrefix(location, "oci://"))
+	default:
+		err = fmt.Errorf("unsupported scheme %q, expected a path or a file, http, https, or oci URL", u.Scheme)
+	}
+	if err != nil {
+		reg.Destroy()
+		return nil, fmt.Errorf("load template %q: %w", location, err)
+	}
+	return &Template{Data: bytes.NewReader(data), Registry: reg}, nil
+}
+
+func loadFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readAllWithLimits(f, DefaultMaxTemplateSize, 0)
+}
+
+// loadHTTP fetches a template over http(s), which must be served successfully with one of templateContentTypes
+func loadHTTP(ctx context.Context, location string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response status %q", resp.Status)
+	}
+
+	// a response without a content type is treated as application/octet-stream
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, fmt.Errorf("invalid content type %q: %v", contentType, err)
+		}
+		valid := false
+		for _, t := range templateContentTypes {
+			valid = valid || mediaType == t
+		}
+		if !valid {
+			return nil, fmt.Errorf("unexpected content type %q, expected one of %q", mediaType, templateContentTypes)
+		}
+	}
+	return readAllWithLimits(resp.Body, DefaultMaxTemplateSize, 0)
+}
+
+// loadArtifact pulls an artifact image and reads the single template file at its root
+func loadArtifact(ctx context.Context, reg image.Registry, ref string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "semver-template-")
+	if err != nil {
+		return nil, fmt.Errorf("create tempdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	imageRef := image.SimpleReference(ref)
+	if err := reg.Pull(ctx, imageRef); err != nil {
+		return nil, err
+	}
+	if err := reg.Unpack(ctx, imageRef, dir); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, e := range entries {
+		switch filepath.Ext(e.Name()) {
+		case ".yaml", ".yml", ".json":
+			if e.Type().IsRegular() {
+				files = append(files, e.Name())
+			}
+		}
+	}
+	if len(files) != 1 {
+		return nil, fmt.Errorf("artifact %q has %d template files %q at its root, expected exactly 1", ref, len(files), files)
+	}
+	return loadFile(filepath.Join(dir, files[0]))
+}
@@ -0,0 +1,63 @@
+package semver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTemplate(t *testing.T) {
+	const data = "schema: olm.semver\n"
+	load := func(location string) (string, error) {
+		tmpl, err := LoadTemplate(context.Background(), location)
+		if err != nil {
+			return "", err
+		}
+		defer tmpl.Registry.Destroy()
+		loaded, err := io.ReadAll(tmpl.Data)
+		require.NoError(t, err)
+		return string(loaded), nil
+	}
+
+	path := filepath.Join(t.TempDir(), "template.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(data), 0644))
+	loaded, err := load(path)
+	require.NoError(t, err)
+	require.Equal(t, data, loaded)
+	loaded, err = load("file://" + path)
+	require.NoError(t, err)
+	require.Equal(t, data, loaded)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/template.yaml":
+			w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+			fmt.Fprint(w, data)
+		case "/login":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<html></html>")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	loaded, err = load(srv.URL + "/template.yaml")
+	require.NoError(t, err)
+	require.Equal(t, data, loaded)
+
+	_, err = load(srv.URL + "/login")
+	require.ErrorContains(t, err, `unexpected content type "text/html"`)
+	_, err = load(srv.URL + "/missing")
+	require.ErrorContains(t, err, `unexpected response status "404 Not Found"`)
+	_, err = load("ftp://example.com/template.yaml")
+	require.ErrorContains(t, err, `unsupported scheme "ftp"`)
+	_, err = load(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,76 @@
+package semver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/docker/distribution/reference"
+
+	"github.com/operator-framework/operator-registry/pkg/image"
+)
+
+// RenderManifest lists the exact bundle images which went into a rendered catalog, for supply-chain tooling
+type RenderManifest struct {
+	Bundles []ManifestBundle `json:"bundles"`
+}
+
+// ManifestBundle identifies a single rendered bundle image
+type ManifestBundle struct {
+	// Image is the bundle image reference as it appears in the template
+	Image string `json:"image"`
+	// Digest is the resolved digest of the bundle image
+	Digest string `json:"digest"`
+	// Tag is the tag of the template's image reference, if it has one
+	Tag     string `json:"tag,omitempty"`
+	Package string `json:"package"`
+	Version string `json:"version"`
+}
+
+// RenderManifest renders the template and lists each bundle in the result with its resolved image digest.  Bundles
+// referenced by tag are resolved using the template's Registry, which must implement image.DigestResolver.
+func (t Template) RenderManifest(ctx context.Context) (*RenderManifest, error) {
+	out, err := t.Render(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &RenderManifest{Bundles: []ManifestBundle{}}
+	for _, b := range out.Bundles {
+		pkg, err := bundlePackage(b)
+		if err != nil {
+			return nil, err
+		}
+
+		ref, err := reference.ParseNormalizedNamed(b.Image)
+		if err != nil {
+			return nil, fmt.Errorf("parse image reference for bundle %q: %v", b.Name, err)
+		}
+		entry := ManifestBundle{
+			Image:   b.Image,
+			Package: pkg.PackageName,
+			Version: pkg.Version,
+		}
+		if tagged, ok := ref.(reference.Tagged); ok {
+			entry.Tag = tagged.Tag()
+		}
+		if digested, ok := ref.(reference.Digested); ok {
+			entry.Digest = digested.Digest().String()
+		} else {
+			resolver, ok := t.Registry.(image.DigestResolver)
+			if !ok {
+				return nil, fmt.Errorf("unable to resolve the digest of bundle image %q: registry does not support digest resolution", b.Image)
+			}
+			d, err := resolver.Digest(ctx, image.SimpleReference(b.Image))
+			if err != nil {
+				return nil, fmt.Errorf("unable to resolve the digest of bundle image %q: %v", b.Image, err)
+			}
+			entry.Digest = d.String()
+		}
+		manifest.Bundles = append(manifest.Bundles, entry)
+	}
+	sort.Slice(manifest.Bundles, func(i, j int) bool {
+		return manifest.Bundles[i].Image < manifest.Bundles[j].Image
+	})
+	return manifest, nil
+}
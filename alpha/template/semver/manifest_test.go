@@ -0,0 +1,47 @@
+package semver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+
+	"github.com/operator-framework/operator-registry/pkg/image"
+)
+
+func TestRenderManifest(t *testing.T) {
+	bundles := testBundles("a", "0.1.0", "0.1.1")
+	newTemplate := func() Template {
+		reg := newTestRegistry(bundles...)
+		for _, b := range bundles {
+			reg.RemoteImages[image.SimpleReference(b.image)].Digest = digest.FromString(b.image)
+		}
+		return Template{
+			Data: strings.NewReader(fmt.Sprintf(`---
+schema: olm.semver
+stable:
+  bundles:
+  - image: %s
+  - image: %s
+`, bundles[1].image, bundles[0].image)),
+			Registry: reg,
+		}
+	}
+
+	manifest, err := newTemplate().RenderManifest(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, &RenderManifest{Bundles: []ManifestBundle{
+		{Image: bundles[0].image, Digest: digest.FromString(bundles[0].image).String(), Tag: "v0.1.0", Package: "a", Version: "0.1.0"},
+		{Image: bundles[1].image, Digest: digest.FromString(bundles[1].image).String(), Tag: "v0.1.1", Package: "a", Version: "0.1.1"},
+	}}, manifest)
+
+	t.Run("unresolvable digest", func(t *testing.T) {
+		tmpl := newTemplate()
+		tmpl.Registry.(*image.MockRegistry).RemoteImages[image.SimpleReference(bundles[0].image)].Digest = ""
+		_, err := tmpl.RenderManifest(context.Background())
+		require.EqualError(t, err, fmt.Sprintf("unable to resolve the digest of bundle image %q: no digest", bundles[0].image))
+	})
+}
@@ -0,0 +1,181 @@
+package semver
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/operator-framework/operator-registry/alpha/action"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/pkg/containertools"
+	"github.com/operator-framework/operator-registry/pkg/image/containerdregistry"
+)
+
+// catalogConfigsDir is the directory within the catalog image which holds the file-based catalog
+const catalogConfigsDir = "/configs"
+
+// RenderToOCILayout renders the template and writes the result to dir as a file-based catalog image in OCI image
+// layout format, ready to be copied to a registry.  dir is created if it does not exist.
+func (t Template) RenderToOCILayout(ctx context.Context, dir string) error {
+	out, err := t.Render(ctx)
+	if err != nil {
+		return err
+	}
+	return writeCatalogOCILayout(out, dir)
+}
+
+// writeCatalogOCILayout writes cfg as a single-layer catalog image, labeled with the location of its configs, to an
+// OCI image layout at dir
+func writeCatalogOCILayout(cfg *declcfg.DeclarativeConfig, dir string) error {
+	// the catalog layer holds a file for each package, configs/<package>/catalog.json
+	layerDir := strings.TrimPrefix(catalogConfigsDir, "/")
+	var layer bytes.Buffer
+	tw := tar.NewWriter(&layer)
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: layerDir + "/", Mode: 0755}); err != nil {
+		return fmt.Errorf("write catalog layer: %v", err)
+	}
+	for _, pkg := range packageConfigs(cfg) {
+		var catalog bytes.Buffer
+		if err := declcfg.WriteJSON(*pkg.cfg, &catalog); err != nil {
+			return fmt.Errorf("write catalog: %v", err)
+		}
+		headers := []*tar.Header{
+			{Typeflag: tar.TypeDir, Name: path.Join(layerDir, pkg.name) + "/", Mode: 0755},
+			{Typeflag: tar.TypeReg, Name: path.Join(layerDir, pkg.name, "catalog.json"), Mode: 0644, Size: int64(catalog.Len())},
+		}
+		for _, h := range headers {
+			if err := tw.WriteHeader(h); err != nil {
+				return fmt.Errorf("write catalog layer: %v", err)
+			}
+		}
+		if _, err := tw.Write(catalog.Bytes()); err != nil {
+			return fmt.Errorf("write catalog layer: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("write catalog layer: %v", err)
+	}
+
+	blobsDir := filepath.Join(dir, "blobs", digest.Canonical.String())
+	if err := os.MkdirAll(blobsDir, 0755); err != nil {
+		return err
+	}
+	writeBlob := func(mediaType string, data []byte) (ocispec.Descriptor, error) {
+		d := digest.FromBytes(data)
+		if err := os.WriteFile(filepath.Join(blobsDir, d.Encoded()), data, 0644); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		return ocispec.Descriptor{MediaType: mediaType, Digest: d, Size: int64(len(data))}, nil
+	}
+
+	layerDesc, err := writeBlob(ocispec.MediaTypeImageLayer, layer.Bytes())
+	if err != nil {
+		return err
+	}
+
+	imageConfig, err := json.Marshal(ocispec.Image{
+		Architecture: "amd64",
+		OS:           "linux",
+		Config: ocispec.ImageConfig{
+			Labels: map[string]string{containertools.ConfigsLocationLabel: catalogConfigsDir},
+		},
+		RootFS: ocispec.RootFS{Type: "layers", DiffIDs: []digest.Digest{layerDesc.Digest}},
+	})
+	if err != nil {
+		return err
+	}
+	configDesc, err := writeBlob(ocispec.MediaTypeImageConfig, imageConfig)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := json.Marshal(ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		Config:    configDesc,
+		Layers:    []ocispec.Descriptor{layerDesc},
+	})
+	if err != nil {
+		return err
+	}
+	manifestDesc, err := writeBlob(ocispec.MediaTypeImageManifest, manifest)
+	if err != nil {
+		return err
+	}
+
+	index, err := json.Marshal(ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		Manifests: []ocispec.Descriptor{manifestDesc},
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.json"), index, 0644); err != nil {
+		return err
+	}
+	layout, err := json.Marshal(ocispec.ImageLayout{Version: ocispec.ImageLayoutVersion})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ocispec.ImageLayoutFile), layout, 0644)
+}
+
+type packageConfig struct {
+	name string
+	cfg  *declcfg.DeclarativeConfig
+}
+
+// packageConfigs splits cfg into the configs of each of its packages, in order of package name
+func packageConfigs(cfg *declcfg.DeclarativeConfig) []packageConfig {
+	byName := map[string]*declcfg.DeclarativeConfig{}
+	get := func(name string) *declcfg.DeclarativeConfig {
+		if _, ok := byName[name]; !ok {
+			byName[name] = &declcfg.DeclarativeConfig{}
+		}
+		return byName[name]
+	}
+	for _, p := range cfg.Packages {
+		get(p.Name).Packages = append(get(p.Name).Packages, p)
+	}
+	for _, ch := range cfg.Channels {
+		get(ch.Package).Channels = append(get(ch.Package).Channels, ch)
+	}
+	for _, b := range cfg.Bundles {
+		get(b.Package).Bundles = append(get(b.Package).Bundles, b)
+	}
+	for _, o := range cfg.Others {
+		get(o.Package).Others = append(get(o.Package).Others, o)
+	}
+
+	pkgs := make([]packageConfig, 0, len(byName))
+	for name, c := range byName {
+		pkgs = append(pkgs, packageConfig{name: name, cfg: c})
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].name < pkgs[j].name })
+	return pkgs
+}
+
+// renderOCILayout unpacks the bundle image of the OCI layout directory layoutDir and renders it as a bundle directory
+func renderOCILayout(ctx context.Context, r action.Render, layoutDir string) (*declcfg.DeclarativeConfig, error) {
+	dir, err := os.MkdirTemp("", "semver-oci-layout-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	if err := containerdregistry.UnpackOCILayout(ctx, layoutDir, dir); err != nil {
+		return nil, fmt.Errorf("unpack OCI layout %q: %v", layoutDir, err)
+	}
+	r.Refs = []string{dir}
+	r.AllowedRefMask = action.RefBundleDir
+	return r.Run(ctx)
+}
@@ -0,0 +1,140 @@
+package semver
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/pkg/containertools"
+)
+
+func TestRenderToOCILayout(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.0.1", "1.1.0")
+	input := "schema: olm.semver\nstable:\n  bundles:\n"
+	for _, b := range bundles {
+		input += fmt.Sprintf("  - image: %s\n", b.image)
+	}
+	newTemplate := func() Template {
+		return Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...)}
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, newTemplate().RenderToOCILayout(context.Background(), dir))
+
+	readBlob := func(d digest.Digest) []byte {
+		data, err := os.ReadFile(filepath.Join(dir, "blobs", d.Algorithm().String(), d.Encoded()))
+		require.NoError(t, err)
+		require.Equal(t, d, digest.FromBytes(data))
+		return data
+	}
+
+	var layout ocispec.ImageLayout
+	data, err := os.ReadFile(filepath.Join(dir, ocispec.ImageLayoutFile))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &layout))
+	require.Equal(t, ocispec.ImageLayoutVersion, layout.Version)
+
+	var index ocispec.Index
+	data, err = os.ReadFile(filepath.Join(dir, "index.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &index))
+	require.Len(t, index.Manifests, 1)
+
+	var manifest ocispec.Manifest
+	require.NoError(t, json.Unmarshal(readBlob(index.Manifests[0].Digest), &manifest))
+	var config ocispec.Image
+	require.NoError(t, json.Unmarshal(readBlob(manifest.Config.Digest), &config))
+	configsDir := config.Config.Labels[containertools.ConfigsLocationLabel]
+	require.Equal(t, "/configs", configsDir)
+	require.Len(t, manifest.Layers, 1)
+
+	// unpack the layer and load the catalog back
+	unpacked := t.TempDir()
+	tr := tar.NewReader(bytes.NewReader(readBlob(manifest.Layers[0].Digest)))
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		target := filepath.Join(unpacked, h.Name)
+		if h.Typeflag == tar.TypeDir {
+			require.NoError(t, os.MkdirAll(target, 0755))
+			continue
+		}
+		contents, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(target, contents, 0644))
+	}
+	loaded, err := declcfg.LoadFS(os.DirFS(filepath.Join(unpacked, configsDir)))
+	require.NoError(t, err)
+
+	rendered, err := newTemplate().Render(context.Background())
+	require.NoError(t, err)
+
+	var expected, actual bytes.Buffer
+	require.NoError(t, declcfg.WriteJSON(*rendered, &expected))
+	require.NoError(t, declcfg.WriteJSON(*loaded, &actual))
+	require.Equal(t, expected.String(), actual.String())
+}
+
+func TestWriteCatalogOCILayoutPackages(t *testing.T) {
+	cfg := &declcfg.DeclarativeConfig{
+		Packages: []declcfg.Package{{Schema: declcfg.SchemaPackage, Name: "b"}, {Schema: declcfg.SchemaPackage, Name: "a"}},
+		Channels: []declcfg.Channel{
+			{Schema: declcfg.SchemaChannel, Name: "stable", Package: "a", Entries: []declcfg.ChannelEntry{{Name: "a.v1.0.0"}}},
+			{Schema: declcfg.SchemaChannel, Name: "stable", Package: "b", Entries: []declcfg.ChannelEntry{{Name: "b.v1.0.0"}}},
+		},
+	}
+	dir := t.TempDir()
+	require.NoError(t, writeCatalogOCILayout(cfg, dir))
+
+	var index ocispec.Index
+	data, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &index))
+	readBlob := func(d digest.Digest) []byte {
+		data, err := os.ReadFile(filepath.Join(dir, "blobs", d.Algorithm().String(), d.Encoded()))
+		require.NoError(t, err)
+		return data
+	}
+	var manifest ocispec.Manifest
+	require.NoError(t, json.Unmarshal(readBlob(index.Manifests[0].Digest), &manifest))
+
+	// each package is written to its own directory, holding only its own configs
+	files := map[string]*declcfg.DeclarativeConfig{}
+	tr := tar.NewReader(bytes.NewReader(readBlob(manifest.Layers[0].Digest)))
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		loaded, err := declcfg.LoadReader(tr)
+		require.NoError(t, err)
+		files[h.Name] = loaded
+	}
+	require.Len(t, files, 2)
+	for _, pkg := range []string{"a", "b"} {
+		loaded := files[fmt.Sprintf("configs/%s/catalog.json", pkg)]
+		require.NotNil(t, loaded)
+		require.Equal(t, []declcfg.Package{{Schema: declcfg.SchemaPackage, Name: pkg}}, loaded.Packages)
+		require.Len(t, loaded.Channels, 1)
+		require.Equal(t, pkg, loaded.Channels[0].Package)
+	}
+}
@@ -0,0 +1,144 @@
+package semver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+// validatePackages ensures that a template of several packages declares its channel archetypes only within its
+// package blocks, that each block is named uniquely, and that it sets no attribute which applies to a single package
+func (sv *semverTemplate) validatePackages() error {
+	if len(sv.Packages) == 0 {
+		return nil
+	}
+	if len(sv.Candidate.Bundles) != 0 || len(sv.Fast.Bundles) != 0 || len(sv.Stable.Bundles) != 0 || len(sv.CustomChannels) != 0 || len(sv.Channels) != 0 {
+		return fmt.Errorf("readFile: template may declare either packages or channels, not both")
+	}
+	singlePackage := []string{}
+	if sv.DefaultChannelOverride != "" {
+		singlePackage = append(singlePackage, "defaultChannel")
+	}
+	if sv.PackageNameOverride != "" {
+		singlePackage = append(singlePackage, "packageNameOverride")
+	}
+	if len(sv.SkipVersions) != 0 {
+		singlePackage = append(singlePackage, "skipVersions")
+	}
+	if len(sv.Deprecations) != 0 {
+		singlePackage = append(singlePackage, "deprecations")
+	}
+	if len(sv.BundleProperties) != 0 {
+		singlePackage = append(singlePackage, "bundleProperties")
+	}
+	if len(singlePackage) != 0 {
+		return fmt.Errorf("readFile: %q cannot be set for a template of several packages", singlePackage)
+	}
+
+	names := sets.NewString()
+	for _, p := range sv.Packages {
+		if p.Name == "" {
+			return fmt.Errorf("readFile: packages must be named")
+		}
+		if names.Has(p.Name) {
+			return fmt.Errorf("readFile: package %q is declared more than once", p.Name)
+		}
+		names.Insert(p.Name)
+	}
+	return nil
+}
+
+// packageTemplate returns the template of a single package block: the block's channel archetypes, with the template's
+// other attributes
+func (sv *semverTemplate) packageTemplate(p semverTemplatePackage) *semverTemplate {
+	psv := *sv
+	psv.Packages = nil
+	psv.Candidate, psv.Fast, psv.Stable = p.Candidate, p.Fast, p.Stable
+	return &psv
+}
+
+// generatePackages generates each package block of the template independently, from its own bundles, and combines
+// them into a single catalog.  Each block's bundles must belong to the package it is named for.
+func (t Template) generatePackages(ctx context.Context, sv *semverTemplate, rendered *declcfg.DeclarativeConfig, variant *VariantSpec) (*declcfg.DeclarativeConfig, *RenderReport, error) {
+	if t.ExpectedPackage != "" {
+		return nil, nil, fmt.Errorf("render: an expected package cannot be set for a template of several packages")
+	}
+
+	out := &declcfg.DeclarativeConfig{}
+	report := &RenderReport{}
+	for _, p := range sv.Packages {
+		psv := sv.packageTemplate(p)
+		refs := sets.NewString()
+		for _, l := range psv.bundleLists() {
+			for _, e := range *l {
+				refs.Insert(e.ref())
+			}
+		}
+		bundles := &declcfg.DeclarativeConfig{}
+		for _, b := range rendered.Bundles {
+			if refs.Has(b.Image) {
+				bundles.Bundles = append(bundles.Bundles, b)
+			}
+		}
+
+		// the template-wide checks and report fields are handled once, for the combined catalog
+		pt := t
+		pt.ExpectedPackage = p.Name
+		pt.PreviousChannelNames = nil
+		pt.EmitRenderDurations = false
+		pkgOut, pkgReport, err := pt.generate(ctx, psv, cloneConfig(bundles), variant)
+		if err != nil {
+			return nil, nil, fmt.Errorf("package %q: %w", p.Name, err)
+		}
+		out.Packages = append(out.Packages, pkgOut.Packages...)
+		out.Channels = append(out.Channels, pkgOut.Channels...)
+		out.Bundles = append(out.Bundles, pkgOut.Bundles...)
+		out.Others = append(out.Others, pkgOut.Others...)
+		report.addPackage(p.Name, pkgReport)
+	}
+
+	if len(t.PreviousChannelNames) != 0 {
+		generated := sets.NewString()
+		for _, ch := range out.Channels {
+			generated.Insert(ch.Name)
+		}
+		for _, name := range sets.NewString(t.PreviousChannelNames...).Difference(generated).List() {
+			report.warnf("channel %q of the previous render is no longer generated", name)
+		}
+	}
+	if t.EmitRenderDurations {
+		report.RenderDurations = sv.renderDurations
+	}
+	return out, report, nil
+}
+
+// addPackage merges the report of a single package of a template of several packages.  Its warnings are prefixed with
+// the package name, as are its archetype and channel keys, since each package has channels of the same names.
+func (r *RenderReport) addPackage(pkg string, other *RenderReport) {
+	for _, w := range other.Warnings {
+		r.warnf("package %q: %s", pkg, w)
+	}
+	for bundle, channels := range other.Heads {
+		if r.Heads == nil {
+			r.Heads = make(map[string][]string)
+		}
+		r.Heads[bundle] = append(r.Heads[bundle], channels...)
+		sort.Strings(r.Heads[bundle])
+	}
+	for arch, summary := range other.Archetypes {
+		if r.Archetypes == nil {
+			r.Archetypes = make(map[string]ArchetypeSummary)
+		}
+		r.Archetypes[pkg+"/"+arch] = summary
+	}
+	for channel, predecessors := range other.Predecessors {
+		if r.Predecessors == nil {
+			r.Predecessors = make(map[string]map[string]string)
+		}
+		r.Predecessors[pkg+"/"+channel] = predecessors
+	}
+}
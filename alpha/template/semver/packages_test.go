@@ -0,0 +1,100 @@
+package semver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+func TestPackages(t *testing.T) {
+	a := testBundles("a", "1.0.0", "1.1.0")
+	b := testBundles("b", "0.1.0", "0.2.0")
+	data := fmt.Sprintf(`schema: olm.semver
+generateMajorChannels: true
+generateMinorChannels: false
+packages:
+- name: a
+  stable:
+    bundles:
+    - image: %s
+    - image: %s
+- name: b
+  candidate:
+    bundles:
+    - image: %s
+  fast:
+    bundles:
+    - image: %s
+`, a[0].image, a[1].image, b[0].image, b[1].image)
+	reg := newTestRegistry(append(a, b...)...)
+	out, report, err := Template{Data: strings.NewReader(data), Registry: reg, EmitPredecessors: true}.RenderWithReport(context.Background())
+	require.NoError(t, err)
+
+	require.Equal(t, []declcfg.Package{
+		{Schema: "olm.package", Name: "a", DefaultChannel: "stable-v1"},
+		{Schema: "olm.package", Name: "b", DefaultChannel: "fast-v0"},
+	}, out.Packages)
+	require.Equal(t, []declcfg.Channel{
+		{Schema: "olm.channel", Name: "stable-v1", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.0.0", Skips: []string{}},
+			{Name: "a.v1.1.0", Replaces: "a.v1.0.0", Skips: []string{}},
+		}},
+		{Schema: "olm.channel", Name: "candidate-v0", Package: "b", Entries: []declcfg.ChannelEntry{
+			{Name: "b.v0.1.0", Skips: []string{}},
+		}},
+		{Schema: "olm.channel", Name: "fast-v0", Package: "b", Entries: []declcfg.ChannelEntry{
+			{Name: "b.v0.2.0", Skips: []string{}},
+		}},
+	}, out.Channels)
+	names := []string{}
+	for _, bundle := range out.Bundles {
+		names = append(names, bundle.Name)
+	}
+	require.Equal(t, []string{"a.v1.0.0", "a.v1.1.0", "b.v0.1.0", "b.v0.2.0"}, names)
+	require.Contains(t, report.Archetypes, "a/stable")
+	require.Contains(t, report.Archetypes, "b/fast")
+	require.Contains(t, report.Predecessors, "a/stable-v1")
+
+	t.Run("bundles of another package", func(t *testing.T) {
+		data := fmt.Sprintf("schema: olm.semver\npackages:\n- name: a\n  stable:\n    bundles:\n    - image: %s\n", b[0].image)
+		_, err := Template{Data: strings.NewReader(data), Registry: reg}.Render(context.Background())
+		require.EqualError(t, err, `package "a": render: template bundles belong to package "b", expected package "a"`)
+	})
+
+	for _, tt := range []struct {
+		name     string
+		template string
+		err      string
+	}{
+		{
+			name:     "with channel archetypes",
+			template: fmt.Sprintf("stable:\n  bundles:\n  - image: %s\npackages:\n- name: a\n", a[0].image),
+			err:      "readFile: template may declare either packages or channels, not both",
+		},
+		{
+			name:     "single package attributes",
+			template: "defaultChannel: stable-v1\nskipVersions: [1.0.0]\npackages:\n- name: a\n",
+			err:      `readFile: ["defaultChannel" "skipVersions"] cannot be set for a template of several packages`,
+		},
+		{
+			name:     "unnamed",
+			template: "packages:\n- stable: {}\n",
+			err:      "readFile: packages must be named",
+		},
+		{
+			name:     "repeated",
+			template: "packages:\n- name: a\n- name: a\n",
+			err:      `readFile: package "a" is declared more than once`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Template{}.readFile(strings.NewReader("schema: olm.semver\n" + tt.template))
+			require.EqualError(t, err, tt.err)
+		})
+	}
+}
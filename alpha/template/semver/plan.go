@@ -0,0 +1,81 @@
+package semver
+
+import (
+	"context"
+	"sort"
+)
+
+// Plan summarizes the channel topology a template renders to for a package, for review before publishing
+type Plan struct {
+	Package        string        `json:"package"`
+	DefaultChannel string        `json:"defaultChannel"`
+	Channels       []PlanChannel `json:"channels"`
+}
+
+// PlanChannel is a generated channel, with its entries ordered by ascending version
+type PlanChannel struct {
+	Name    string      `json:"name"`
+	Head    string      `json:"head"`
+	Entries []PlanEntry `json:"entries"`
+}
+
+// PlanEntry is a channel entry and its upgrade edges
+type PlanEntry struct {
+	Bundle    string   `json:"bundle"`
+	Version   string   `json:"version"`
+	Replaces  string   `json:"replaces,omitempty"`
+	Skips     []string `json:"skips,omitempty"`
+	SkipRange string   `json:"skipRange,omitempty"`
+}
+
+// RenderPlan renders the template and returns a summary of each package's channels, their heads and edges, and its
+// default channel, in a deterministic order suited to diffing.  It is not a dry run: the template is fully rendered,
+// with every bundle image pulled and unpacked, since bundle names and versions are read from their CSVs.  The summary
+// merely leaves out the bundles' contents.  Like Render, it consumes Data.
+func (t Template) RenderPlan(ctx context.Context) ([]Plan, error) {
+	out, err := t.Render(ctx)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := versionsByName(out)
+	if err != nil {
+		return nil, err
+	}
+
+	plans := make([]Plan, 0, len(out.Packages))
+	for _, pkg := range out.Packages {
+		plan := Plan{
+			Package:        pkg.Name,
+			DefaultChannel: pkg.DefaultChannel,
+			Channels:       []PlanChannel{},
+		}
+		for i := range out.Channels {
+			ch := &out.Channels[i]
+			if ch.Package != pkg.Name {
+				continue
+			}
+			head, err := channelHead(ch)
+			if err != nil {
+				return nil, err
+			}
+			pc := PlanChannel{Name: ch.Name, Head: head, Entries: make([]PlanEntry, 0, len(ch.Entries))}
+			for _, e := range ch.Entries {
+				pc.Entries = append(pc.Entries, PlanEntry{
+					Bundle:    e.Name,
+					Version:   versions[e.Name].String(),
+					Replaces:  e.Replaces,
+					Skips:     append([]string(nil), e.Skips...),
+					SkipRange: e.SkipRange,
+				})
+			}
+			sort.SliceStable(pc.Entries, func(i, j int) bool {
+				return versions[pc.Entries[i].Bundle].LT(versions[pc.Entries[j].Bundle])
+			})
+			plan.Channels = append(plan.Channels, pc)
+		}
+		sort.Slice(plan.Channels, func(i, j int) bool { return plan.Channels[i].Name < plan.Channels[j].Name })
+		plans = append(plans, plan)
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].Package < plans[j].Package })
+	return plans, nil
+}
@@ -0,0 +1,68 @@
+package semver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlan(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.0.1", "1.1.0")
+	data := "schema: olm.semver\ngenerateMajorChannels: true\ngenerateMinorChannels: true\nstable:\n  bundles:\n"
+	for _, b := range bundles {
+		data += fmt.Sprintf("  - image: %s\n", b.image)
+	}
+
+	plan, err := Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}.RenderPlan(context.Background())
+	require.NoError(t, err)
+	actual, err := json.Marshal(plan)
+	require.NoError(t, err)
+	require.JSONEq(t, `[{
+		"package": "a",
+		"defaultChannel": "stable-v1.1",
+		"channels": [
+			{"name": "stable-v1", "head": "a.v1.1.0", "entries": [
+				{"bundle": "a.v1.0.0", "version": "1.0.0"},
+				{"bundle": "a.v1.0.1", "version": "1.0.1", "skips": ["a.v1.0.0"]},
+				{"bundle": "a.v1.1.0", "version": "1.1.0", "replaces": "a.v1.0.1", "skips": ["a.v1.0.0"]}
+			]},
+			{"name": "stable-v1.0", "head": "a.v1.0.1", "entries": [
+				{"bundle": "a.v1.0.0", "version": "1.0.0"},
+				{"bundle": "a.v1.0.1", "version": "1.0.1", "skips": ["a.v1.0.0"]}
+			]},
+			{"name": "stable-v1.1", "head": "a.v1.1.0", "entries": [
+				{"bundle": "a.v1.1.0", "version": "1.1.0", "replaces": "a.v1.0.1", "skips": ["a.v1.0.0"]}
+			]}
+		]
+	}]`, string(actual))
+}
+
+func TestPlanPackages(t *testing.T) {
+	a := testBundles("a", "1.0.0", "1.1.0")
+	b := testBundles("b", "0.1.0")
+	data := fmt.Sprintf("schema: olm.semver\ngenerateMinorChannels: false\ngenerateMajorChannels: true\npackages:\n- name: b\n  fast:\n    bundles:\n    - image: %s\n- name: a\n  stable:\n    bundles:\n    - image: %s\n    - image: %s\n",
+		b[0].image, a[0].image, a[1].image)
+
+	plans, err := Template{Data: strings.NewReader(data), Registry: newTestRegistry(append(a, b...)...)}.RenderPlan(context.Background())
+	require.NoError(t, err)
+	actual, err := json.Marshal(plans)
+	require.NoError(t, err)
+	// each package is planned with only its own channels
+	require.JSONEq(t, `[
+		{"package": "a", "defaultChannel": "stable-v1", "channels": [
+			{"name": "stable-v1", "head": "a.v1.1.0", "entries": [
+				{"bundle": "a.v1.0.0", "version": "1.0.0"},
+				{"bundle": "a.v1.1.0", "version": "1.1.0", "replaces": "a.v1.0.0"}
+			]}
+		]},
+		{"package": "b", "defaultChannel": "fast-v0", "channels": [
+			{"name": "fast-v0", "head": "b.v0.1.0", "entries": [
+				{"bundle": "b.v0.1.0", "version": "0.1.0"}
+			]}
+		]}
+	]`, string(actual))
+}
@@ -0,0 +1,68 @@
+package semver
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+const (
+	osLabelPrefix   = "operatorframework.io/os."
+	archLabelPrefix = "operatorframework.io/arch."
+	supportedLabel  = "supported"
+)
+
+// platform is an os/arch pair which a bundle may support
+type platform struct {
+	os   string
+	arch string
+}
+
+func parsePlatform(s string) (*platform, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid platform %q, expected <os>/<arch>", s)
+	}
+	return &platform{os: parts[0], arch: parts[1]}, nil
+}
+
+func (p platform) String() string {
+	return p.os + "/" + p.arch
+}
+
+// supportedBy reports whether the bundle's CSV labels declare support for the platform.  As in OLM, a CSV without any
+// os labels supports only linux, and one without any arch labels supports only amd64.
+func (p platform) supportedBy(b declcfg.Bundle) (bool, error) {
+	var csv struct {
+		Metadata struct {
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}
+	if b.CsvJSON != "" {
+		if err := json.Unmarshal([]byte(b.CsvJSON), &csv); err != nil {
+			return false, fmt.Errorf("parse CSV for bundle %q: %v", b.Name, err)
+		}
+	}
+
+	oses, archs := map[string]bool{}, map[string]bool{}
+	for k, v := range csv.Metadata.Labels {
+		if v != supportedLabel {
+			continue
+		}
+		if strings.HasPrefix(k, osLabelPrefix) {
+			oses[strings.TrimPrefix(k, osLabelPrefix)] = true
+		}
+		if strings.HasPrefix(k, archLabelPrefix) {
+			archs[strings.TrimPrefix(k, archLabelPrefix)] = true
+		}
+	}
+	if len(oses) == 0 {
+		oses["linux"] = true
+	}
+	if len(archs) == 0 {
+		archs["amd64"] = true
+	}
+	return oses[p.os] && archs[p.arch], nil
+}
@@ -0,0 +1,55 @@
+package semver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+func TestPlatform(t *testing.T) {
+	multiArch := map[string]string{
+		"operatorframework.io/arch.amd64": "supported",
+		"operatorframework.io/arch.arm64": "supported",
+	}
+	bundles := []testBundle{
+		{image: testImage("a", "1.0.0"), pkg: "a", version: "1.0.0", labels: multiArch},
+		{image: testImage("a", "1.1.0"), pkg: "a", version: "1.1.0"},
+		{image: testImage("a", "1.2.0"), pkg: "a", version: "1.2.0", labels: multiArch},
+	}
+	newTemplate := func(platform string) Template {
+		data := "---\nschema: olm.semver\ngenerateMajorChannels: true\ngenerateMinorChannels: false\nstable:\n  bundles:\n"
+		for _, b := range bundles {
+			data += fmt.Sprintf("  - image: %s\n", b.image)
+		}
+		return Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...), Platform: platform}
+	}
+
+	out, err := newTemplate("linux/arm64").Render(context.Background())
+	require.NoError(t, err)
+	require.Len(t, out.Bundles, 2)
+	require.Equal(t, []declcfg.Channel{{
+		Schema:  "olm.channel",
+		Name:    "stable-v1",
+		Package: "a",
+		Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.0.0", Skips: []string{}},
+			{Name: "a.v1.2.0", Replaces: "a.v1.0.0", Skips: []string{}},
+		},
+	}}, out.Channels)
+
+	// bundles without arch labels support amd64
+	out, err = newTemplate("linux/amd64").Render(context.Background())
+	require.NoError(t, err)
+	require.Len(t, out.Bundles, 3)
+
+	_, err = newTemplate("linux/s390x").Render(context.Background())
+	require.EqualError(t, err, `render: no bundles support platform "linux/s390x"`)
+
+	_, err = newTemplate("arm64").Render(context.Background())
+	require.EqualError(t, err, `render: invalid platform "arm64", expected <os>/<arch>`)
+}
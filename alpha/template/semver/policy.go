@@ -0,0 +1,102 @@
+package semver
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/blang/semver/v4"
+	"github.com/docker/distribution/reference"
+)
+
+// Policy declares organizational rules which a template must satisfy in order to render
+type Policy struct {
+	// DigestPinnedArchetypes lists the channel archetypes (or declared channels) whose bundle images must be
+	// referenced by digest
+	DigestPinnedArchetypes []string `json:"digestPinnedArchetypes,omitempty"`
+	// NoPrereleaseArchetypes lists the channel archetypes (or declared channels) which may not contain prerelease versions
+	NoPrereleaseArchetypes []string `json:"noPrereleaseArchetypes,omitempty"`
+	// MaxVersion, when set, is the highest bundle version which may be rendered
+	MaxVersion *semver.Version `json:"maxVersion,omitempty"`
+	// RequiredMemberships maps a channel archetype (or declared channel) to the archetypes which must also contain each
+	// of its bundles, e.g. {"stable": ["fast"]} to require that stable bundles were released to fast
+	RequiredMemberships map[string][]string `json:"requiredMemberships,omitempty"`
+}
+
+// ApplyPolicy configures the template to enforce the policy when rendering
+func (t *Template) ApplyPolicy(policy Policy) {
+	t.Policy = &policy
+}
+
+// enforce checks the template's bundles against the policy, returning every violation
+func (p *Policy) enforce(sv *semverTemplate, versions *bundleVersions) error {
+	violations := []string{}
+
+	for _, arch := range p.DigestPinnedArchetypes {
+		for _, entry := range sv.channelBundles(channelArchetype(arch)).Bundles {
+			// bundles rendered from files and OCI layouts are local content, which has no image reference to pin
+			if entry.Image == "" {
+				continue
+			}
+			ref, err := reference.ParseNormalizedNamed(entry.ref())
+			if err != nil {
+				violations = append(violations, fmt.Sprintf("%s bundle image %q is not a valid reference: %v", arch, entry.ref(), err))
+				continue
+			}
+			if _, ok := ref.(reference.Digested); !ok {
+				violations = append(violations, fmt.Sprintf("%s bundle image %q is not pinned by digest", arch, entry.ref()))
+			}
+		}
+	}
+
+	for _, arch := range p.NoPrereleaseArchetypes {
+		for _, name := range sortedBundleNames((*versions)[channelArchetype(arch)]) {
+			if v := (*versions)[channelArchetype(arch)][name]; len(v.Pre) != 0 {
+				violations = append(violations, fmt.Sprintf("%s bundle %q has prerelease version %q", arch, name, v))
+			}
+		}
+	}
+
+	if p.MaxVersion != nil {
+		exceeding := map[string]semver.Version{}
+		for _, bundles := range *versions {
+			for name, v := range bundles {
+				if v.GT(*p.MaxVersion) {
+					exceeding[name] = v
+				}
+			}
+		}
+		for _, name := range sortedBundleNames(exceeding) {
+			violations = append(violations, fmt.Sprintf("bundle %q version %q exceeds the maximum version %q", name, exceeding[name], p.MaxVersion))
+		}
+	}
+
+	archs := make([]string, 0, len(p.RequiredMemberships))
+	for arch := range p.RequiredMemberships {
+		archs = append(archs, arch)
+	}
+	sort.Strings(archs)
+	for _, arch := range archs {
+		for _, name := range sortedBundleNames((*versions)[channelArchetype(arch)]) {
+			for _, required := range p.RequiredMemberships[arch] {
+				if _, ok := (*versions)[channelArchetype(required)][name]; !ok {
+					violations = append(violations, fmt.Sprintf("%s bundle %q is not also in %s", arch, name, required))
+				}
+			}
+		}
+	}
+
+	if len(violations) != 0 {
+		return &ErrPolicyViolation{Violations: violations}
+	}
+	return nil
+}
+
+// sortedBundleNames returns the bundle names of a version map in lexical order
+func sortedBundleNames(bundles map[string]semver.Version) []string {
+	names := make([]string, 0, len(bundles))
+	for name := range bundles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,104 @@
+package semver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.1.0-rc.1", "2.0.0")
+	newTemplate := func(policy Policy) Template {
+		tmpl := Template{
+			Data: strings.NewReader(fmt.Sprintf(`---
+schema: olm.semver
+candidate:
+  bundles:
+  - image: %s
+  - image: %s
+  - image: %s
+stable:
+  bundles:
+  - image: %s
+  - image: %s
+`, bundles[0].image, bundles[1].image, bundles[2].image, bundles[0].image, bundles[1].image)),
+			Registry: newTestRegistry(bundles...),
+		}
+		tmpl.ApplyPolicy(policy)
+		return tmpl
+	}
+
+	tests := []struct {
+		name   string
+		policy Policy
+		err    string
+	}{
+		{
+			name:   "no prereleases in candidate or stable",
+			policy: Policy{NoPrereleaseArchetypes: []string{"candidate", "stable"}},
+			err:    `render: policy violations: [candidate bundle "a.v1.1.0-rc.1" has prerelease version "1.1.0-rc.1", stable bundle "a.v1.1.0-rc.1" has prerelease version "1.1.0-rc.1"]`,
+		},
+		{
+			name:   "no prereleases in stable",
+			policy: Policy{NoPrereleaseArchetypes: []string{"stable"}},
+			err:    `render: policy violations: [stable bundle "a.v1.1.0-rc.1" has prerelease version "1.1.0-rc.1"]`,
+		},
+		{
+			name:   "digest pinning",
+			policy: Policy{DigestPinnedArchetypes: []string{"stable"}},
+			err:    fmt.Sprintf(`render: policy violations: [stable bundle image %q is not pinned by digest, stable bundle image %q is not pinned by digest]`, bundles[0].image, bundles[1].image),
+		},
+		{
+			name:   "version cap",
+			policy: Policy{MaxVersion: &semver.Version{Major: 1, Minor: 99}},
+			err:    `render: policy violations: [bundle "a.v2.0.0" version "2.0.0" exceeds the maximum version "1.99.0"]`,
+		},
+		{
+			name:   "stable bundles must be in fast",
+			policy: Policy{RequiredMemberships: map[string][]string{"stable": {"candidate", "fast"}}},
+			err:    `render: policy violations: [stable bundle "a.v1.0.0" is not also in fast, stable bundle "a.v1.1.0-rc.1" is not also in fast]`,
+		},
+		{
+			name:   "stable bundles must be in candidate",
+			policy: Policy{RequiredMemberships: map[string][]string{"stable": {"candidate"}}},
+		},
+		{
+			name:   "satisfied",
+			policy: Policy{NoPrereleaseArchetypes: []string{"fast"}, MaxVersion: &semver.Version{Major: 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTemplate(tt.policy).Render(context.Background())
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.err)
+			code, ok := ErrorCodeOf(err)
+			require.True(t, ok)
+			require.Equal(t, CodePolicyViolation, code)
+		})
+	}
+}
+
+func TestPolicyDigestPinningSkipsFiles(t *testing.T) {
+	bundles := testBundles("a", "1.0.0")
+	dir := writeTestBundleDir(t, testBundle{pkg: "a", version: "1.1.0"})
+	tmpl := Template{
+		Data:     strings.NewReader(fmt.Sprintf("schema: olm.semver\nstable:\n  bundles:\n  - image: %s\n  - file: %s\n", bundles[0].image, dir)),
+		Registry: newTestRegistry(bundles...),
+	}
+	tmpl.ApplyPolicy(Policy{DigestPinnedArchetypes: []string{"stable"}})
+
+	// only the image entry has a reference to pin, and Validate enforces the policy like Render
+	err := tmpl.Validate(context.Background())
+	require.EqualError(t, err, fmt.Sprintf(`render: policy violations: [stable bundle image %q is not pinned by digest]`, bundles[0].image))
+	code, ok := ErrorCodeOf(err)
+	require.True(t, ok)
+	require.Equal(t, CodePolicyViolation, code)
+}
@@ -0,0 +1,70 @@
+package semver
+
+import (
+	"fmt"
+
+	"github.com/blang/semver/v4"
+	"k8s.io/apimachinery/pkg/util/errors"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/property"
+)
+
+// addBundleProperties attaches the template's bundle properties to the output bundles whose versions satisfy their
+// ranges.  Every range must match at least one output bundle, and a property the bundle already has is not repeated.
+func (sv *semverTemplate) addBundleProperties(out *declcfg.DeclarativeConfig, versions *bundleVersions) error {
+	bundleVersion := make(map[string]semver.Version)
+	for _, bundles := range *versions {
+		for name, v := range bundles {
+			bundleVersion[name] = v
+		}
+	}
+
+	added := make(map[string][]property.Property)
+	errs := []error{}
+	for _, bp := range sv.BundleProperties {
+		r, err := semver.ParseRange(bp.Versions)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid versions %q: %v", bp.Versions, err))
+			continue
+		}
+		if len(bp.Properties) == 0 {
+			errs = append(errs, fmt.Errorf("versions %q have no properties", bp.Versions))
+			continue
+		}
+		valid := true
+		for _, p := range bp.Properties {
+			if err := p.Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("invalid property of versions %q: %v", bp.Versions, err))
+				valid = false
+			} else if p.Type == property.TypePackage {
+				errs = append(errs, fmt.Errorf("versions %q cannot set the bundles' %q property", bp.Versions, property.TypePackage))
+				valid = false
+			}
+		}
+		if !valid {
+			continue
+		}
+
+		matched := false
+		for _, b := range out.Bundles {
+			if v, ok := bundleVersion[b.Name]; ok && r(v) {
+				matched = true
+				added[b.Name] = append(added[b.Name], bp.Properties...)
+			}
+		}
+		if !matched {
+			errs = append(errs, fmt.Errorf("versions %q match no bundle in the output", bp.Versions))
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid bundle properties: %v", errors.NewAggregate(errs))
+	}
+
+	for i := range out.Bundles {
+		if props, ok := added[out.Bundles[i].Name]; ok {
+			out.Bundles[i].Properties = property.Deduplicate(append(out.Bundles[i].Properties, props...))
+		}
+	}
+	return nil
+}
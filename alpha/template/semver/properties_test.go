@@ -0,0 +1,87 @@
+package semver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/property"
+)
+
+func TestBundleProperties(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.1.0", "2.0.0")
+	render := func(bundleProperties string) (*declcfg.DeclarativeConfig, error) {
+		data := "schema: olm.semver\nbundleProperties:\n" + bundleProperties + "stable:\n  bundles:\n"
+		for _, b := range bundles {
+			data += fmt.Sprintf("  - image: %s\n", b.image)
+		}
+		return Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+	}
+	others := func(b declcfg.Bundle) []property.Property {
+		props, err := property.Parse(b.Properties)
+		require.NoError(t, err)
+		return props.Others
+	}
+
+	out, err := render(`- versions: "<2.0.0"
+  properties:
+  - type: example.com/support
+    value: {tier: extended}
+- versions: ">=1.1.0"
+  properties:
+  - type: example.com/support
+    value: {tier: extended}
+  - type: example.com/channel
+    value: fast
+`)
+	require.NoError(t, err)
+	support := property.Property{Type: "example.com/support", Value: json.RawMessage(`{"tier":"extended"}`)}
+	fast := property.Property{Type: "example.com/channel", Value: json.RawMessage(`"fast"`)}
+	expected := map[string][]property.Property{
+		"a.v1.0.0": {support},
+		// identical properties are not repeated
+		"a.v1.1.0": {support, fast},
+		"a.v2.0.0": {support, fast},
+	}
+	require.Len(t, out.Bundles, 3)
+	for _, b := range out.Bundles {
+		require.Equal(t, expected[b.Name], others(b), b.Name)
+	}
+
+	for _, tt := range []struct {
+		name             string
+		bundleProperties string
+		err              string
+	}{
+		{
+			name:             "unmatched versions",
+			bundleProperties: "- versions: \">=3.0.0\"\n  properties:\n  - {type: example.com/support, value: {}}\n",
+			err:              `render: invalid bundle properties: versions ">=3.0.0" match no bundle in the output`,
+		},
+		{
+			name:             "no properties",
+			bundleProperties: "- versions: \">=1.0.0\"\n",
+			err:              `render: invalid bundle properties: versions ">=1.0.0" have no properties`,
+		},
+		{
+			name:             "package property",
+			bundleProperties: "- versions: \">=1.0.0\"\n  properties:\n  - {type: olm.package, value: {packageName: b, version: 1.0.0}}\n",
+			err:              `render: invalid bundle properties: versions ">=1.0.0" cannot set the bundles' "olm.package" property`,
+		},
+		{
+			name:             "untyped property",
+			bundleProperties: "- versions: \">=1.0.0\"\n  properties:\n  - {value: {}}\n",
+			err:              `render: invalid bundle properties: invalid property of versions ">=1.0.0": type must be set`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := render(tt.bundleProperties)
+			require.EqualError(t, err, tt.err)
+		})
+	}
+}
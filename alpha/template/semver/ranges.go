@@ -0,0 +1,110 @@
+package semver
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/blang/semver/v4"
+	"k8s.io/apimachinery/pkg/util/errors"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+// resolveRanges replaces each range entry of the template's bundle lists with an entry for the image of every bundle of
+// its package in catalog whose version satisfies its range, in order of increasing version.  Images which the list
+// already contains are not repeated.  Every range must match at least one bundle of the catalog.
+func (sv *semverTemplate) resolveRanges(catalog *declcfg.DeclarativeConfig) error {
+	errs := []error{}
+	for _, l := range sv.bundleLists() {
+		hasRange := false
+		listed := make(map[string]bool)
+		for _, e := range *l {
+			hasRange = hasRange || e.Range != ""
+			if e.Range == "" {
+				listed[e.ref()] = true
+			}
+		}
+		if !hasRange {
+			continue
+		}
+
+		resolved := make([]semverTemplateBundleEntry, 0, len(*l))
+		for _, e := range *l {
+			if e.Range == "" {
+				resolved = append(resolved, e)
+				continue
+			}
+			images, err := resolveRange(e, catalog)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			for _, image := range images {
+				if !listed[image] {
+					listed[image] = true
+					resolved = append(resolved, semverTemplateBundleEntry{Image: image})
+				}
+			}
+		}
+		*l = resolved
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid bundle ranges: %v", errors.NewAggregate(errs))
+	}
+	return nil
+}
+
+// resolveRange returns the images of the bundles of the range entry's package in catalog whose versions satisfy its
+// range, in order of increasing version
+func resolveRange(e semverTemplateBundleEntry, catalog *declcfg.DeclarativeConfig) ([]string, error) {
+	switch {
+	case e.Image != "" || e.File != "" || e.OCILayout != "":
+		return nil, fmt.Errorf("range %q cannot be combined with an image, a file, or an OCI layout", e.Range)
+	case e.Head || e.PreviewHead || e.Ordinal != nil || len(e.TestedFrom) != 0:
+		return nil, fmt.Errorf("range %q cannot set attributes of a single bundle", e.Range)
+	case e.Package == "":
+		return nil, fmt.Errorf("range %q does not name a package", e.Range)
+	case catalog == nil:
+		return nil, fmt.Errorf("range %q cannot be resolved without a catalog", e.Range)
+	}
+	r, err := semver.ParseRange(e.Range)
+	if err != nil {
+		return nil, fmt.Errorf("invalid range %q: %v", e.Range, err)
+	}
+
+	type match struct {
+		image   string
+		version semver.Version
+	}
+	matches := []match{}
+	for _, b := range catalog.Bundles {
+		if b.Package != e.Package {
+			continue
+		}
+		pkg, err := bundlePackage(b)
+		if err != nil {
+			return nil, err
+		}
+		v, err := semver.Parse(pkg.Version)
+		if err != nil {
+			return nil, fmt.Errorf("bundle %q has invalid version %q: %v", b.Name, pkg.Version, err)
+		}
+		if !r(v) {
+			continue
+		}
+		if b.Image == "" {
+			return nil, fmt.Errorf("bundle %q matches range %q, but has no image", b.Name, e.Range)
+		}
+		matches = append(matches, match{image: b.Image, version: v})
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("range %q matches no bundle of package %q in the catalog", e.Range, e.Package)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].version.LT(matches[j].version) })
+	images := make([]string, 0, len(matches))
+	for _, m := range matches {
+		images = append(images, m.image)
+	}
+	return images, nil
+}
@@ -0,0 +1,98 @@
+package semver
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/property"
+)
+
+func TestBundleRanges(t *testing.T) {
+	bundles := testBundles("a", "0.9.0", "1.0.0", "1.1.0", "2.0.0")
+	catalog := &declcfg.DeclarativeConfig{}
+	// listed out of order, to be resolved in order of increasing version
+	for _, i := range []int{3, 2, 0, 1} {
+		b := bundles[i]
+		catalog.Bundles = append(catalog.Bundles, declcfg.Bundle{
+			Schema:     "olm.bundle",
+			Name:       "a.v" + b.version,
+			Package:    b.pkg,
+			Image:      b.image,
+			Properties: []property.Property{property.MustBuildPackage(b.pkg, b.version)},
+		})
+	}
+	catalog.Bundles = append(catalog.Bundles, declcfg.Bundle{
+		Schema:     "olm.bundle",
+		Name:       "b.v1.0.0",
+		Package:    "b",
+		Image:      testImage("b", "1.0.0"),
+		Properties: []property.Property{property.MustBuildPackage("b", "1.0.0")},
+	})
+	render := func(stable string, catalog *declcfg.DeclarativeConfig) (*declcfg.DeclarativeConfig, error) {
+		data := "schema: olm.semver\ngenerateMajorChannels: true\ngenerateMinorChannels: false\nstable:\n  bundles:\n" + stable
+		return Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...), Catalog: catalog}.Render(context.Background())
+	}
+
+	out, err := render(`  - range: ">=1.0.0 <2.0.0"
+    package: a
+  - image: `+bundles[3].image+`
+`, catalog)
+	require.NoError(t, err)
+	names := []string{}
+	for _, b := range out.Bundles {
+		names = append(names, b.Name)
+	}
+	require.ElementsMatch(t, []string{"a.v1.0.0", "a.v1.1.0", "a.v2.0.0"}, names)
+	require.Equal(t, []declcfg.Channel{
+		{Schema: "olm.channel", Name: "stable-v1", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.0.0", Skips: []string{}},
+			{Name: "a.v1.1.0", Replaces: "a.v1.0.0", Skips: []string{}},
+		}},
+		{Schema: "olm.channel", Name: "stable-v2", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v2.0.0", Skips: []string{}},
+		}},
+	}, out.Channels)
+
+	// an image selected by a range and also listed is rendered once
+	out, err = render(`  - image: `+bundles[1].image+`
+  - range: ">=1.0.0 <2.0.0"
+    package: a
+`, catalog)
+	require.NoError(t, err)
+	require.Len(t, out.Bundles, 2)
+
+	_, err = render(`  - range: ">=3.0.0"
+    package: a
+`, catalog)
+	require.ErrorContains(t, err, `render: invalid bundle ranges: range ">=3.0.0" matches no bundle of package "a" in the catalog`)
+
+	_, err = render(`  - range: ">=1.0.0"
+    package: a
+`, nil)
+	require.ErrorContains(t, err, `range ">=1.0.0" cannot be resolved without a catalog`)
+
+	_, err = render(`  - range: ">=1.0.0"
+`, catalog)
+	require.ErrorContains(t, err, `range ">=1.0.0" does not name a package`)
+
+	_, err = render(`  - range: ">=1.0.0"
+    package: a
+    image: `+bundles[1].image+`
+`, catalog)
+	require.ErrorContains(t, err, `range ">=1.0.0" cannot be combined with an image, a file, or an OCI layout`)
+
+	_, err = render(`  - range: ">=1.0.0"
+    package: a
+    head: true
+`, catalog)
+	require.ErrorContains(t, err, `range ">=1.0.0" cannot set attributes of a single bundle`)
+
+	_, err = render(`  - range: "not a range"
+    package: a
+`, catalog)
+	require.ErrorContains(t, err, `invalid range "not a range"`)
+}
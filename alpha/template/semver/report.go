@@ -0,0 +1,336 @@
+package semver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+// RenderReport summarizes the outcome of rendering a semver template, for review and debugging
+type RenderReport struct {
+	// Heads maps each bundle name to the (sorted) names of the channels it heads
+	Heads map[string][]string `json:"heads,omitempty"`
+	// Warnings are informational findings which did not prevent rendering
+	Warnings []string `json:"warnings,omitempty"`
+	// Archetypes summarizes the bundles and generated channels of each non-empty channel archetype (or declared channel)
+	Archetypes map[string]ArchetypeSummary `json:"archetypes,omitempty"`
+	// Predecessors maps each channel name to a map of bundle version --> the version it replaces, for upgrade systems
+	// which track a single previous version rather than replaces/skips.  Only populated when requested.
+	Predecessors map[string]map[string]string `json:"predecessors,omitempty"`
+	// Unrendered lists the bundle images skipped by RenderWithDeadline because they did not render in time
+	Unrendered []string `json:"unrendered,omitempty"`
+	// RenderDurations lists the time taken to render each bundle image, slowest first.  Only populated when requested.
+	RenderDurations []BundleRenderDuration `json:"renderDurations,omitempty"`
+}
+
+// BundleRenderDuration is the wall-clock time taken to render a single bundle image
+type BundleRenderDuration struct {
+	Image    string        `json:"image"`
+	Duration time.Duration `json:"duration"`
+}
+
+// ArchetypeSummary describes the shape of the catalog generated for a single channel archetype
+type ArchetypeSummary struct {
+	Bundles        int    `json:"bundles"`
+	LowestVersion  string `json:"lowestVersion"`
+	HighestVersion string `json:"highestVersion"`
+	// Channels counts every output channel generated for the archetype, including classified and declared channels,
+	// of which MinorChannels and MajorChannels are the minor and major channels
+	Channels      int `json:"channels"`
+	MinorChannels int `json:"minorChannels"`
+	MajorChannels int `json:"majorChannels"`
+}
+
+// recordChannelOrigin records the archetype and kind a channel is created for, for the report
+func (sv *semverTemplate) recordChannelOrigin(name string, arch channelArchetype, kind streamType) {
+	if sv.channelOrigins == nil {
+		sv.channelOrigins = make(map[string]channelOrigin)
+	}
+	sv.channelOrigins[name] = channelOrigin{archetype: arch, kind: kind}
+}
+
+// archetypeSummaries summarizes each non-empty channel archetype's bundles, and the channels of the output which were
+// generated from them
+func (sv *semverTemplate) archetypeSummaries(versions *bundleVersions, channels []declcfg.Channel) map[string]ArchetypeSummary {
+	summaries := make(map[string]ArchetypeSummary)
+	for arch, bundles := range *versions {
+		if len(bundles) == 0 {
+			continue
+		}
+		var lowest, highest *semver.Version
+		for _, v := range bundles {
+			v := v
+			if lowest == nil || v.LT(*lowest) {
+				lowest = &v
+			}
+			if highest == nil || v.GT(*highest) {
+				highest = &v
+			}
+		}
+		summaries[string(arch)] = ArchetypeSummary{
+			Bundles:        len(bundles),
+			LowestVersion:  lowest.String(),
+			HighestVersion: highest.String(),
+		}
+	}
+
+	// channels which were filtered out, or renamed by the ChannelMutator, are not counted
+	for _, ch := range channels {
+		origin, ok := sv.channelOrigins[ch.Name]
+		if !ok {
+			continue
+		}
+		summary, ok := summaries[string(origin.archetype)]
+		if !ok {
+			continue
+		}
+		summary.Channels++
+		switch origin.kind {
+		case minorStreamType:
+			summary.MinorChannels++
+		case majorStreamType:
+			summary.MajorChannels++
+		}
+		summaries[string(origin.archetype)] = summary
+	}
+	return summaries
+}
+
+// warnf records a formatted warning in the report
+func (r *RenderReport) warnf(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// buildMetadataWarnings reports each bundle whose version carries build metadata
+func buildMetadataWarnings(versions *bundleVersions, report *RenderReport) {
+	withBuild := make(map[string]semver.Version)
+	for _, bundles := range *versions {
+		for name, v := range bundles {
+			if len(v.Build) > 0 {
+				withBuild[name] = v
+			}
+		}
+	}
+	names := make([]string, 0, len(withBuild))
+	for name := range withBuild {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		report.warnf("bundle %q version %q carries build metadata, which is ignored when ordering versions", name, withBuild[name].String())
+	}
+}
+
+// repeatedImageWarnings reports each bundle reference which the template lists under more than one channel archetype
+// (or declared channel), naming them
+func (sv *semverTemplate) repeatedImageWarnings(report *RenderReport) {
+	listings := make(map[string][]string)
+	for _, arch := range sv.templateChannels() {
+		for _, e := range sv.channelBundles(arch).Bundles {
+			listings[e.ref()] = append(listings[e.ref()], string(arch))
+		}
+	}
+	refs := make([]string, 0, len(listings))
+	for ref, archs := range listings {
+		if len(archs) > 1 {
+			refs = append(refs, ref)
+		}
+	}
+	sort.Strings(refs)
+	for _, ref := range refs {
+		report.warnf("bundle image %q is listed under %s; it is rendered once, with a single version", ref, strings.Join(listings[ref], ", "))
+	}
+}
+
+// longSkipsWarnings returns a warning for each channel entry which skips more than threshold bundles, as Z-streams
+// accumulate in the skips of a channel head
+func longSkipsWarnings(channels []declcfg.Channel, threshold int) []string {
+	warnings := []string{}
+	for _, ch := range channels {
+		for _, e := range ch.Entries {
+			if len(e.Skips) > threshold {
+				warnings = append(warnings, fmt.Sprintf("channel %q entry %q skips %d bundles, more than %d; consider setting generateSkipRange", ch.Name, e.Name, len(e.Skips), threshold))
+			}
+		}
+	}
+	return warnings
+}
+
+// identicalArchetypeWarnings reports each pair of adjacent channel archetypes which contain exactly the same bundles,
+// which may indicate that bundles were not promoted to the more stable archetype
+func identicalArchetypeWarnings(versions *bundleVersions, report *RenderReport) {
+	adjacent := [][2]channelArchetype{
+		{candidateChannelArchetype, fastChannelArchetype},
+		{fastChannelArchetype, stableChannelArchetype},
+	}
+	for _, pair := range adjacent {
+		less, more := (*versions)[pair[0]], (*versions)[pair[1]]
+		if len(less) == 0 || len(less) != len(more) {
+			continue
+		}
+		identical := true
+		for name := range less {
+			if _, ok := more[name]; !ok {
+				identical = false
+				break
+			}
+		}
+		if identical {
+			report.warnf("%s and %s channels contain the same %d bundles; consider whether they should be promoted", pair[0], pair[1], len(less))
+		}
+	}
+}
+
+// promotionWarnings reports prerelease versions which reached the stable archetype, and, when the template uses the
+// candidate or fast archetypes at all, stable release versions which were released to neither of them in any form
+func promotionWarnings(versions *bundleVersions, report *RenderReport) {
+	stable := (*versions)[stableChannelArchetype]
+	for _, name := range sortedBundleNames(stable) {
+		if v := stable[name]; len(v.Pre) != 0 {
+			report.warnf("stable bundle %q has prerelease version %q", name, v)
+		}
+	}
+
+	released := sets.NewString()
+	for _, arch := range []channelArchetype{candidateChannelArchetype, fastChannelArchetype} {
+		for _, v := range (*versions)[arch] {
+			released.Insert(releaseVersion(v))
+		}
+	}
+	if released.Len() == 0 {
+		return
+	}
+	for _, name := range sortedBundleNames(stable) {
+		if v := stable[name]; len(v.Pre) == 0 && !released.Has(releaseVersion(v)) {
+			report.warnf("stable bundle %q version %q was not released to candidate or fast", name, v)
+		}
+	}
+}
+
+// headsByBundle collects, for each bundle, the channels where it is the head.  A bundle normally heads both its
+// minor and major channel; heading a channel at an unexpected version can indicate a generation bug.
+func headsByBundle(channels []declcfg.Channel) map[string][]string {
+	heads := make(map[string][]string)
+	for i := range channels {
+		head, err := channelHead(&channels[i])
+		if err != nil {
+			// channels without a single head have no meaningful entry here
+			continue
+		}
+		heads[head] = append(heads[head], channels[i].Name)
+	}
+	for _, chs := range heads {
+		sort.Strings(chs)
+	}
+	return heads
+}
+
+// predecessorsByChannel derives each channel's version --> replaced version mapping from its replaces edges
+func predecessorsByChannel(channels []declcfg.Channel, versions *bundleVersions) map[string]map[string]string {
+	bundleVersion := make(map[string]semver.Version)
+	for _, bundles := range *versions {
+		for name, v := range bundles {
+			bundleVersion[name] = v
+		}
+	}
+
+	predecessors := make(map[string]map[string]string)
+	for _, ch := range channels {
+		previous := make(map[string]string)
+		for _, e := range ch.Entries {
+			if e.Replaces == "" {
+				continue
+			}
+			v, ok := bundleVersion[e.Name]
+			replaced, replacedOK := bundleVersion[e.Replaces]
+			if !ok || !replacedOK {
+				continue
+			}
+			previous[v.String()] = replaced.String()
+		}
+		if len(previous) != 0 {
+			predecessors[ch.Name] = previous
+		}
+	}
+	return predecessors
+}
+
+// DefaultChannelChain renders the template and returns the versions of its default channel's replaces spine, ordered
+// from tail to head: the upgrade path followed by a user of the default channel who takes every replaces edge.
+// Entries which are only skipped are not part of the chain.  The template must render a single package.  Like Render,
+// it consumes Data.
+func (t Template) DefaultChannelChain(ctx context.Context) ([]semver.Version, error) {
+	out, err := t.Render(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(out.Packages) != 1 {
+		return nil, fmt.Errorf("default channel chain: template renders %d packages, expected exactly one", len(out.Packages))
+	}
+	pkg := out.Packages[0]
+	var ch *declcfg.Channel
+	for i := range out.Channels {
+		if out.Channels[i].Package == pkg.Name && out.Channels[i].Name == pkg.DefaultChannel {
+			ch = &out.Channels[i]
+		}
+	}
+	if ch == nil {
+		return nil, fmt.Errorf("default channel %q not found", pkg.DefaultChannel)
+	}
+	head, err := channelHead(ch)
+	if err != nil {
+		return nil, err
+	}
+
+	bundleVersion, err := versionsByName(out)
+	if err != nil {
+		return nil, err
+	}
+	replaces := make(map[string]string, len(ch.Entries))
+	for _, e := range ch.Entries {
+		replaces[e.Name] = e.Replaces
+	}
+
+	// walk back from the head for as long as the replaced bundle is an entry of the channel
+	chain := []semver.Version{}
+	visited := sets.NewString()
+	for name := head; name != ""; name = replaces[name] {
+		if _, ok := replaces[name]; !ok {
+			break
+		}
+		if visited.Has(name) {
+			return nil, fmt.Errorf("channel %q has a replaces cycle through %q", ch.Name, name)
+		}
+		visited.Insert(name)
+		chain = append(chain, bundleVersion[name])
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, nil
+}
+
+// versionsByName maps the name of each bundle in cfg to its version
+func versionsByName(cfg *declcfg.DeclarativeConfig) (map[string]semver.Version, error) {
+	versions := make(map[string]semver.Version, len(cfg.Bundles))
+	for _, b := range cfg.Bundles {
+		pkg, err := bundlePackage(b)
+		if err != nil {
+			return nil, err
+		}
+		v, err := semver.Parse(pkg.Version)
+		if err != nil {
+			return nil, &ErrInvalidVersion{Bundle: b.Name, Version: pkg.Version, Err: err}
+		}
+		versions[b.Name] = v
+	}
+	return versions, nil
+}
@@ -0,0 +1,341 @@
+package semver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+
+	"github.com/operator-framework/operator-registry/pkg/image"
+)
+
+func TestReportHeads(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.1.0")
+	tmpl := Template{
+		Data: strings.NewReader(fmt.Sprintf(`---
+schema: olm.semver
+generateMajorChannels: true
+generateMinorChannels: true
+stable:
+  bundles:
+  - image: %s
+  - image: %s
+`, bundles[0].image, bundles[1].image)),
+		Registry: newTestRegistry(bundles...),
+	}
+
+	_, report, err := tmpl.RenderWithReport(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{
+		"a.v1.0.0": {"stable-v1.0"},
+		"a.v1.1.0": {"stable-v1", "stable-v1.1"},
+	}, report.Heads)
+}
+
+func TestReportBuildMetadataWarnings(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.0.1+build.5")
+	// "+" is not valid in an image tag
+	bundles[1].image = testImage("a", "1.0.1_build.5")
+	newTemplate := func(warn bool) Template {
+		return Template{
+			Data: strings.NewReader(fmt.Sprintf(`---
+schema: olm.semver
+stable:
+  bundles:
+  - image: %s
+  - image: %s
+`, bundles[0].image, bundles[1].image)),
+			Registry:            newTestRegistry(bundles...),
+			WarnOnBuildMetadata: warn,
+		}
+	}
+
+	_, report, err := newTemplate(true).RenderWithReport(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{`bundle "a.v1.0.1+build.5" version "1.0.1+build.5" carries build metadata, which is ignored when ordering versions`}, report.Warnings)
+
+	_, report, err = newTemplate(false).RenderWithReport(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, report.Warnings)
+}
+
+func TestReportArchetypes(t *testing.T) {
+	bundles := testBundles("a", "0.1.0", "0.2.0", "1.0.0", "1.1.0", "1.1.1")
+	tmpl := Template{
+		Data: strings.NewReader(fmt.Sprintf(`---
+schema: olm.semver
+generateMajorChannels: true
+generateMinorChannels: true
+candidate:
+  bundles:
+  - image: %s
+  - image: %s
+  - image: %s
+  - image: %s
+  - image: %s
+fast:
+  bundles:
+  - image: %s
+  - image: %s
+`, bundles[0].image, bundles[1].image, bundles[2].image, bundles[3].image, bundles[4].image, bundles[3].image, bundles[4].image)),
+		Registry: newTestRegistry(bundles...),
+	}
+	_, report, err := tmpl.RenderWithReport(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string]ArchetypeSummary{
+		"candidate": {Bundles: 5, LowestVersion: "0.1.0", HighestVersion: "1.1.1", Channels: 6, MinorChannels: 4, MajorChannels: 2},
+		"fast":      {Bundles: 2, LowestVersion: "1.1.0", HighestVersion: "1.1.1", Channels: 2, MinorChannels: 1, MajorChannels: 1},
+	}, report.Archetypes)
+
+	// classified channels replace the minor and major channels
+	tmpl.Data = strings.NewReader(fmt.Sprintf("schema: olm.semver\ngenerateMajorChannels: true\ncandidate:\n  bundles:\n  - image: %s\n  - image: %s\n  - image: %s\n", bundles[0].image, bundles[1].image, bundles[2].image))
+	tmpl.ChannelClassifier = func(v semver.Version, archetype string) []string {
+		if v.Major == 0 {
+			return []string{archetype + "-legacy"}
+		}
+		return []string{archetype + "-current"}
+	}
+	_, report, err = tmpl.RenderWithReport(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string]ArchetypeSummary{
+		"candidate": {Bundles: 3, LowestVersion: "0.1.0", HighestVersion: "1.0.0", Channels: 2},
+	}, report.Archetypes)
+
+	// as do declared channels, each its own archetype
+	tmpl.ChannelClassifier = nil
+	tmpl.Data = strings.NewReader(fmt.Sprintf("schema: olm.semver\ngenerateMajorChannels: true\nchannels:\n- name: preview\n  bundles:\n  - image: %s\n  - image: %s\n- name: ga\n  bundles:\n  - image: %s\n", bundles[3].image, bundles[4].image, bundles[3].image))
+	_, report, err = tmpl.RenderWithReport(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string]ArchetypeSummary{
+		"preview": {Bundles: 2, LowestVersion: "1.1.0", HighestVersion: "1.1.1", Channels: 1},
+		"ga":      {Bundles: 1, LowestVersion: "1.1.0", HighestVersion: "1.1.0", Channels: 1},
+	}, report.Archetypes)
+}
+
+func TestReportPredecessors(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.0.1", "1.1.0", "1.2.0", "1.2.1")
+	tmpl := Template{
+		Data: strings.NewReader(fmt.Sprintf(`---
+schema: olm.semver
+generateMajorChannels: true
+generateMinorChannels: false
+stable:
+  bundles:
+  - image: %s
+  - image: %s
+  - image: %s
+  - image: %s
+  - image: %s
+`, bundles[0].image, bundles[1].image, bundles[2].image, bundles[3].image, bundles[4].image)),
+		Registry:         newTestRegistry(bundles...),
+		EmitPredecessors: true,
+	}
+	out, report, err := tmpl.RenderWithReport(context.Background())
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]map[string]string{
+		"stable-v1": {"1.1.0": "1.0.1", "1.2.1": "1.1.0"},
+	}, report.Predecessors)
+
+	// every predecessor corresponds to a replaces edge
+	require.Len(t, out.Channels, 1)
+	replaces := map[string]string{}
+	for _, e := range out.Channels[0].Entries {
+		if e.Replaces != "" {
+			replaces[e.Name] = e.Replaces
+		}
+	}
+	require.Equal(t, map[string]string{"a.v1.1.0": "a.v1.0.1", "a.v1.2.1": "a.v1.1.0"}, replaces)
+}
+
+func TestReportIdenticalArchetypes(t *testing.T) {
+	bundles := testBundles("a", "0.1.0", "0.1.1")
+	data := fmt.Sprintf(`---
+schema: olm.semver
+candidate:
+  bundles:
+  - image: %[1]s
+  - image: %[2]s
+fast:
+  bundles:
+  - image: %[1]s
+  - image: %[2]s
+stable:
+  bundles:
+  - image: %[1]s
+`, bundles[0].image, bundles[1].image)
+	tmpl := Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}
+	_, report, err := tmpl.RenderWithReport(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"candidate and fast channels contain the same 2 bundles; consider whether they should be promoted"}, report.Warnings)
+}
+
+func TestDefaultChannelChain(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.0.1", "1.1.0", "1.1.1", "1.2.0")
+	data := "---\nschema: olm.semver\ngenerateMajorChannels: true\ngenerateMinorChannels: false\nstable:\n  bundles:\n"
+	for _, b := range bundles {
+		data += fmt.Sprintf("  - image: %s\n", b.image)
+	}
+	tmpl := Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}
+	chain, err := tmpl.DefaultChannelChain(context.Background())
+	require.NoError(t, err)
+	// 1.0.0 and 1.1.0 are only skipped, so are not on the replaces spine
+	require.Equal(t, []semver.Version{semver.MustParse("1.0.1"), semver.MustParse("1.1.1"), semver.MustParse("1.2.0")}, chain)
+
+	t.Run("multiple packages", func(t *testing.T) {
+		a := testBundles("a", "1.0.0")
+		b := testBundles("b", "1.0.0")
+		data := fmt.Sprintf("schema: olm.semver\npackages:\n- name: a\n  stable:\n    bundles:\n    - image: %s\n- name: b\n  stable:\n    bundles:\n    - image: %s\n", a[0].image, b[0].image)
+		_, err := Template{Data: strings.NewReader(data), Registry: newTestRegistry(append(a, b...)...)}.DefaultChannelChain(context.Background())
+		require.EqualError(t, err, "default channel chain: template renders 2 packages, expected exactly one")
+	})
+}
+
+func TestReportPromotionAnomalies(t *testing.T) {
+	bundles := testBundles("a", "1.1.0-rc.1", "1.1.0", "1.2.0-rc.1", "1.3.0")
+	data := fmt.Sprintf(`---
+schema: olm.semver
+candidate:
+  bundles:
+  - image: %[1]s
+  - image: %[3]s
+stable:
+  bundles:
+  - image: %[2]s
+  - image: %[3]s
+  - image: %[4]s
+`, bundles[0].image, bundles[1].image, bundles[2].image, bundles[3].image)
+	tmpl := Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...), WarnOnPromotionAnomalies: true}
+	_, report, err := tmpl.RenderWithReport(context.Background())
+	require.NoError(t, err)
+	// 1.1.0 was promoted from its release candidate, but 1.2.0-rc.1 leaked to stable and 1.3.0 skipped candidate
+	require.Equal(t, []string{
+		`stable bundle "a.v1.2.0-rc.1" has prerelease version "1.2.0-rc.1"`,
+		`stable bundle "a.v1.3.0" version "1.3.0" was not released to candidate or fast`,
+	}, report.Warnings)
+}
+
+// delayRegistry delays the pulls of its images by fixed durations
+type delayRegistry struct {
+	*image.MockRegistry
+	delays map[string]time.Duration
+}
+
+func (r *delayRegistry) Pull(ctx context.Context, ref image.Reference) error {
+	time.Sleep(r.delays[ref.String()])
+	return r.MockRegistry.Pull(ctx, ref)
+}
+
+func TestReportRenderDurations(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.1.0", "1.2.0")
+	data := "schema: olm.semver\nstable:\n  bundles:\n"
+	for _, b := range bundles {
+		data += fmt.Sprintf("  - image: %s\n", b.image)
+	}
+	delays := map[string]time.Duration{
+		bundles[0].image: 0,
+		bundles[1].image: 100 * time.Millisecond,
+		bundles[2].image: 50 * time.Millisecond,
+	}
+	reg := &delayRegistry{MockRegistry: newTestRegistry(bundles...), delays: delays}
+
+	_, report, err := Template{Data: strings.NewReader(data), Registry: reg, EmitRenderDurations: true}.RenderWithReport(context.Background())
+	require.NoError(t, err)
+	require.Len(t, report.RenderDurations, 3)
+	var images []string
+	for _, d := range report.RenderDurations {
+		images = append(images, d.Image)
+		require.GreaterOrEqual(t, d.Duration, delays[d.Image])
+	}
+	require.Equal(t, []string{bundles[1].image, bundles[2].image, bundles[0].image}, images)
+
+	_, report, err = Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}.RenderWithReport(context.Background())
+	require.NoError(t, err)
+	require.Nil(t, report.RenderDurations)
+}
+
+func TestReportChannelNameDrift(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.1.0")
+	render := func(archetype string, indent string, previous ...string) *RenderReport {
+		data := "schema: olm.semver\n" + archetype + "\n" + indent + "bundles:\n"
+		for _, b := range bundles {
+			data += fmt.Sprintf("%s- image: %s\n", indent, b.image)
+		}
+		_, report, err := Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...), PreviousChannelNames: previous}.RenderWithReport(context.Background())
+		require.NoError(t, err)
+		return report
+	}
+
+	// added channels are not drift
+	require.Empty(t, render("stable:", "  ", "stable-v1.0").Warnings)
+
+	// moving the bundles to a custom channel type changes the channel name prefix
+	require.Equal(t, []string{
+		`channel "stable-v1.0" of the previous render is no longer generated`,
+		`channel "stable-v1.1" of the previous render is no longer generated`,
+	}, render("customChannels:\n  ga:\n    priority: 3", "    ", "stable-v1.0", "stable-v1.1").Warnings)
+}
+
+func TestReportRepeatedImages(t *testing.T) {
+	bundles := testBundles("a", "0.1.0", "0.1.1", "0.2.0")
+	data := fmt.Sprintf(`---
+schema: olm.semver
+candidate:
+  bundles:
+  - image: %[1]s
+  - image: %[2]s
+stable:
+  bundles:
+  - image: %[1]s
+  - image: %[3]s
+`, bundles[0].image, bundles[1].image, bundles[2].image)
+	render := func(warn bool) *RenderReport {
+		_, report, err := Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...), WarnOnRepeatedImages: warn}.RenderWithReport(context.Background())
+		require.NoError(t, err)
+		return report
+	}
+
+	require.Empty(t, render(false).Warnings)
+	require.Equal(t, []string{
+		fmt.Sprintf("bundle image %q is listed under candidate, stable; it is rendered once, with a single version", bundles[0].image),
+	}, render(true).Warnings)
+}
+
+func TestReportLongSkips(t *testing.T) {
+	bundles := testBundles("a", "0.1.0", "0.1.1", "0.1.2", "0.1.3")
+	data := "schema: olm.semver\nstable:\n  bundles:\n"
+	for _, b := range bundles {
+		data += fmt.Sprintf("  - image: %s\n", b.image)
+	}
+	render := func(threshold int) (*RenderReport, *test.Hook) {
+		logger, hook := test.NewNullLogger()
+		_, report, err := Template{
+			Data:                  strings.NewReader(data),
+			Registry:              newTestRegistry(bundles...),
+			SkipsWarningThreshold: threshold,
+			Log:                   logrus.NewEntry(logger),
+		}.RenderWithReport(context.Background())
+		require.NoError(t, err)
+		return report, hook
+	}
+
+	report, hook := render(0)
+	require.Empty(t, report.Warnings)
+	require.Empty(t, hook.AllEntries())
+
+	report, hook = render(2)
+	expected := `channel "stable-v0.1" entry "a.v0.1.3" skips 3 bundles, more than 2; consider setting generateSkipRange`
+	require.Equal(t, []string{expected}, report.Warnings)
+	require.Len(t, hook.AllEntries(), 1)
+	require.Equal(t, logrus.WarnLevel, hook.LastEntry().Level)
+	require.Equal(t, expected, hook.LastEntry().Message)
+
+	report, _ = render(-1)
+	require.Empty(t, report.Warnings)
+}
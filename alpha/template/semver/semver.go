@@ -2,11 +2,22 @@ package semver
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"runtime"
 	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
 
 	"github.com/blang/semver/v4"
+	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/yaml"
@@ -14,270 +25,1955 @@ import (
 	"github.com/operator-framework/operator-registry/alpha/action"
 	"github.com/operator-framework/operator-registry/alpha/declcfg"
 	"github.com/operator-framework/operator-registry/alpha/property"
+	"github.com/operator-framework/operator-registry/pkg/image/containerdregistry"
 )
 
 func (t Template) Render(ctx context.Context) (*declcfg.DeclarativeConfig, error) {
-	var out declcfg.DeclarativeConfig
+	out, _, err := t.RenderWithReport(ctx)
+	return out, err
+}
 
-	sv, err := readFile(t.Data)
+// RenderWithReport renders the template like Render, and additionally returns a report describing the result
+func (t Template) RenderWithReport(ctx context.Context) (*declcfg.DeclarativeConfig, *RenderReport, error) {
+	sv, out, err := t.renderBundles(ctx, false)
 	if err != nil {
-		return nil, fmt.Errorf("render: unable to read file: %v", err)
+		return nil, nil, err
 	}
+	return t.generate(ctx, sv, out, nil)
+}
 
-	var cfgs []declcfg.DeclarativeConfig
+// Validate renders the template like Render, discarding the result, and returns any error which would fail the render,
+// including violations of the template's Policy
+func (t Template) Validate(ctx context.Context) error {
+	_, err := t.Render(ctx)
+	return err
+}
 
-	bundleDict := make(map[string]struct{})
-	buildBundleList(&sv.Candidate.Bundles, &bundleDict)
-	buildBundleList(&sv.Fast.Bundles, &bundleDict)
-	buildBundleList(&sv.Stable.Bundles, &bundleDict)
+// RenderWithDeadline renders the template like RenderWithReport, on a best-effort basis: bundle images which have not
+// rendered within d are skipped, and channels are generated from the bundles which did render.  The skipped images
+// are listed in the report's Unrendered, and each is reported as a warning.
+func (t Template) RenderWithDeadline(ctx context.Context, d time.Duration) (*declcfg.DeclarativeConfig, *RenderReport, error) {
+	// only rendering is limited by the deadline; the channels are generated from whichever bundles rendered
+	renderCtx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
 
-	for b := range bundleDict {
-		r := action.Render{
-			AllowedRefMask: action.RefBundleImage,
-			Refs:           []string{b},
-			Registry:       t.Registry,
+	sv, out, err := t.renderBundles(renderCtx, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, report, err := t.generate(ctx, sv, out, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	report.Unrendered = sv.unrendered
+	for _, image := range sv.unrendered {
+		report.warnf("bundle image %q was skipped because it did not render before the deadline", image)
+	}
+	return out, report, nil
+}
+
+// renderBundles reads the template and renders each distinct bundle image it references.  When bestEffort is set,
+// images which fail to render because ctx is done are dropped from the template and recorded in sv.unrendered,
+// instead of failing the render.
+func (t Template) renderBundles(ctx context.Context, bestEffort bool) (*semverTemplate, *declcfg.DeclarativeConfig, error) {
+	sv, bundleDict, files, err := t.preflight()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(t.AuthFiles) != 0 {
+		if t.Registry != nil {
+			return nil, nil, fmt.Errorf("render: auth files cannot be combined with a registry")
 		}
-		c, err := r.Run(ctx)
+		reg, err := newAuthFilesRegistry(t.AuthFiles)
 		if err != nil {
-			return nil, err
+			return nil, nil, fmt.Errorf("render: %w", err)
 		}
-		cfgs = append(cfgs, *c)
+		defer reg.Destroy()
+		t.Registry = reg
+	}
+
+	images := make([]string, 0, len(bundleDict))
+	for b := range bundleDict {
+		images = append(images, b)
+	}
+	sort.Strings(images)
+	cfgs, unrendered, durations, err := t.renderImages(ctx, images, files, sv.ociLayouts, bestEffort)
+	if err != nil {
+		return nil, nil, err
+	}
+	sv.unrendered = unrendered
+	sv.renderDurations = durations
+	out, err := combineConfigs(cfgs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("render: %w", err)
 	}
-	out = *combineConfigs(cfgs)
+	sv.dropBundles(sets.NewString(sv.unrendered...))
 
 	if len(out.Bundles) == 0 {
-		return nil, fmt.Errorf("render: no bundles specified or no bundles could be rendered")
+		return nil, nil, fmt.Errorf("render: no bundles could be rendered")
 	}
+	return sv, out, nil
+}
+
+// generate post-processes the rendered bundles of a freshly-read template and generates its channels.  When variant
+// is set, its pruning is applied to the channel archetypes before the channels are generated.  Channel generation
+// stops early if ctx is cancelled.
+func (t Template) generate(ctx context.Context, sv *semverTemplate, out *declcfg.DeclarativeConfig, variant *VariantSpec) (*declcfg.DeclarativeConfig, *RenderReport, error) {
+	if len(sv.Packages) != 0 {
+		return t.generatePackages(ctx, sv, out, variant)
+	}
+	report := &RenderReport{}
+	renderedProperties := bundlePropertiesByImage(out)
 
-	channelBundleVersions, err := sv.getVersionsFromStandardChannels(&out)
+	channelBundleVersions, err := t.classifyBundles(sv, out, variant, report)
+	if err != nil {
+		return nil, nil, fmt.Errorf("render: %w", err)
+	}
+	channels, err := t.linkChannels(ctx, sv, out, channelBundleVersions)
 	if err != nil {
-		return nil, fmt.Errorf("render: unable to post-process bundle info: %v", err)
+		return nil, nil, fmt.Errorf("render: %w", err)
+	}
+	if err := t.validateChannels(sv, channels, channelBundleVersions); err != nil {
+		return nil, nil, fmt.Errorf("render: %w", err)
+	}
+	if err := sv.annotateTestedFrom(channels, out, channelBundleVersions); err != nil {
+		return nil, nil, fmt.Errorf("render: %w", err)
+	}
+	// the selection is only announced once the channels it was made from are known to be valid
+	if t.OnDefaultChannelSelected != nil && sv.defaultChannel != "" && sv.defaultHead != nil {
+		t.OnDefaultChannelSelected(sv.defaultChannel, string(sv.defaultArch), *sv.defaultHead)
+	}
+	if err := t.postProcess(sv, out, channels, channelBundleVersions, renderedProperties, report); err != nil {
+		return nil, nil, fmt.Errorf("render: %w", err)
 	}
 
-	channels := sv.generateChannels(channelBundleVersions)
-	out.Channels = channels
-	out.Packages[0].DefaultChannel = sv.defaultChannel
+	// migrated last, since generation requires the rendered bundle properties to be unchanged
+	if t.CatalogFormat != "" {
+		if err := action.MigrateConfig(out, t.CatalogFormat); err != nil {
+			return nil, nil, fmt.Errorf("render: %w", err)
+		}
+	}
 
-	return &out, nil
+	return out, report, nil
 }
 
-func buildBundleList(bundles *[]semverTemplateBundleEntry, dict *map[string]struct{}) {
-	for _, b := range *bundles {
-		if _, ok := (*dict)[b.Image]; !ok {
-			(*dict)[b.Image] = struct{}{}
+// classifyBundles is the first phase of generate.  It post-processes the rendered bundles, reads their versions into
+// the channel archetypes, and prunes and checks them according to the template's options, returning the versions from
+// which channels are generated.
+func (t Template) classifyBundles(sv *semverTemplate, out *declcfg.DeclarativeConfig, variant *VariantSpec, report *RenderReport) (*bundleVersions, error) {
+	var err error
+	if t.VerifyTagMatchesVersion {
+		if err := validateTagsMatchVersions(out); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.Platform != "" {
+		if sv.platform, err = parsePlatform(t.Platform); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.DisambiguateBundleNames {
+		if err := disambiguateBundleNames(out); err != nil {
+			return nil, err
 		}
 	}
-}
 
-func readFile(reader io.Reader) (*semverTemplate, error) {
-	data, err := io.ReadAll(reader)
+	channelBundleVersions, err := sv.getVersionsFromStandardChannels(out)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("unable to post-process bundle info: %w", err)
 	}
 
-	// default behavior is to generate only minor channels
-	sv := semverTemplate{
-		GenerateMajorChannels: false,
-		GenerateMinorChannels: true,
+	if t.ExpectedPackage != "" && sv.pkg != t.ExpectedPackage {
+		return nil, fmt.Errorf("template bundles belong to package %q, expected package %q", sv.pkg, t.ExpectedPackage)
 	}
-	if err := yaml.UnmarshalStrict(data, &sv); err != nil {
-		return nil, err
+
+	if sv.platform != nil {
+		pruneBundles(out, channelBundleVersions)
+		if len(out.Bundles) == 0 {
+			return nil, fmt.Errorf("no bundles support platform %q", sv.platform)
+		}
 	}
-	if sv.Schema != schema {
-		return nil, fmt.Errorf("readFile: input file has unknown schema, should be %q", schema)
+
+	if sv.PackageNameOverride != "" {
+		if err := sv.renamePackage(out, sv.PackageNameOverride); err != nil {
+			return nil, err
+		}
 	}
-	return &sv, nil
-}
 
-func (sv *semverTemplate) getVersionsFromStandardChannels(cfg *declcfg.DeclarativeConfig) (*bundleVersions, error) {
-	versions := bundleVersions{}
+	if t.NormalizePackageName {
+		if err := sv.renamePackage(out, strings.ToLower(sv.pkg)); err != nil {
+			return nil, err
+		}
+	}
 
-	bdm, err := sv.getVersionsFromChannel(sv.Candidate.Bundles, cfg)
-	if err != nil {
-		return nil, err
+	if sv.EntryNameTemplate != "" {
+		if err := renameBundles(out, channelBundleVersions, sv.EntryNameTemplate); err != nil {
+			return nil, err
+		}
 	}
-	if err = validateVersions(&bdm); err != nil {
-		return nil, err
+
+	if t.VersionFilter != nil {
+		filterVersions(channelBundleVersions, t.VersionFilter)
+		pruneBundles(out, channelBundleVersions)
+		if len(out.Bundles) == 0 {
+			return nil, fmt.Errorf("no bundles satisfy the version filter")
+		}
 	}
-	versions[candidateChannelArchetype] = bdm
 
-	bdm, err = sv.getVersionsFromChannel(sv.Fast.Bundles, cfg)
-	if err != nil {
-		return nil, err
+	if sv.PrereleasePolicy == prereleasePolicyExclude {
+		for _, name := range excludePrereleases(channelBundleVersions) {
+			report.warnf("prerelease bundle %q was excluded by the prerelease policy", name)
+		}
+		pruneBundles(out, channelBundleVersions)
+		if len(out.Bundles) == 0 {
+			return nil, fmt.Errorf("no bundles remain after excluding prereleases")
+		}
+	}
+
+	if pruned := sv.pruneBelowMinVersions(channelBundleVersions); len(pruned) != 0 {
+		report.warnf("bundles %q are below the minimum version and were pruned", pruned)
+		pruneBundles(out, channelBundleVersions)
+		if len(out.Bundles) == 0 {
+			return nil, fmt.Errorf("no bundles remain at or above the minimum version")
+		}
+	}
+
+	if variant != nil {
+		variant.prune(channelBundleVersions)
+		pruneBundles(out, channelBundleVersions)
+		if len(out.Bundles) == 0 {
+			return nil, fmt.Errorf("no bundles remain after pruning")
+		}
+	}
+
+	if t.RequireStrictPromotion && len(sv.Channels) == 0 {
+		if err := validateStrictPromotion(channelBundleVersions); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.Policy != nil {
+		if err := t.Policy.enforce(sv, channelBundleVersions); err != nil {
+			return nil, err
+		}
+	}
+
+	// build metadata which orders versions is intended
+	if t.WarnOnBuildMetadata && sv.BuildMetadataPolicy != buildMetadataPolicyOrder {
+		buildMetadataWarnings(channelBundleVersions, report)
+	}
+
+	if t.WarnOnRepeatedImages {
+		sv.repeatedImageWarnings(report)
+	}
+
+	if t.WarnOnPromotionAnomalies && len(sv.Channels) == 0 {
+		promotionWarnings(channelBundleVersions, report)
 	}
-	if err = validateVersions(&bdm); err != nil {
+
+	if err := sv.resolvePreviewHead(out, channelBundleVersions); err != nil {
 		return nil, err
 	}
-	versions[fastChannelArchetype] = bdm
 
-	bdm, err = sv.getVersionsFromChannel(sv.Stable.Bundles, cfg)
+	warnings, err := sv.resolveHeads(out, channelBundleVersions)
 	if err != nil {
 		return nil, err
 	}
-	if err = validateVersions(&bdm); err != nil {
-		return nil, err
+	for _, w := range warnings {
+		report.warnf("%s", w)
 	}
-	versions[stableChannelArchetype] = bdm
 
-	return &versions, nil
-}
+	if sv.GenerateSkipRange {
+		for _, names := range sv.heads {
+			if names.Len() != 0 {
+				return nil, fmt.Errorf("flagged channel heads cannot be combined with generateSkipRange")
+			}
+		}
+	}
 
-func (sv *semverTemplate) getVersionsFromChannel(semverBundles []semverTemplateBundleEntry, cfg *declcfg.DeclarativeConfig) (map[string]semver.Version, error) {
-	entries := make(map[string]semver.Version)
+	if err := sv.resolveOrdinals(out, channelBundleVersions); err != nil {
+		return nil, err
+	}
 
-	// we iterate over the channel bundles from the template, to:
-	// - identify if any required bundles for the channel are missing/not rendered/otherwise unavailable
-	// - maintain the channel-bundle relationship as we map from un-rendered semver template bundles to rendered bundles in `entries` which is accumulated by the caller
-	//   in a per-channel structure to which we can safely refer when generating/linking channels
-	for _, semverBundle := range semverBundles {
-		// test if the bundle specified in the template is present in the successfully-rendered bundles
-		index := 0
-		for index < len(cfg.Bundles) {
-			if cfg.Bundles[index].Image == semverBundle.Image {
-				break
+	if t.ChannelNamer != nil {
+		sv.namer = t.ChannelNamer
+	}
+	if t.ChannelNamer != nil && len(sv.Channels) == 0 {
+		if err := sv.validateChannelNames(channelBundleVersions); err != nil {
+			return nil, err
+		}
+	}
+
+	if pruned := sv.reduceToHeads(channelBundleVersions); pruned.Len() != 0 {
+		bundles := out.Bundles[:0]
+		for _, b := range out.Bundles {
+			if !pruned.Has(b.Name) {
+				bundles = append(bundles, b)
 			}
-			index++
 		}
-		if index == len(cfg.Bundles) {
-			return nil, fmt.Errorf("supplied bundle image name %q not found in rendered bundle images", semverBundle.Image)
+		out.Bundles = bundles
+	}
+
+	if t.ChannelClassifier != nil {
+		if len(sv.Channels) != 0 {
+			return nil, fmt.Errorf("a channel classifier cannot be combined with declared channels")
 		}
-		b := cfg.Bundles[index]
+		if err := sv.classifyChannels(t.ChannelClassifier, channelBundleVersions); err != nil {
+			return nil, err
+		}
+	}
 
-		props, err := property.Parse(b.Properties)
+	return channelBundleVersions, nil
+}
+
+// linkChannels is the second phase of generate.  It generates the channels of the classified bundles, linked by their
+// upgrade edges.
+func (t Template) linkChannels(ctx context.Context, sv *semverTemplate, out *declcfg.DeclarativeConfig, channelBundleVersions *bundleVersions) ([]declcfg.Channel, error) {
+	var err error
+	sv.defaultChannelRange = t.DefaultChannelVersionRange
+	sv.cascadingDefault = t.CascadingArchetypeDefault
+	switch t.DefaultChannelEntry {
+	case "", DefaultChannelEntryHead:
+	case DefaultChannelEntryTail:
+		sv.defaultEntryTail = true
+	default:
+		return nil, fmt.Errorf("invalid default channel entry %q, expected %q or %q", t.DefaultChannelEntry, DefaultChannelEntryHead, DefaultChannelEntryTail)
+	}
+	sv.includeChannels, sv.excludeChannels = t.IncludeChannels, t.ExcludeChannels
+	var channels []declcfg.Channel
+	if len(sv.Channels) != 0 {
+		if channels, err = sv.generatePlannedChannels(ctx, channelBundleVersions); err != nil {
+			return nil, err
+		}
+	} else {
+		if channels, err = sv.generateChannels(ctx, channelBundleVersions); err != nil {
+			return nil, err
+		}
+		recommended, err := sv.generateRecommendedChannel(channelBundleVersions)
 		if err != nil {
-			return nil, fmt.Errorf("parse properties for bundle %q: %v", b.Name, err)
+			return nil, err
+		}
+		if recommended != nil && sv.includesChannel(recommended.Name) {
+			channels = append(channels, *recommended)
+		}
+		if sv.preview != nil && sv.includesChannel(previewChannelName) {
+			for _, ch := range channels {
+				if ch.Name == previewChannelName {
+					return nil, fmt.Errorf("the preview channel has the name of a generated channel %q", ch.Name)
+				}
+			}
+			channels = append(channels, *sv.previewChannel())
+		}
+	}
+	if len(sv.SkipVersions) != 0 {
+		if err := sv.skipVersions(channels, channelBundleVersions); err != nil {
+			return nil, err
+		}
+	}
+	return channels, nil
+}
+
+// validateChannels is the third phase of generate.  It checks the upgrade edges of the generated channels, and resolves
+// the default channel among them.
+func (t Template) validateChannels(sv *semverTemplate, channels []declcfg.Channel, channelBundleVersions *bundleVersions) error {
+	if err := validateSkipRanges(channels, channelBundleVersions); err != nil {
+		return err
+	}
+	if err := sv.validateReplacesTargets(channels, channelBundleVersions); err != nil {
+		return err
+	}
+	if err := validateEntrySkips(channels); err != nil {
+		return err
+	}
+	if err := validateAcyclicEdges(channels); err != nil {
+		return err
+	}
+	if !t.SkipChannelValidation {
+		if err := validateChannelReachability(channels, channelBundleVersions); err != nil {
+			return err
+		}
+	}
+	if t.MaxSkipsPerEntry > 0 {
+		if err := validateMaxSkips(channels, t.MaxSkipsPerEntry); err != nil {
+			return err
+		}
+	}
+	flaggedHeads := sets.NewString()
+	for _, names := range sv.heads {
+		flaggedHeads = flaggedHeads.Union(names)
+	}
+	if err := sv.validateReplacesOrder(channels, channelBundleVersions, flaggedHeads); err != nil {
+		return err
+	}
+	if len(sv.Channels) == 0 && sv.classified == nil && sv.GenerateMajorChannels && sv.GenerateMinorChannels {
+		if err := validateMajorChannelCompleteness(channels, channelBundleVersions, sv.channelNamer()); err != nil {
+			return err
+		}
+	}
+	if sv.DefaultChannelOverride != "" {
+		found := false
+		for _, ch := range channels {
+			found = found || ch.Name == sv.DefaultChannelOverride
+		}
+		if !found {
+			return fmt.Errorf("default channel %q is not one of the generated channels", sv.DefaultChannelOverride)
+		}
+		sv.defaultChannel = sv.DefaultChannelOverride
+	}
+	if sv.defaultChannel == "" && len(channels) != 0 && t.DefaultChannelVersionRange != nil {
+		return fmt.Errorf("no channel head satisfies the default channel version range")
+	}
+	if sv.defaultHead != nil && sv.defaultChannel != "" {
+		if err := validateDefaultChannelHead(channels, sv.defaultChannel, *sv.defaultHead, channelBundleVersions); err != nil {
+			return err
 		}
-		if len(props.Packages) != 1 {
-			return nil, fmt.Errorf("bundle %q has multiple %q properties, expected exactly 1", b.Name, property.TypePackage)
+	}
+	return nil
+}
+
+// postProcess is the last phase of generate.  It applies the channel mutator, completes the output with the package's
+// default channel, deprecations, and bundle properties, and fills in the report.
+func (t Template) postProcess(sv *semverTemplate, out *declcfg.DeclarativeConfig, channels []declcfg.Channel, channelBundleVersions *bundleVersions, renderedProperties map[string][]string, report *RenderReport) error {
+	var err error
+	if t.ChannelMutator != nil {
+		if channels, err = t.ChannelMutator(channels); err != nil {
+			return fmt.Errorf("channel mutator: %w", err)
+		}
+	}
+	// the mutator may have renamed or dropped the default channel
+	if err := validateDefaultChannel(channels, sv.defaultChannel); err != nil {
+		return err
+	}
+	out.Channels = channels
+	out.Packages[0].DefaultChannel = sv.defaultChannel
+	if sv.defaultEntryTail && sv.defaultChannel != "" {
+		if err := sv.annotateDefaultEntry(&out.Packages[0], channels, channelBundleVersions); err != nil {
+			return err
 		}
-		v, err := semver.Parse(props.Packages[0].Version)
+	}
+
+	if err := validatePackageChannels(out); err != nil {
+		return err
+	}
+	if err := validateChannelPackages(out); err != nil {
+		return err
+	}
+
+	if len(sv.Deprecations) != 0 {
+		deps, err := sv.deprecations(out, channelBundleVersions)
 		if err != nil {
-			return nil, fmt.Errorf("bundle %q has invalid version %q: %v", b.Name, props.Packages[0].Version, err)
+			return err
 		}
+		out.Others = append(out.Others, *deps)
+	}
 
-		// package name detection
-		if sv.pkg != "" {
-			// if we have a known package name, then ensure all subsequent packages match
-			if props.Packages[0].PackageName != sv.pkg {
-				return nil, fmt.Errorf("bundle %q does not belong to this package: %q", props.Packages[0].PackageName, sv.pkg)
+	if err := validateBundleProperties(out, renderedProperties); err != nil {
+		return err
+	}
+	// added once the rendered properties are known to be intact
+	if len(sv.BundleProperties) != 0 {
+		if err := sv.addBundleProperties(out, channelBundleVersions); err != nil {
+			return err
+		}
+	}
+
+	threshold := t.SkipsWarningThreshold
+	if threshold == 0 {
+		threshold = DefaultSkipsWarningThreshold
+	}
+	if threshold > 0 {
+		for _, w := range longSkipsWarnings(out.Channels, threshold) {
+			report.warnf("%s", w)
+			if t.Log != nil {
+				t.Log.Warn(w)
 			}
-		} else {
-			// else cache the first
-			p := newPackage(props.Packages[0].PackageName)
-			cfg.Packages = append(cfg.Packages, *p)
-			sv.pkg = props.Packages[0].PackageName
 		}
+	}
 
-		if _, ok := entries[b.Name]; ok {
-			return nil, fmt.Errorf("duplicate bundle name %q", b.Name)
+	if dangling := danglingBundles(out); len(dangling) != 0 {
+		if t.FailOnDanglingBundles {
+			return fmt.Errorf("bundles %v are not entries of any channel", dangling)
+		}
+		for _, name := range dangling {
+			report.warnf("bundle %q is not an entry of any channel", name)
 		}
+	}
 
-		entries[b.Name] = v
+	if len(t.PreviousChannelNames) != 0 {
+		generated := sets.NewString()
+		for _, ch := range out.Channels {
+			generated.Insert(ch.Name)
+		}
+		for _, name := range sets.NewString(t.PreviousChannelNames...).Difference(generated).List() {
+			report.warnf("channel %q of the previous render is no longer generated", name)
+		}
+	}
+
+	if t.WarnOnSingleBundle && len(out.Bundles) == 1 {
+		report.warnf("package %q has a single bundle %q, so no upgrade graph exists yet", sv.pkg, out.Bundles[0].Name)
+	}
+
+	// inherited archetypes are expected to repeat the bundles of the more stable ones
+	if len(sv.Channels) == 0 && !sv.InheritChannels {
+		identicalArchetypeWarnings(channelBundleVersions, report)
+	}
+
+	report.Heads = headsByBundle(out.Channels)
+	report.Archetypes = sv.archetypeSummaries(channelBundleVersions, out.Channels)
+	if t.EmitPredecessors {
+		report.Predecessors = predecessorsByChannel(out.Channels, channelBundleVersions)
+	}
+	if t.EmitRenderDurations {
+		report.RenderDurations = sv.renderDurations
+	}
+	return nil
+}
+
+// newAuthFilesRegistry creates a registry whose credentials are merged from the given docker config files
+func newAuthFilesRegistry(authFiles []string) (*containerdregistry.Registry, error) {
+	cacheDir, err := os.MkdirTemp("", "semver-registry-")
+	if err != nil {
+		return nil, fmt.Errorf("create tempdir: %v", err)
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	reg, err := containerdregistry.NewRegistry(
+		containerdregistry.WithCacheDir(cacheDir),
+		containerdregistry.WithAuthFiles(authFiles...),
+		containerdregistry.WithLog(logrus.NewEntry(logger)),
+	)
+	if err != nil {
+		os.RemoveAll(cacheDir)
+		return nil, err
+	}
+	return reg, nil
+}
+
+// renderImages renders the bundle images concurrently, with at most MaxConcurrency renders in flight, returning the
+// rendered configs in the order of images.  Those of images which are also in files are rendered as bundle
+// directories, and their bundles take the path as image.  The first failure cancels the renders still in flight, and
+// every failure observed before then is returned.  When bestEffort is set, images which fail to render because ctx is
+// done are returned as unrendered instead.  The time taken by each successful render is returned, slowest first.
+func (t Template) renderImages(ctx context.Context, images []string, files sets.String, layouts sets.String, bestEffort bool) ([]declcfg.DeclarativeConfig, []string, []BundleRenderDuration, error) {
+	concurrency := t.MaxConcurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	type result struct {
+		cfg        *declcfg.DeclarativeConfig
+		err        error
+		unrendered bool
+		duration   time.Duration
+	}
+	results := make([]result, len(images))
+	renderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// workers take the images in order, so that a best-effort render reaches the images in a predictable order
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(images); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if renderCtx.Err() == nil {
+					r := action.Render{
+						AllowedRefMask: action.RefBundleImage,
+						Refs:           []string{images[i]},
+						Registry:       t.Registry,
+					}
+					if files.Has(images[i]) {
+						r.AllowedRefMask = action.RefBundleDir
+					}
+					start := time.Now()
+					var c *declcfg.DeclarativeConfig
+					var err error
+					switch {
+					case layouts.Has(images[i]):
+						c, err = renderOCILayout(renderCtx, r, images[i])
+					case t.BundleCache != nil && !files.Has(images[i]):
+						c, err = t.cachedRender(renderCtx, images[i], func() (*declcfg.DeclarativeConfig, error) { return r.Run(renderCtx) })
+					default:
+						c, err = r.Run(renderCtx)
+					}
+					results[i].duration = time.Since(start)
+					if err == nil {
+						if files.Has(images[i]) {
+							// bundle directories and OCI layouts have no image, so they are identified by their path
+							for j := range c.Bundles {
+								c.Bundles[j].Image = images[i]
+							}
+						}
+						results[i].cfg = c
+						continue
+					}
+					if renderCtx.Err() == nil {
+						// a genuine failure, which stops the remaining renders
+						results[i].err = err
+						cancel()
+						continue
+					}
+				}
+				// the render was cut short, by ctx or by another failure
+				results[i].unrendered = true
+			}
+		}()
+	}
+	for i := range images {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	var cfgs []declcfg.DeclarativeConfig
+	var unrendered []string
+	var durations []BundleRenderDuration
+	errs := []error{}
+	for i, r := range results {
+		switch {
+		case r.err != nil:
+			errs = append(errs, r.err)
+		case r.unrendered:
+			unrendered = append(unrendered, images[i])
+		default:
+			cfgs = append(cfgs, *r.cfg)
+			durations = append(durations, BundleRenderDuration{Image: images[i], Duration: r.duration})
+		}
+	}
+	switch {
+	case len(errs) == 1:
+		return nil, nil, nil, errs[0]
+	case len(errs) != 0:
+		return nil, nil, nil, errors.NewAggregate(errs)
+	case len(unrendered) != 0 && !bestEffort:
+		return nil, nil, nil, fmt.Errorf("render: %v", ctx.Err())
+	}
+	sort.SliceStable(durations, func(i, j int) bool { return durations[i].Duration > durations[j].Duration })
+	return cfgs, unrendered, durations, nil
+}
+
+// preflight reads the template and validates it before any bundles are rendered, returning it along with the
+// distinct references of its bundle entries, and those of them which are bundle files
+func (t Template) preflight() (*semverTemplate, map[string]struct{}, sets.String, error) {
+	sv, err := t.readSources()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("render: unable to read file: %w", err)
+	}
+
+	for _, pattern := range append(append([]string{}, t.IncludeChannels...), t.ExcludeChannels...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, nil, nil, fmt.Errorf("render: invalid channel pattern %q: %v", pattern, err)
+		}
+	}
+
+	if err := sv.resolveRanges(t.Catalog); err != nil {
+		return nil, nil, nil, fmt.Errorf("render: %w", err)
+	}
+
+	bundleDict := make(map[string]struct{})
+	files := sets.NewString()
+	sv.ociLayouts = sets.NewString()
+	for _, l := range sv.bundleLists() {
+		if err := buildBundleList(l, &bundleDict, files, sv.ociLayouts); err != nil {
+			return nil, nil, nil, fmt.Errorf("render: %w", err)
+		}
+	}
+
+	if len(bundleDict) == 0 {
+		// fail before any registry is set up, distinctly from the failure to render any of the listed bundles
+		return nil, nil, nil, fmt.Errorf("render: %w", &ErrNoBundleEntries{})
+	}
+
+	if err := validateOCILayouts(sv.ociLayouts.List()); err != nil {
+		return nil, nil, nil, fmt.Errorf("render: %w", err)
+	}
+
+	// bundle files are local, and so are neither image references nor subject to the allowed registries
+	images := make([]string, 0, len(bundleDict))
+	for b := range bundleDict {
+		if !files.Has(b) {
+			images = append(images, b)
+		}
+	}
+	if err := validateImageReferences(images); err != nil {
+		return nil, nil, nil, fmt.Errorf("render: %w", err)
+	}
+	if len(t.AllowedRegistries) != 0 {
+		if err := validateAllowedRegistries(images, t.AllowedRegistries); err != nil {
+			return nil, nil, nil, fmt.Errorf("render: %w", err)
+		}
+	}
+	return sv, bundleDict, files, nil
+}
+
+// RenderFromConfig generates the template's channels from a declarative config which already contains its rendered
+// bundles, instead of rendering the bundle images.  Every bundle entry of the template must match the image of one of
+// the config's bundles.  Only the matching bundles are included in the output, along with the generated package and
+// channels; the config itself is not modified.  Like Render, it consumes Data.
+func (t Template) RenderFromConfig(ctx context.Context, cfg *declcfg.DeclarativeConfig) (*declcfg.DeclarativeConfig, error) {
+	sv, bundleDict, _, err := t.preflight()
+	if err != nil {
+		return nil, err
+	}
+
+	rendered := &declcfg.DeclarativeConfig{}
+	matched := sets.NewString()
+	for _, b := range cfg.Bundles {
+		if _, ok := bundleDict[b.Image]; ok && !matched.Has(b.Image) {
+			// generation may modify the bundles' properties
+			b.Properties = append([]property.Property{}, b.Properties...)
+			rendered.Bundles = append(rendered.Bundles, b)
+			matched.Insert(b.Image)
+		}
+	}
+	if missing := sets.StringKeySet(bundleDict).Difference(matched); missing.Len() != 0 {
+		return nil, fmt.Errorf("render: bundle images %q are not in the provided config", missing.List())
+	}
+
+	out, _, err := t.generate(ctx, sv, rendered, nil)
+	return out, err
+}
+
+// dropBundles removes the given bundle images from every channel archetype and declared channel of the template
+func (sv *semverTemplate) dropBundles(images sets.String) {
+	if images.Len() == 0 {
+		return
+	}
+	drop := func(entries []semverTemplateBundleEntry) []semverTemplateBundleEntry {
+		kept := []semverTemplateBundleEntry{}
+		for _, e := range entries {
+			if !images.Has(e.ref()) {
+				kept = append(kept, e)
+			}
+		}
+		return kept
+	}
+	sv.Candidate.Bundles = drop(sv.Candidate.Bundles)
+	sv.Fast.Bundles = drop(sv.Fast.Bundles)
+	sv.Stable.Bundles = drop(sv.Stable.Bundles)
+	for _, ch := range sv.CustomChannels {
+		ch.Bundles = drop(ch.Bundles)
+	}
+	for i := range sv.Channels {
+		sv.Channels[i].Bundles = drop(sv.Channels[i].Bundles)
+	}
+}
+
+// EffectiveConfig reads the template from Data and returns it as YAML, as the renderer will interpret it, with all
+// defaults applied.  The result is itself a template which renders like the original.  Like Render, it consumes Data.
+func (t Template) EffectiveConfig() ([]byte, error) {
+	sv, err := t.readSources()
+	if err != nil {
+		return nil, fmt.Errorf("effective config: unable to read file: %w", err)
+	}
+	data, err := yaml.Marshal(sv)
+	if err != nil {
+		return nil, fmt.Errorf("effective config: %w", err)
+	}
+	return data, nil
+}
+
+// bundleLists returns the bundle lists of the template's channel archetypes, declared channels, and packages
+func (sv *semverTemplate) bundleLists() []*[]semverTemplateBundleEntry {
+	lists := []*[]semverTemplateBundleEntry{&sv.Candidate.Bundles, &sv.Fast.Bundles, &sv.Stable.Bundles}
+	for i := range sv.Packages {
+		lists = append(lists, &sv.Packages[i].Candidate.Bundles, &sv.Packages[i].Fast.Bundles, &sv.Packages[i].Stable.Bundles)
+	}
+	for _, ch := range sv.CustomChannels {
+		lists = append(lists, &ch.Bundles)
+	}
+	for i := range sv.Channels {
+		lists = append(lists, &sv.Channels[i].Bundles)
+	}
+	return lists
+}
+
+// buildBundleList adds the references of the bundle entries to dict, and those of bundle files and OCI layouts, which
+// are local, to files, and of OCI layouts to layouts
+func buildBundleList(bundles *[]semverTemplateBundleEntry, dict *map[string]struct{}, files sets.String, layouts sets.String) error {
+	for _, b := range *bundles {
+		switch {
+		case b.Image != "" && b.File != "":
+			return fmt.Errorf("bundle entry specifies both image %q and file %q, expected exactly one", b.Image, b.File)
+		case b.OCILayout != "" && (b.Image != "" || b.File != ""):
+			return fmt.Errorf("bundle entry specifies OCI layout %q along with an image or a file, expected exactly one", b.OCILayout)
+		case b.Image == "" && b.File == "" && b.OCILayout == "":
+			return fmt.Errorf("bundle entry specifies neither an image nor a file")
+		}
+		if _, ok := (*dict)[b.ref()]; !ok {
+			(*dict)[b.ref()] = struct{}{}
+		}
+		if b.File != "" {
+			files.Insert(b.File)
+		}
+		if b.OCILayout != "" {
+			files.Insert(b.OCILayout)
+			layouts.Insert(b.OCILayout)
+		}
+	}
+	return nil
+}
+
+func (t Template) readFile(reader io.Reader) (*semverTemplate, error) {
+	maxSize := t.MaxTemplateSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxTemplateSize
+	}
+	data, err := readAllWithLimits(reader, maxSize, t.ReadTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// the schema version is checked before the template is strictly unmarshaled, so that a template of another version
+	// is reported as such, rather than for the attributes this version does not know
+	var header struct {
+		Schema        string `json:"schema"`
+		SchemaVersion *int   `json:"schemaVersion,omitempty"`
+	}
+	if err := yaml.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+	if header.Schema == schema && header.SchemaVersion != nil && (*header.SchemaVersion < minSchemaVersion || *header.SchemaVersion > currentSchemaVersion) {
+		return nil, &ErrUnsupportedSchemaVersion{Version: *header.SchemaVersion}
+	}
+
+	// default behavior is to generate only minor channels
+	sv := semverTemplate{
+		SchemaVersion:         currentSchemaVersion,
+		GenerateMajorChannels: false,
+		GenerateMinorChannels: true,
+	}
+	if err := yaml.UnmarshalStrict(data, &sv); err != nil {
+		return nil, err
+	}
+	if sv.Schema != schema {
+		return nil, &ErrUnknownSchema{Schema: sv.Schema}
+	}
+	switch sv.PrereleasePolicy {
+	case "", prereleasePolicyInclude, prereleasePolicyExclude:
+	default:
+		return nil, fmt.Errorf("readFile: invalid prerelease policy %q, expected %q or %q", sv.PrereleasePolicy, prereleasePolicyInclude, prereleasePolicyExclude)
+	}
+	switch sv.BuildMetadataPolicy {
+	case "", buildMetadataPolicyError, buildMetadataPolicyOrder:
+	default:
+		return nil, fmt.Errorf("readFile: invalid build metadata policy %q, expected %q or %q", sv.BuildMetadataPolicy, buildMetadataPolicyError, buildMetadataPolicyOrder)
+	}
+	if err := sv.validateChannelPlan(); err != nil {
+		return nil, err
+	}
+	if err := sv.validateMinVersions(); err != nil {
+		return nil, err
+	}
+	if err := sv.validateCustomChannels(); err != nil {
+		return nil, err
+	}
+	if err := sv.validatePackages(); err != nil {
+		return nil, err
+	}
+	return &sv, nil
+}
+
+// readSources reads Data and each of Sources as a template, merging the bundle lists of the later templates into the
+// first.  The bundles and recommended versions of each channel archetype (or declared channel) are concatenated, and
+// channel archetypes or declared channels which are not in the first template are added; all other attributes are
+// taken from the first template.  A bundle may not be listed under the same channel archetype by more than one source.
+func (t Template) readSources() (*semverTemplate, error) {
+	if len(t.Sources) == 0 {
+		return t.readFile(t.Data)
+	}
+	readers := t.Sources
+	if t.Data != nil {
+		readers = append([]io.Reader{t.Data}, t.Sources...)
+	}
+
+	var sv *semverTemplate
+	// channel archetype --> bundle reference --> index of the source listing it
+	listed := make(map[channelArchetype]map[string]int)
+	for i, r := range readers {
+		next, err := t.readFile(r)
+		if err != nil {
+			return nil, fmt.Errorf("source %d: %w", i, err)
+		}
+		if sv == nil {
+			sv = next
+		} else {
+			sv.mergeChannels(next)
+		}
+		for _, arch := range next.templateChannels() {
+			if listed[arch] == nil {
+				listed[arch] = make(map[string]int)
+			}
+			for _, e := range next.channelBundles(arch).Bundles {
+				if e.Range != "" {
+					// resolved once the sources are merged
+					continue
+				}
+				if other, ok := listed[arch][e.ref()]; ok && other != i {
+					return nil, fmt.Errorf("readFile: bundle %q is listed under %s by both source %d and source %d", e.ref(), arch, other, i)
+				}
+				listed[arch][e.ref()] = i
+			}
+		}
+	}
+
+	if err := sv.validateChannelPlan(); err != nil {
+		return nil, err
+	}
+	if err := sv.validateCustomChannels(); err != nil {
+		return nil, err
+	}
+	if err := sv.validatePackages(); err != nil {
+		return nil, err
+	}
+	return sv, nil
+}
+
+// mergeChannels adds the bundles and recommended versions of each of other's channel archetypes, declared channels, or
+// packages, to the template's
+func (sv *semverTemplate) mergeChannels(other *semverTemplate) {
+	for _, p := range other.Packages {
+		i := 0
+		for i < len(sv.Packages) && sv.Packages[i].Name != p.Name {
+			i++
+		}
+		if i == len(sv.Packages) {
+			sv.Packages = append(sv.Packages, semverTemplatePackage{Name: p.Name})
+		}
+		for _, pair := range [][2]*semverTemplateChannelBundles{{&sv.Packages[i].Candidate, &p.Candidate}, {&sv.Packages[i].Fast, &p.Fast}, {&sv.Packages[i].Stable, &p.Stable}} {
+			dst, src := pair[0], pair[1]
+			dst.Bundles = append(dst.Bundles, src.Bundles...)
+			dst.Recommended = append(dst.Recommended, src.Recommended...)
+		}
+	}
+	for name, ch := range other.CustomChannels {
+		if _, ok := sv.CustomChannels[name]; !ok {
+			if sv.CustomChannels == nil {
+				sv.CustomChannels = make(map[string]*semverTemplateCustomChannel)
+			}
+			sv.CustomChannels[name] = &semverTemplateCustomChannel{Priority: ch.Priority}
+		}
+	}
+	for _, plan := range other.Channels {
+		found := false
+		for _, ch := range sv.Channels {
+			found = found || ch.Name == plan.Name
+		}
+		if !found {
+			sv.Channels = append(sv.Channels, semverTemplateChannelPlan{Name: plan.Name})
+		}
+	}
+	for _, arch := range other.templateChannels() {
+		src, dst := other.channelBundles(arch), sv.channelBundles(arch)
+		dst.Bundles = append(dst.Bundles, src.Bundles...)
+		dst.Recommended = append(dst.Recommended, src.Recommended...)
+	}
+}
+
+// validateChannelPlan ensures that declared channels are not mixed with channel archetypes, and are uniquely named
+func (sv *semverTemplate) validateChannelPlan() error {
+	if len(sv.Channels) == 0 {
+		return nil
+	}
+	if len(sv.Candidate.Bundles) != 0 || len(sv.Fast.Bundles) != 0 || len(sv.Stable.Bundles) != 0 || len(sv.CustomChannels) != 0 {
+		return fmt.Errorf("readFile: template may declare either channel archetypes or channels, not both")
+	}
+	if sv.InheritChannels {
+		return fmt.Errorf("readFile: declared channels cannot inherit bundles, since they are not ordered by stability")
+	}
+	names := sets.NewString()
+	for _, ch := range sv.Channels {
+		if ch.Name == "" {
+			return fmt.Errorf("readFile: declared channels must be named")
+		}
+		if len(ch.Recommended) != 0 {
+			return fmt.Errorf("readFile: declared channel %q cannot list recommended versions", ch.Name)
+		}
+		if names.Has(ch.Name) {
+			return fmt.Errorf("readFile: channel %q is declared more than once", ch.Name)
+		}
+		names.Insert(ch.Name)
+	}
+	return nil
+}
+
+// validateMinVersions ensures that the template's minimum versions, and those of its channel archetypes, are versions
+func (sv *semverTemplate) validateMinVersions() error {
+	if _, err := sv.minVersion(""); err != nil {
+		return fmt.Errorf("readFile: %v", err)
+	}
+	for _, arch := range sv.templateChannels() {
+		if _, err := sv.minVersion(arch); err != nil {
+			return fmt.Errorf("readFile: %s: %v", arch, err)
+		}
+	}
+	return nil
+}
+
+// minVersion returns the minimum version of the bundles of a channel archetype, if any: its own, else the template's
+func (sv *semverTemplate) minVersion(arch channelArchetype) (*semver.Version, error) {
+	floor := sv.MinVersion
+	if arch != "" && sv.channelBundles(arch).MinVersion != "" {
+		floor = sv.channelBundles(arch).MinVersion
+	}
+	if floor == "" {
+		return nil, nil
+	}
+	v, err := semver.Parse(floor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid minimum version %q: %v", floor, err)
+	}
+	return &v, nil
+}
+
+// pruneBelowMinVersions removes the bundles below the minimum version of their channel archetypes, returning the names
+// of the bundles pruned from any archetype.  Edges are generated among the remaining bundles, so that no replaces or
+// skips refers to a pruned bundle.
+func (sv *semverTemplate) pruneBelowMinVersions(versions *bundleVersions) []string {
+	pruned := sets.NewString()
+	for arch, bundles := range *versions {
+		// validated when the template was read
+		floor, _ := sv.minVersion(arch)
+		if floor == nil {
+			continue
+		}
+		for name, v := range bundles {
+			if v.LT(*floor) {
+				pruned.Insert(name)
+				delete(bundles, name)
+			}
+		}
+	}
+	return pruned.List()
+}
+
+// validateCustomChannels ensures that custom channel archetypes neither reuse the name nor the priority of another
+func (sv *semverTemplate) validateCustomChannels() error {
+	priorities := make(map[int]string, len(channelPriorities)+len(sv.CustomChannels))
+	for arch, p := range channelPriorities {
+		priorities[p] = string(arch)
+	}
+	names := make([]string, 0, len(sv.CustomChannels))
+	for name := range sv.CustomChannels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := channelPriorities[channelArchetype(name)]; ok {
+			return fmt.Errorf("readFile: custom channel %q has the name of a built-in channel archetype", name)
+		}
+		if name == "" {
+			return fmt.Errorf("readFile: custom channels must be named")
+		}
+		if sv.CustomChannels[name] == nil {
+			return fmt.Errorf("readFile: custom channel %q has no priority", name)
+		}
+		p := sv.CustomChannels[name].Priority
+		if other, ok := priorities[p]; ok {
+			return fmt.Errorf("readFile: custom channel %q has the same priority %d as channel archetype %q", name, p, other)
+		}
+		priorities[p] = name
+	}
+	return nil
+}
+
+// readAllWithLimits reads reader to completion, failing if more than maxSize bytes are available or, when timeout
+// is non-zero, if the read does not complete in time.  On timeout the blocked read is abandoned.
+func readAllWithLimits(reader io.Reader, maxSize int64, timeout time.Duration) ([]byte, error) {
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		// read one byte past the limit so we can tell an exactly-sized input from an oversized one
+		data, err := io.ReadAll(io.LimitReader(reader, maxSize+1))
+		done <- result{data: data, err: err}
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return nil, r.err
+		}
+		if int64(len(r.data)) > maxSize {
+			return nil, &ErrTemplateTooLarge{Limit: maxSize}
+		}
+		return r.data, nil
+	case <-expired:
+		return nil, &ErrTemplateReadTimeout{Timeout: timeout}
+	}
+}
+
+// getVersionsFromStandardChannels maps the bundles of each channel archetype (or declared channel) to their versions.
+// Errors are accumulated across the archetypes, so that all of a template's problems are reported at once; a single
+// error is returned as is.
+func (sv *semverTemplate) getVersionsFromStandardChannels(cfg *declcfg.DeclarativeConfig) (*bundleVersions, error) {
+	versions := bundleVersions{}
+
+	// declared channels are keyed by their own names in place of the archetypes
+	archs := []channelArchetype{}
+	if len(sv.Channels) != 0 {
+		for _, ch := range sv.Channels {
+			archs = append(archs, channelArchetype(ch.Name))
+		}
+	} else {
+		archs = append(archs, candidateChannelArchetype, fastChannelArchetype, stableChannelArchetype)
+		custom := make([]string, 0, len(sv.CustomChannels))
+		for name := range sv.CustomChannels {
+			custom = append(custom, name)
+		}
+		sort.Strings(custom)
+		for _, name := range custom {
+			archs = append(archs, channelArchetype(name))
+		}
+	}
+
+	errs := []error{}
+	for _, arch := range archs {
+		bdm, err := sv.getVersionsFromChannel(sv.channelBundles(arch).Bundles, cfg)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err = sv.validateBundleVersions(&bdm); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		versions[arch] = bdm
+	}
+	switch len(errs) {
+	case 0:
+	case 1:
+		return nil, errs[0]
+	default:
+		return nil, errors.NewAggregate(errs)
+	}
+
+	if err := sv.validateVersionCollisions(&versions); err != nil {
+		return nil, err
+	}
+	if sv.InheritChannels {
+		sv.inheritChannels(&versions)
+	}
+	return &versions, nil
+}
+
+// inheritChannels adds the bundles of each channel archetype to every less stable channel archetype, since promotion
+// implies that a bundle is of the quality of each archetype it was promoted through
+func (sv *semverTemplate) inheritChannels(versions *bundleVersions) {
+	// in order of increasing stability
+	archs := sv.templateChannels()
+	for i := range archs {
+		for j := 0; j < i; j++ {
+			if sv.priority(archs[j]) == sv.priority(archs[i]) {
+				continue
+			}
+			if (*versions)[archs[j]] == nil {
+				(*versions)[archs[j]] = make(map[string]semver.Version)
+			}
+			for name, v := range (*versions)[archs[i]] {
+				(*versions)[archs[j]][name] = v
+			}
+		}
+	}
+}
+
+// validateVersionCollisions ensures that each version is the same bundle in every channel archetype (or declared
+// channel) which has it, since channels linked by version would otherwise disagree about which bundle it is
+func (sv *semverTemplate) validateVersionCollisions(versions *bundleVersions) error {
+	type owner struct {
+		name string
+		arch channelArchetype
+	}
+	owners := make(map[string]owner)
+	for _, arch := range sv.templateChannels() {
+		bundles := (*versions)[arch]
+		for _, name := range sortedBundleNames(bundles) {
+			v := bundles[name].String()
+			if o, ok := owners[v]; ok && o.name != name {
+				return &ErrVersionCollision{Version: v, Bundles: []string{o.name, name}, Archetypes: []string{string(o.arch), string(arch)}}
+			}
+			if _, ok := owners[v]; !ok {
+				owners[v] = owner{name: name, arch: arch}
+			}
+		}
+	}
+	return nil
+}
+
+func (sv *semverTemplate) getVersionsFromChannel(semverBundles []semverTemplateBundleEntry, cfg *declcfg.DeclarativeConfig) (map[string]semver.Version, error) {
+	entries := make(map[string]semver.Version)
+	// version (without build metadata, unless it orders versions) --> image, to identify distinct images which claim
+	// the same version
+	type versionImage struct {
+		image   string
+		version semver.Version
+	}
+	versionImages := make(map[string]versionImage)
+
+	// we iterate over the channel bundles from the template, to:
+	// - identify if any required bundles for the channel are missing/not rendered/otherwise unavailable
+	// - maintain the channel-bundle relationship as we map from un-rendered semver template bundles to rendered bundles in `entries` which is accumulated by the caller
+	//   in a per-channel structure to which we can safely refer when generating/linking channels
+	for _, semverBundle := range semverBundles {
+		// test if the bundle specified in the template is present in the successfully-rendered bundles
+		index := 0
+		for index < len(cfg.Bundles) {
+			if cfg.Bundles[index].Image == semverBundle.ref() {
+				break
+			}
+			index++
+		}
+		if index == len(cfg.Bundles) {
+			return nil, &ErrBundleNotRendered{Image: semverBundle.ref()}
+		}
+		b := cfg.Bundles[index]
+
+		pkg, err := bundlePackage(b)
+		if err != nil {
+			return nil, err
+		}
+		v, err := semver.Parse(pkg.Version)
+		if err != nil {
+			return nil, &ErrInvalidVersion{Bundle: b.Name, Version: pkg.Version, Err: err}
+		}
+
+		// package name detection
+		if sv.pkg != "" {
+			// if we have a known package name, then ensure all subsequent packages match
+			if pkg.PackageName != sv.pkg {
+				return nil, fmt.Errorf("bundle %q (image %q) belongs to package %q, not to the template's package %q", b.Name, b.Image, pkg.PackageName, sv.pkg)
+			}
+		} else {
+			// else cache the first, unless it was rendered
+			found := false
+			for _, p := range cfg.Packages {
+				found = found || p.Name == pkg.PackageName
+			}
+			if !found {
+				cfg.Packages = append(cfg.Packages, *newPackage(pkg.PackageName))
+			}
+			sv.pkg = pkg.PackageName
+		}
+
+		if sv.platform != nil {
+			supported, err := sv.platform.supportedBy(b)
+			if err != nil {
+				return nil, err
+			}
+			if !supported {
+				continue
+			}
+		}
+
+		// build metadata does not distinguish versions, unless the template orders by it
+		key := v.String()
+		if sv.BuildMetadataPolicy != buildMetadataPolicyOrder {
+			key = stripBuildMetadata(v)
+		}
+		if prev, ok := versionImages[key]; ok && prev.image != semverBundle.ref() {
+			dup := &ErrDuplicateVersion{Images: []string{prev.image, semverBundle.ref()}, Version: key}
+			if prev.version.String() != v.String() {
+				// the versions differ only by build metadata
+				return nil, &ErrBuildMetadataConflict{Versions: []string{v.String()}, Err: dup}
+			}
+			return nil, dup
+		}
+		versionImages[key] = versionImage{image: semverBundle.ref(), version: v}
+
+		if _, ok := entries[b.Name]; ok {
+			return nil, fmt.Errorf("duplicate bundle name %q", b.Name)
+		}
+
+		entries[b.Name] = v
+	}
+
+	return entries, nil
+}
+
+// renamePackage rewrites every reference to the detected package so that it uses the target name instead: the
+// package object, each bundle's package and olm.package property, and (via sv.pkg) the channels generated later
+func (sv *semverTemplate) renamePackage(cfg *declcfg.DeclarativeConfig, target string) error {
+	source := sv.pkg
+	if source == target {
+		return nil
+	}
+	for _, p := range cfg.Packages {
+		if p.Name == target {
+			return fmt.Errorf("cannot rename package %q to %q: package %q already exists", source, target, target)
+		}
+	}
+
+	for i := range cfg.Packages {
+		if cfg.Packages[i].Name == source {
+			cfg.Packages[i].Name = target
+		}
+	}
+	for i := range cfg.Channels {
+		if cfg.Channels[i].Package == source {
+			cfg.Channels[i].Package = target
+		}
+	}
+	for i := range cfg.Bundles {
+		b := &cfg.Bundles[i]
+		if b.Package != source {
+			continue
+		}
+		b.Package = target
+		for j, prop := range b.Properties {
+			if prop.Type != property.TypePackage {
+				continue
+			}
+			var pkg property.Package
+			if err := json.Unmarshal(prop.Value, &pkg); err != nil {
+				return fmt.Errorf("parse properties for bundle %q: %v", b.Name, err)
+			}
+			if pkg.PackageName == source {
+				b.Properties[j] = property.MustBuildPackage(target, pkg.Version)
+			}
+		}
+	}
+	sv.pkg = target
+	return nil
+}
+
+// entryNameData is the data available to the entry name template
+type entryNameData struct {
+	Package    string
+	Version    string
+	BundleName string
+}
+
+// renameBundles renames every rendered bundle according to the entry name template, and re-keys the channel
+// archetype versions accordingly, so that channels subsequently generated refer to the new names throughout
+func renameBundles(cfg *declcfg.DeclarativeConfig, versions *bundleVersions, nameTemplate string) error {
+	tmpl, err := template.New("entryName").Option("missingkey=error").Parse(nameTemplate)
+	if err != nil {
+		return fmt.Errorf("invalid entry name template: %v", err)
+	}
+
+	renamed := make(map[string]string, len(cfg.Bundles))
+	used := make(map[string]string, len(cfg.Bundles))
+	for i, b := range cfg.Bundles {
+		pkg, err := bundlePackage(b)
+		if err != nil {
+			return err
+		}
+		var name strings.Builder
+		if err := tmpl.Execute(&name, entryNameData{Package: pkg.PackageName, Version: pkg.Version, BundleName: b.Name}); err != nil {
+			return fmt.Errorf("evaluate entry name template for bundle %q: %v", b.Name, err)
+		}
+		if name.Len() == 0 {
+			return fmt.Errorf("entry name template produced an empty name for bundle %q", b.Name)
+		}
+		if other, ok := used[name.String()]; ok && other != b.Name {
+			return fmt.Errorf("entry name template produced name %q for both bundle %q and %q", name.String(), other, b.Name)
+		}
+		used[name.String()] = b.Name
+		renamed[b.Name] = name.String()
+		cfg.Bundles[i].Name = name.String()
+	}
+
+	for arch, bundles := range *versions {
+		rekeyed := make(map[string]semver.Version, len(bundles))
+		for name, v := range bundles {
+			rekeyed[renamed[name]] = v
+		}
+		(*versions)[arch] = rekeyed
+	}
+	return nil
+}
+
+// disambiguateBundleNames renames each rendered bundle which shares its name with another as <name>-v<version>, and
+// fails if the resulting names are still not unique
+func disambiguateBundleNames(cfg *declcfg.DeclarativeConfig) error {
+	byName := make(map[string][]int)
+	for i, b := range cfg.Bundles {
+		byName[b.Name] = append(byName[b.Name], i)
+	}
+	for name, indices := range byName {
+		if len(indices) < 2 {
+			continue
+		}
+		for _, i := range indices {
+			pkg, err := bundlePackage(cfg.Bundles[i])
+			if err != nil {
+				return err
+			}
+			cfg.Bundles[i].Name = fmt.Sprintf("%s-v%s", name, pkg.Version)
+		}
+	}
+
+	images := make(map[string]string, len(cfg.Bundles))
+	for _, b := range cfg.Bundles {
+		if other, ok := images[b.Name]; ok {
+			return fmt.Errorf("bundle images %q and %q have the same name %q, even when disambiguated by version", other, b.Image, b.Name)
+		}
+		images[b.Name] = b.Image
+	}
+	return nil
+}
+
+// filterVersions drops every bundle whose version does not satisfy the range from all channel archetypes
+func filterVersions(versions *bundleVersions, keep semver.Range) {
+	for _, bundles := range *versions {
+		for name, v := range bundles {
+			if !keep(v) {
+				delete(bundles, name)
+			}
+		}
+	}
+}
+
+// excludePrereleases drops every bundle with a prerelease version from all channel archetypes, returning the (sorted)
+// names of the dropped bundles
+func excludePrereleases(versions *bundleVersions) []string {
+	excluded := sets.NewString()
+	for _, bundles := range *versions {
+		for name, v := range bundles {
+			if len(v.Pre) != 0 {
+				excluded.Insert(name)
+				delete(bundles, name)
+			}
+		}
+	}
+	return excluded.List()
+}
+
+// reduceToHeads removes every bundle but the head from the channel archetypes marked as head-only.  The head is the
+// highest version, or the highest version flagged as a channel head, if any.  It returns the names of the removed
+// bundles which are not listed under any other channel archetype.
+func (sv *semverTemplate) reduceToHeads(versions *bundleVersions) sets.String {
+	removed := sets.NewString()
+	for _, arch := range sv.templateChannels() {
+		bundles := (*versions)[arch]
+		if !sv.channelBundles(arch).HeadOnly || len(bundles) == 0 {
+			continue
+		}
+		head := ""
+		for _, name := range sortedBundleNames(bundles) {
+			if flagged := sv.heads[arch].Has(name); sv.heads[arch].Len() != 0 && !flagged {
+				continue
+			}
+			if head == "" || sv.versionLess(arch, head, bundles[head], name, bundles[name]) {
+				head = name
+			}
+		}
+		for name := range bundles {
+			if name != head {
+				delete(bundles, name)
+				removed.Insert(name)
+			}
+		}
+	}
+	for _, bundles := range *versions {
+		for name := range bundles {
+			removed.Delete(name)
+		}
+	}
+	return removed
+}
+
+// bundlePackage returns the package property of a bundle, which must have exactly one.  Required packages are
+// dependencies of the bundle, and are not considered.
+func bundlePackage(b declcfg.Bundle) (*property.Package, error) {
+	props, err := property.Parse(b.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("parse properties for bundle %q: %v", b.Name, err)
+	}
+	switch len(props.Packages) {
+	case 1:
+		return &props.Packages[0], nil
+	case 0:
+		return nil, fmt.Errorf("bundle %q (image %q) has no %q property", b.Name, b.Image, property.TypePackage)
+	}
+	names := make([]string, 0, len(props.Packages))
+	for _, p := range props.Packages {
+		names = append(names, p.PackageName)
+	}
+	return nil, fmt.Errorf("bundle %q (image %q) has %d %q properties, for packages %q, expected exactly 1", b.Name, b.Image, len(props.Packages), property.TypePackage, names)
+}
+
+// pruneBundles removes the rendered bundles which are no longer referenced by any channel archetype
+func pruneBundles(cfg *declcfg.DeclarativeConfig, versions *bundleVersions) {
+	referenced := sets.NewString()
+	for _, bundles := range *versions {
+		for name := range bundles {
+			referenced.Insert(name)
+		}
+	}
+
+	bundles := cfg.Bundles[:0]
+	for _, b := range cfg.Bundles {
+		if referenced.Has(b.Name) {
+			bundles = append(bundles, b)
+		}
+	}
+	cfg.Bundles = bundles
+}
+
+// generateRecommendedChannel generates the curated channel of the versions each archetype recommends, if any, with
+// each entry replacing the next-lowest one
+func (sv *semverTemplate) generateRecommendedChannel(semverChannels *bundleVersions) (*declcfg.Channel, error) {
+	recommended := make(map[string]semver.Version)
+	errs := []error{}
+	for _, arch := range sv.templateChannels() {
+		for _, rv := range sv.channelBundles(arch).Recommended {
+			v, err := semver.Parse(rv)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s recommended version %q: %v", arch, rv, err))
+				continue
+			}
+			found := ""
+			for name, bv := range (*semverChannels)[arch] {
+				if bv.EQ(v) {
+					found = name
+				}
+			}
+			if found == "" {
+				errs = append(errs, fmt.Errorf("%s recommended version %q is not one of its bundles", arch, rv))
+				continue
+			}
+			for name, other := range recommended {
+				if name != found && other.EQ(v) {
+					errs = append(errs, fmt.Errorf("recommended version %q refers to both bundle %q and %q", rv, name, found))
+				}
+			}
+			recommended[found] = v
+		}
+	}
+	if len(errs) != 0 {
+		return nil, fmt.Errorf("invalid recommended versions: %v", errors.NewAggregate(errs))
+	}
+	if len(recommended) == 0 {
+		return nil, nil
+	}
+
+	names := sortedBundleNames(recommended)
+	sort.SliceStable(names, func(i, j int) bool {
+		return recommended[names[i]].LT(recommended[names[j]])
+	})
+	ch := newChannel(sv.pkg, recommendedChannelName)
+	for i, name := range names {
+		entry := declcfg.ChannelEntry{Name: name}
+		if i > 0 {
+			entry.Replaces = names[i-1]
+		}
+		ch.Entries = append(ch.Entries, entry)
+	}
+	return ch, nil
+}
+
+// channelNamePattern matches the channel names a ChannelClassifier may return
+var channelNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// classifyChannels records the channels the classifier assigns to each bundle of each channel archetype, ensuring
+// that the channel names are well-formed, and that no channel mixes archetypes
+func (sv *semverTemplate) classifyChannels(classifier func(version semver.Version, archetype string) []string, versions *bundleVersions) error {
+	sv.classified = make(map[channelArchetype]map[string][]string)
+	channelArchetypes := make(map[string]channelArchetype)
+	mixed := sets.NewString()
+	errs := []error{}
+	for _, arch := range sv.templateChannels() {
+		bundles := (*versions)[arch]
+		sv.classified[arch] = make(map[string][]string, len(bundles))
+		for _, name := range sortedBundleNames(bundles) {
+			names := classifier(bundles[name], string(arch))
+			seen := sets.NewString()
+			for _, cName := range names {
+				switch {
+				case !channelNamePattern.MatchString(cName):
+					errs = append(errs, fmt.Errorf("invalid channel name %q for %s bundle %q", cName, arch, name))
+					continue
+				case seen.Has(cName):
+					errs = append(errs, fmt.Errorf("channel %q returned more than once for %s bundle %q", cName, arch, name))
+					continue
+				}
+				seen.Insert(cName)
+				if other, ok := channelArchetypes[cName]; ok && other != arch {
+					if !mixed.Has(cName) {
+						errs = append(errs, fmt.Errorf("channel %q is returned for both %s and %s bundles", cName, other, arch))
+						mixed.Insert(cName)
+					}
+					continue
+				}
+				channelArchetypes[cName] = arch
+				sv.classified[arch][name] = append(sv.classified[arch][name], cName)
+			}
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid classified channels: %v", errors.NewAggregate(errs))
+	}
+	return nil
+}
+
+// generates an unlinked channel for each channel as per the input template config (major || minor), then link up the edges of the set of channels so that:
+// - for minor version increase, the new edge replaces the previous
+// - (for major channels) iterating to a new minor version channel (traversing between Y-streams) creates a 'replaces' edge between the predecessor and successor bundles
+// - within the same minor version (Y-stream), the head of the channel should have a 'skips' encompassing all lesser Y.Z versions of the bundle enumerated in the template.
+// along the way, uses a highwaterChannel marker to identify the "most stable" channel head to be used as the default channel for the generated package
+
+func (sv *semverTemplate) generateChannels(ctx context.Context, semverChannels *bundleVersions) ([]declcfg.Channel, error) {
+	outChannels := []declcfg.Channel{}
+
+	// the channel archetypes in ascending order, so we can traverse the bundles in order of their source channel's priority
+	archetypesByPriority := sv.templateChannels()
+
+	// default channel candidates, in order of creation
+	candidates := []highwaterChannel{}
+
+	unlinkedChannels := make(map[string]*declcfg.Channel)
+	unassociatedEdges := []entryTuple{}
+
+	for _, archetype := range archetypesByPriority {
+		bundles := (*semverChannels)[archetype]
+		// skip channel if empty
+		if len(bundles) == 0 {
+			continue
+		}
+
+		// sort the bundle names according to their semver, so we can walk in ascending order
+		bundleNamesByVersion := []string{}
+		for b := range bundles {
+			bundleNamesByVersion = append(bundleNamesByVersion, b)
+		}
+		sort.Slice(bundleNamesByVersion, func(i, j int) bool {
+			return sv.versionLess(archetype, bundleNamesByVersion[i], bundles[bundleNamesByVersion[i]], bundleNamesByVersion[j], bundles[bundleNamesByVersion[j]])
+		})
+
+		// for each bundle (by version):
+		//   for each of Major/Minor setting (since they're independent)
+		//     retrieve the existing channel object, or create a channel (by criteria major/minor) if one doesn't exist
+		//     add a new edge entry based on the bundle name
+		//     save the channel name --> channel archetype mapping
+		//     test the channel object for 'more stable' than previous best
+		for _, bundleName := range bundleNamesByVersion {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			// classified channels replace the major and minor channels; each is linked on its own below
+			if sv.classified != nil {
+				for _, cName := range sv.classified[archetype][bundleName] {
+					ch, ok := unlinkedChannels[cName]
+					if !ok {
+						ch = newChannel(sv.pkg, cName)
+						unlinkedChannels[cName] = ch
+						sv.recordChannelOrigin(cName, archetype, "")
+						candidates = append(candidates, highwaterChannel{archetype: archetype, priority: sv.priority(archetype), kind: majorStreamType, version: bundles[bundleName], name: cName})
+					}
+					ch.Entries = append(ch.Entries, declcfg.ChannelEntry{Name: bundleName})
+					unassociatedEdges = append(unassociatedEdges, entryTuple{arch: archetype, kind: majorStreamType, parent: cName, name: bundleName, version: bundles[bundleName], index: len(ch.Entries) - 1, head: sv.heads[archetype].Has(bundleName)})
+				}
+				continue
+			}
+
+			// a dodge to avoid duplicating channel processing body; accumulate a map of the channels which need creating from the bundle
+			// we need to associate by kind so we can partition the resulting entries
+			channelNameKeys := make(map[streamType]string)
+			if sv.GenerateMajorChannels {
+				channelNameKeys[majorStreamType] = sv.channelNameFromMajor(archetype, bundles[bundleName])
+			}
+			if sv.GenerateMinorChannels {
+				channelNameKeys[minorStreamType] = sv.channelNameFromMinor(archetype, bundles[bundleName])
+			}
+
+			// visit the kinds in a fixed order, so that ties in default channel selection are broken deterministically
+			for _, cKey := range []streamType{minorStreamType, majorStreamType} {
+				cName, ok := channelNameKeys[cKey]
+				if !ok {
+					continue
+				}
+				ch, ok := unlinkedChannels[cName]
+				if !ok {
+					ch = newChannel(sv.pkg, cName)
+
+					unlinkedChannels[cName] = ch
+					sv.recordChannelOrigin(cName, archetype, cKey)
+
+					candidates = append(candidates, highwaterChannel{archetype: archetype, priority: sv.priority(archetype), kind: cKey, version: bundles[bundleName], name: cName})
+				}
+				ch.Entries = append(ch.Entries, declcfg.ChannelEntry{Name: bundleName})
+				unassociatedEdges = append(unassociatedEdges, entryTuple{arch: archetype, kind: cKey, parent: cName, name: bundleName, version: bundles[bundleName], index: len(ch.Entries) - 1, head: sv.heads[archetype].Has(bundleName)})
+			}
+		}
+	}
+
+	// entries were appended in ascending version order, so the last is the channel head unless one was flagged
+	headVersion := func(c highwaterChannel) semver.Version {
+		entries := unlinkedChannels[c.name].Entries
+		for _, e := range entries {
+			if sv.heads[c.archetype].Has(e.Name) {
+				return (*semverChannels)[c.archetype][e.Name]
+			}
+		}
+		return (*semverChannels)[c.archetype][entries[len(entries)-1].Name]
+	}
+
+	// entries were appended in ascending version order, so the first is the channel tail
+	tailVersion := func(c highwaterChannel) semver.Version {
+		return (*semverChannels)[c.archetype][unlinkedChannels[c.name].Entries[0].Name]
+	}
+
+	// set to the least-priority channel
+	hwc := highwaterChannel{archetype: archetypesByPriority[0], priority: sv.priority(archetypesByPriority[0]), version: semver.Version{Major: 0, Minor: 0}}
+	var diag *DefaultChannelDiagnostics
+	if sv.diagnostics != nil {
+		diag = &sv.diagnostics.DefaultChannel
+		diag.Strategy = sv.defaultChannelStrategy()
+	}
+	// record each candidate as it is compared, with the reason it was ignored, if it was
+	compare := func(c highwaterChannel, ignored string) {
+		if diag != nil {
+			diag.Candidates = append(diag.Candidates, DefaultChannelCandidate{
+				Channel:   c.name,
+				Archetype: string(c.archetype),
+				Priority:  c.priority,
+				Kind:      string(c.kind),
+				Version:   c.version.String(),
+				Head:      headVersion(c).String(),
+				Ignored:   ignored,
+			})
+		}
+	}
+	for _, c := range candidates {
+		if !sv.includesChannel(c.name) {
+			compare(c, "excluded from the output")
+			continue
+		}
+		if sv.DefaultChannelOverride != "" {
+			if c.name == sv.DefaultChannelOverride {
+				compare(c, "")
+				hwc = c
+				break
+			}
+			compare(c, "not the default channel override")
+			continue
+		}
+		if sv.defaultChannelRange != nil && !sv.defaultChannelRange(headVersion(c)) {
+			compare(c, "head version does not satisfy the default channel version range")
+			continue
+		}
+		compare(c, "")
+		if sv.defaultEntryTail {
+			// prefer the most stable archetype, then the lowest tail, then minor over major channels
+			if hwc.name == "" || c.priority > hwc.priority ||
+				(c.archetype == hwc.archetype && tailVersion(c).LT(tailVersion(hwc))) {
+				hwc = c
+			}
+			continue
+		}
+		if sv.cascadingDefault {
+			// strictly prefer the most stable archetype, then the highest head, then minor over major channels
+			if hwc.name == "" || c.priority > hwc.priority ||
+				(c.archetype == hwc.archetype && (headVersion(c).GT(headVersion(hwc)) ||
+					(headVersion(c).EQ(headVersion(hwc)) && streamTypePriorities[c.kind] < streamTypePriorities[hwc.kind]))) {
+				hwc = c
+			}
+			continue
+		}
+		if c.gt(&hwc) {
+			hwc = c
+		}
+	}
+
+	// save off the name of the high-water-mark channel for the default for this package
+	sv.defaultChannel = hwc.name
+	if diag != nil && hwc.name != "" {
+		diag.Selected, diag.Archetype, diag.Version = hwc.name, string(hwc.archetype), hwc.version.String()
+	}
+	if hwc.name != "" {
+		head := headVersion(hwc)
+		sv.defaultHead = &head
+		sv.defaultArch = hwc.archetype
+	}
+
+	var linked []declcfg.Channel
+	if sv.classified != nil {
+		// classified channels are not partitioned by version, so link each separately
+		edges := make(map[string][]entryTuple, len(unlinkedChannels))
+		for _, e := range unassociatedEdges {
+			edges[e.parent] = append(edges[e.parent], e)
+		}
+		for name, ch := range unlinkedChannels {
+			channels, err := sv.linkChannels(ctx, map[string]*declcfg.Channel{name: ch}, edges[name])
+			if err != nil {
+				return nil, err
+			}
+			linked = append(linked, channels...)
+		}
+	} else {
+		var err error
+		if linked, err = sv.linkChannels(ctx, unlinkedChannels, unassociatedEdges); err != nil {
+			return nil, err
+		}
+	}
+	for _, ch := range linked {
+		if sv.includesChannel(ch.Name) {
+			outChannels = append(outChannels, ch)
+		}
+	}
+	// classified channels are linked one at a time
+	sort.Slice(outChannels, func(i, j int) bool { return outChannels[i].Name < outChannels[j].Name })
+
+	return outChannels, nil
+}
+
+// includesChannel reports whether a generated channel is kept in the output, according to the include and exclude
+// patterns (which have already been validated)
+func (sv *semverTemplate) includesChannel(name string) bool {
+	included := len(sv.includeChannels) == 0
+	for _, pattern := range sv.includeChannels {
+		if ok, _ := path.Match(pattern, name); ok {
+			included = true
+			break
+		}
+	}
+	for _, pattern := range sv.excludeChannels {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	return included
+}
+
+// annotateDefaultEntry adds a property to the package recording the lowest version entry of the default channel as its
+// recommended starting point for new installations.  The default channel must be among the generated channels.
+func (sv *semverTemplate) annotateDefaultEntry(pkg *declcfg.Package, channels []declcfg.Channel, versions *bundleVersions) error {
+	for _, ch := range channels {
+		if ch.Name != sv.defaultChannel {
+			continue
+		}
+		var tail string
+		var tailVersion semver.Version
+		for _, e := range ch.Entries {
+			for _, bundles := range *versions {
+				if v, ok := bundles[e.Name]; ok && (tail == "" || v.LT(tailVersion)) {
+					tail, tailVersion = e.Name, v
+				}
+			}
+		}
+		value, err := json.Marshal(defaultEntry{Bundle: tail, Version: tailVersion.String()})
+		if err != nil {
+			return err
+		}
+		pkg.Properties = append(pkg.Properties, property.Property{Type: defaultEntryPropertyType, Value: value})
+		return nil
 	}
-
-	return entries, nil
+	return fmt.Errorf("default channel %q not found", sv.defaultChannel)
 }
 
-// generates an unlinked channel for each channel as per the input template config (major || minor), then link up the edges of the set of channels so that:
-// - for minor version increase, the new edge replaces the previous
-// - (for major channels) iterating to a new minor version channel (traversing between Y-streams) creates a 'replaces' edge between the predecessor and successor bundles
-// - within the same minor version (Y-stream), the head of the channel should have a 'skips' encompassing all lesser Y.Z versions of the bundle enumerated in the template.
-// along the way, uses a highwaterChannel marker to identify the "most stable" channel head to be used as the default channel for the generated package
-
-func (sv *semverTemplate) generateChannels(semverChannels *bundleVersions) []declcfg.Channel {
+// generatePlannedChannels generates each declared channel with exactly its declared bundles, linked by the same rules
+// as generated channels.  Since declared channels are listed in order of increasing stability, the last non-empty one
+// is the default channel.
+func (sv *semverTemplate) generatePlannedChannels(ctx context.Context, semverChannels *bundleVersions) ([]declcfg.Channel, error) {
 	outChannels := []declcfg.Channel{}
-
-	// sort the channel archetypes in ascending order so we can traverse the bundles in order of
-	// their source channel's priority
-	var archetypesByPriority []channelArchetype
-	for k := range channelPriorities {
-		archetypesByPriority = append(archetypesByPriority, k)
-	}
-	sort.Sort(byChannelPriority(archetypesByPriority))
-
-	// set to the least-priority channel
-	hwc := highwaterChannel{archetype: archetypesByPriority[0], version: semver.Version{Major: 0, Minor: 0}}
-
-	unlinkedChannels := make(map[string]*declcfg.Channel)
-	unassociatedEdges := []entryTuple{}
-
-	for _, archetype := range archetypesByPriority {
-		bundles := (*semverChannels)[archetype]
-		// skip channel if empty
-		if len(bundles) == 0 {
+	heads := map[string]semver.Version{}
+	for _, plan := range sv.Channels {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		bundles := (*semverChannels)[channelArchetype(plan.Name)]
+		if len(bundles) == 0 || !sv.includesChannel(plan.Name) {
 			continue
 		}
 
-		// sort the bundle names according to their semver, so we can walk in ascending order
 		bundleNamesByVersion := []string{}
 		for b := range bundles {
 			bundleNamesByVersion = append(bundleNamesByVersion, b)
 		}
 		sort.Slice(bundleNamesByVersion, func(i, j int) bool {
-			return bundles[bundleNamesByVersion[i]].LT(bundles[bundleNamesByVersion[j]])
+			return sv.versionLess(channelArchetype(plan.Name), bundleNamesByVersion[i], bundles[bundleNamesByVersion[i]], bundleNamesByVersion[j], bundles[bundleNamesByVersion[j]])
 		})
 
-		// for each bundle (by version):
-		//   for each of Major/Minor setting (since they're independent)
-		//     retrieve the existing channel object, or create a channel (by criteria major/minor) if one doesn't exist
-		//     add a new edge entry based on the bundle name
-		//     save the channel name --> channel archetype mapping
-		//     test the channel object for 'more stable' than previous best
+		ch := newChannel(sv.pkg, plan.Name)
+		sv.recordChannelOrigin(plan.Name, channelArchetype(plan.Name), "")
+		edges := []entryTuple{}
 		for _, bundleName := range bundleNamesByVersion {
-			// a dodge to avoid duplicating channel processing body; accumulate a map of the channels which need creating from the bundle
-			// we need to associate by kind so we can partition the resulting entries
-			channelNameKeys := make(map[streamType]string)
-			if sv.GenerateMajorChannels {
-				channelNameKeys[majorStreamType] = channelNameFromMajor(archetype, bundles[bundleName])
-			}
-			if sv.GenerateMinorChannels {
-				channelNameKeys[minorStreamType] = channelNameFromMinor(archetype, bundles[bundleName])
-			}
-
-			for cKey, cName := range channelNameKeys {
-				ch, ok := unlinkedChannels[cName]
-				if !ok {
-					ch = newChannel(sv.pkg, cName)
-
-					unlinkedChannels[cName] = ch
+			ch.Entries = append(ch.Entries, declcfg.ChannelEntry{Name: bundleName})
+			edges = append(edges, entryTuple{arch: channelArchetype(plan.Name), kind: majorStreamType, parent: plan.Name, name: bundleName, version: bundles[bundleName], index: len(ch.Entries) - 1, head: sv.heads[channelArchetype(plan.Name)].Has(bundleName)})
+		}
 
-					hwcCandidate := highwaterChannel{archetype: archetype, version: bundles[bundleName], name: cName}
-					if hwcCandidate.gt(&hwc) {
-						hwc = hwcCandidate
-					}
-				}
-				ch.Entries = append(ch.Entries, declcfg.ChannelEntry{Name: bundleName})
-				unassociatedEdges = append(unassociatedEdges, entryTuple{arch: archetype, kind: cKey, parent: cName, name: bundleName, version: bundles[bundleName], index: len(ch.Entries) - 1})
+		linked, err := sv.linkChannels(ctx, map[string]*declcfg.Channel{plan.Name: ch}, edges)
+		if err != nil {
+			return nil, err
+		}
+		outChannels = append(outChannels, linked...)
+		sv.defaultChannel = plan.Name
+		for i := range linked {
+			if head, err := channelHead(&linked[i]); err == nil {
+				heads[plan.Name] = bundles[head]
 			}
 		}
 	}
-
-	// save off the name of the high-water-mark channel for the default for this package
-	sv.defaultChannel = hwc.name
-
-	outChannels = append(outChannels, sv.linkChannels(unlinkedChannels, unassociatedEdges)...)
-
-	return outChannels
+	// record the declared default, or its override, for the default channel hook
+	selected := sv.defaultChannel
+	if sv.DefaultChannelOverride != "" {
+		selected = sv.DefaultChannelOverride
+	}
+	if head, ok := heads[selected]; ok {
+		sv.defaultHead = &head
+		sv.defaultArch = channelArchetype(selected)
+	}
+	if sv.diagnostics != nil {
+		diag := DefaultChannelDiagnostics{Strategy: DefaultChannelStrategyDeclared, Selected: sv.defaultChannel}
+		if sv.DefaultChannelOverride != "" {
+			diag = DefaultChannelDiagnostics{Strategy: DefaultChannelStrategyOverride, Selected: sv.DefaultChannelOverride}
+		}
+		diag.Archetype = diag.Selected
+		sv.diagnostics.DefaultChannel = diag
+	}
+	return outChannels, nil
 }
 
-func (sv *semverTemplate) linkChannels(unlinkedChannels map[string]*declcfg.Channel, entries []entryTuple) []declcfg.Channel {
+func (sv *semverTemplate) linkChannels(ctx context.Context, unlinkedChannels map[string]*declcfg.Channel, entries []entryTuple) ([]declcfg.Channel, error) {
 	channels := []declcfg.Channel{}
+	if len(entries) == 0 {
+		return channels, nil
+	}
 
-	// sort to force partitioning by archetype --> kind --> semver
+	// sort to force partitioning by archetype --> kind --> semver, except that a bundle flagged as its channel's head is
+	// the terminal entry of that channel
 	sort.Slice(entries, func(i, j int) bool {
-		if channelPriorities[entries[i].arch] != channelPriorities[entries[j].arch] {
-			return channelPriorities[entries[i].arch] < channelPriorities[entries[j].arch]
+		if sv.priority(entries[i].arch) != sv.priority(entries[j].arch) {
+			return sv.priority(entries[i].arch) < sv.priority(entries[j].arch)
 		}
 		if streamTypePriorities[entries[i].kind] != streamTypePriorities[entries[j].kind] {
 			return streamTypePriorities[entries[i].kind] < streamTypePriorities[entries[j].kind]
 		}
-		return entries[i].version.LT(entries[j].version)
+		if entries[i].parent == entries[j].parent && entries[i].head != entries[j].head {
+			return entries[j].head
+		}
+		return sv.versionLess(entries[i].arch, entries[i].name, entries[i].version, entries[j].name, entries[j].version)
 	})
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if sv.GenerateSkipRange {
+		return sv.linkSkipRangeChannels(ctx, unlinkedChannels, entries)
+	}
+
 	prevZMax := ""
 	var curSkips sets.String = sets.NewString()
 
 	for index := 1; index < len(entries); index++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		prevTuple := entries[index-1]
 		curTuple := entries[index]
 		prevX := getMajorVersion(prevTuple.version)
@@ -307,6 +2003,10 @@ func (sv *semverTemplate) linkChannels(unlinkedChannels map[string]*declcfg.Chan
 			// we don't maintain skips/replaces over these transitions
 			curSkips = sets.NewString()
 			prevZMax = ""
+			// unless explicitly requested to seed the new major version's first Y-stream with a replaces edge from the prior major's head
+			if !archChange && !kindChange && sv.channelBundles(curTuple.arch).SeedFromPrevious {
+				prevZMax = prevTuple.name
+			}
 		} else {
 			if yChange {
 				prevZMax = prevTuple.name
@@ -327,19 +2027,525 @@ func (sv *semverTemplate) linkChannels(unlinkedChannels map[string]*declcfg.Chan
 		finalEntry.Skips = curSkips.List()
 	}
 
-	for _, ch := range unlinkedChannels {
-		channels = append(channels, *ch)
+	return append(channels, sortedChannels(unlinkedChannels)...), nil
+}
+
+// sortedChannels returns the channels ordered by name, so that the output is reproducible
+func sortedChannels(channels map[string]*declcfg.Channel) []declcfg.Channel {
+	sorted := make([]declcfg.Channel, 0, len(channels))
+	for _, ch := range channels {
+		sorted = append(sorted, *ch)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
+
+// linkSkipRangeChannels links entries, sorted as for linkChannels, without discrete skips: each entry replaces the
+// next-lower version of its major version (and the first of a major version, if seeded, the prior major's head), so that
+// every entry is on the replaces chain, and each Y-stream head has a skipRange covering the lower versions of its Y-stream
+func (sv *semverTemplate) linkSkipRangeChannels(ctx context.Context, unlinkedChannels map[string]*declcfg.Channel, entries []entryTuple) ([]declcfg.Channel, error) {
+	sameStream := func(a, b entryTuple) bool {
+		return a.arch == b.arch && a.kind == b.kind && getMinorVersion(a.version).EQ(getMinorVersion(b.version))
+	}
+
+	// the lowest version of the current Y-stream, the lower bound of its head's skipRange
+	yMin := entries[0].version
+	for index, cur := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		entry := &unlinkedChannels[cur.parent].Entries[cur.index]
+		if index > 0 {
+			prev := entries[index-1]
+			partition := prev.arch == cur.arch && prev.kind == cur.kind
+			if partition && (getMajorVersion(prev.version).EQ(getMajorVersion(cur.version)) || sv.channelBundles(cur.arch).SeedFromPrevious) {
+				entry.Replaces = prev.name
+			}
+			if !sameStream(prev, cur) {
+				yMin = cur.version
+			}
+		}
+		if index == len(entries)-1 || !sameStream(cur, entries[index+1]) {
+			entry.SkipRange = skipRange(yMin, cur.version)
+		}
+	}
+
+	return sortedChannels(unlinkedChannels), nil
+}
+
+// skipVersions adds the bundles of the template's skip versions to the skips of their successors: the next-higher
+// entries of the channels they are in
+func (sv *semverTemplate) skipVersions(channels []declcfg.Channel, versions *bundleVersions) error {
+	bundleVersion := make(map[string]semver.Version)
+	for _, bundles := range *versions {
+		for name, v := range bundles {
+			bundleVersion[name] = v
+		}
+	}
+
+	skipped := make([]semver.Version, 0, len(sv.SkipVersions))
+	errs := []error{}
+	for _, sVersion := range sv.SkipVersions {
+		v, err := semver.Parse(sVersion)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid skip version %q: %v", sVersion, err))
+			continue
+		}
+		found := false
+		for _, bv := range bundleVersion {
+			found = found || bv.EQ(v)
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("skip version %q is not the version of any bundle", sVersion))
+			continue
+		}
+		skipped = append(skipped, v)
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid skip versions: %v", errors.NewAggregate(errs))
+	}
+
+	for _, ch := range channels {
+		for _, v := range skipped {
+			var skippedName string
+			successor := -1
+			for i, e := range ch.Entries {
+				ev, ok := bundleVersion[e.Name]
+				switch {
+				case !ok:
+				case ev.EQ(v):
+					skippedName = e.Name
+				case ev.GT(v) && (successor == -1 || ev.LT(bundleVersion[ch.Entries[successor].Name])):
+					successor = i
+				}
+			}
+			if skippedName == "" || successor == -1 {
+				continue
+			}
+			entry := &ch.Entries[successor]
+			if !sets.NewString(entry.Skips...).Has(skippedName) {
+				entry.Skips = append(entry.Skips, skippedName)
+				sort.Strings(entry.Skips)
+			}
+		}
+	}
+	return nil
+}
+
+// skipRange returns the skipRange of a Y-stream head covering the lower versions of its Y-stream, or nothing if it is
+// the only version of its Y-stream
+func skipRange(yMin semver.Version, head semver.Version) string {
+	if !yMin.LT(head) {
+		return ""
+	}
+	return fmt.Sprintf(">=%s <%s", yMin, head)
+}
+
+// resolveHeads records the names of the bundles flagged as channel heads.  It fails if two flagged bundles would head
+// the same generated channel, and returns a warning for each flagged bundle which is not the highest version of a
+// channel it heads.
+func (sv *semverTemplate) resolveHeads(cfg *declcfg.DeclarativeConfig, versions *bundleVersions) ([]string, error) {
+	imageNames := make(map[string]string, len(cfg.Bundles))
+	for _, b := range cfg.Bundles {
+		imageNames[b.Image] = b.Name
+	}
+
+	sv.heads = make(map[channelArchetype]sets.String)
+	warnings := []string{}
+	for _, arch := range sv.templateChannels() {
+		bundles := (*versions)[arch]
+		// channel name --> flagged head
+		channelHeads := make(map[string]string)
+		for _, entry := range sv.channelBundles(arch).Bundles {
+			name, ok := imageNames[entry.ref()]
+			if !entry.Head || !ok {
+				continue
+			}
+			v, ok := bundles[name]
+			if !ok {
+				// excluded from the output
+				continue
+			}
+			for _, chName := range sv.channelNamesFor(arch, v) {
+				if other, ok := channelHeads[chName]; ok && other != name {
+					return nil, fmt.Errorf("bundles %q and %q are both flagged as the head of channel %q", other, name, chName)
+				}
+				channelHeads[chName] = name
+			}
+			if _, ok := sv.heads[arch]; !ok {
+				sv.heads[arch] = sets.NewString()
+			}
+			sv.heads[arch].Insert(name)
+		}
+
+		chNames := make([]string, 0, len(channelHeads))
+		for chName := range channelHeads {
+			chNames = append(chNames, chName)
+		}
+		sort.Strings(chNames)
+		for _, chName := range chNames {
+			head := channelHeads[chName]
+			highest := bundles[head]
+			for _, v := range bundles {
+				if v.GT(highest) && sets.NewString(sv.channelNamesFor(arch, v)...).Has(chName) {
+					highest = v
+				}
+			}
+			if highest.GT(bundles[head]) {
+				warnings = append(warnings, fmt.Sprintf("bundle %q is flagged as the head of channel %q, which has a higher version %q", head, chName, highest))
+			}
+		}
+	}
+	return warnings, nil
+}
+
+// resolvePreviewHead removes the bundle marked as the preview head from its channel archetype, recording it along with
+// the head of the archetype's remaining bundles.  At most one bundle may be marked, and it must either be a prerelease
+// or have a higher version than the rest of its archetype.
+func (sv *semverTemplate) resolvePreviewHead(cfg *declcfg.DeclarativeConfig, versions *bundleVersions) error {
+	imageNames := make(map[string]string, len(cfg.Bundles))
+	for _, b := range cfg.Bundles {
+		imageNames[b.Image] = b.Name
+	}
+
+	for _, arch := range sv.templateChannels() {
+		for _, entry := range sv.channelBundles(arch).Bundles {
+			name, ok := imageNames[entry.ref()]
+			if !entry.PreviewHead || !ok {
+				continue
+			}
+			if len(sv.Channels) != 0 {
+				return fmt.Errorf("preview heads cannot be combined with declared channels")
+			}
+			v, ok := (*versions)[arch][name]
+			if !ok {
+				// excluded from the output
+				continue
+			}
+			if sv.preview != nil {
+				return fmt.Errorf("bundles %q and %q are both marked as the preview head", sv.preview.name, name)
+			}
+			delete((*versions)[arch], name)
+
+			p := &previewHead{name: name}
+			var baseVersion semver.Version
+			for _, other := range sortedBundleNames((*versions)[arch]) {
+				if ov := (*versions)[arch][other]; p.base == "" || ov.GT(baseVersion) {
+					p.base, baseVersion = other, ov
+				}
+			}
+			if p.base != "" && len(v.Pre) == 0 && !v.GT(baseVersion) {
+				return fmt.Errorf("%s bundle %q is marked as the preview head, but is neither a prerelease nor higher than %q", arch, name, p.base)
+			}
+			sv.preview = p
+		}
+	}
+	return nil
+}
+
+// previewChannel returns the preview channel, in which the preview head skips the head of its channel archetype
+func (sv *semverTemplate) previewChannel() *declcfg.Channel {
+	ch := newChannel(sv.pkg, previewChannelName)
+	head := declcfg.ChannelEntry{Name: sv.preview.name}
+	if sv.preview.base != "" {
+		ch.Entries = append(ch.Entries, declcfg.ChannelEntry{Name: sv.preview.base})
+		head.Skips = []string{sv.preview.base}
+	}
+	ch.Entries = append(ch.Entries, head)
+	return ch
+}
+
+// resolveOrdinals records the prerelease ordering overrides of each channel archetype by bundle name.  Ordinals must be
+// unique among the prereleases of a release version, and if any of those prereleases has one, all of them must.
+func (sv *semverTemplate) resolveOrdinals(cfg *declcfg.DeclarativeConfig, versions *bundleVersions) error {
+	imageNames := make(map[string]string, len(cfg.Bundles))
+	for _, b := range cfg.Bundles {
+		imageNames[b.Image] = b.Name
+	}
+
+	sv.ordinals = make(map[channelArchetype]map[string]int)
+	for _, arch := range sv.templateChannels() {
+		bundles := (*versions)[arch]
+		ordinals := make(map[string]int)
+		for _, entry := range sv.channelBundles(arch).Bundles {
+			name, ok := imageNames[entry.ref()]
+			if entry.Ordinal == nil || !ok {
+				continue
+			}
+			v, ok := bundles[name]
+			if !ok {
+				// excluded from the output
+				continue
+			}
+			if len(v.Pre) == 0 {
+				return fmt.Errorf("%s bundle %q has an ordinal, but version %q is not a prerelease", arch, name, v)
+			}
+			ordinals[name] = *entry.Ordinal
+		}
+		if len(ordinals) == 0 {
+			continue
+		}
+
+		// release version --> ordinal --> bundle name
+		byRelease := make(map[string]map[int]string)
+		for name, ordinal := range ordinals {
+			release := releaseVersion(bundles[name])
+			if _, ok := byRelease[release]; !ok {
+				byRelease[release] = make(map[int]string)
+			}
+			if other, ok := byRelease[release][ordinal]; ok {
+				return fmt.Errorf("%s bundles %q have the same ordinal %d", arch, sets.NewString(other, name).List(), ordinal)
+			}
+			byRelease[release][ordinal] = name
+		}
+		for _, name := range sortedBundleNames(bundles) {
+			v := bundles[name]
+			if _, ok := byRelease[releaseVersion(v)]; ok && len(v.Pre) != 0 {
+				if _, ok := ordinals[name]; !ok {
+					return fmt.Errorf("%s bundle %q has no ordinal, but other prereleases of %q do", arch, name, releaseVersion(v))
+				}
+			}
+		}
+		sv.ordinals[arch] = ordinals
+	}
+	return nil
+}
+
+// versionLess orders bundles of a channel archetype by version, except that prereleases of the same release version
+// with ordinals are ordered by ordinal.  Versions which differ only by build metadata are ordered by their build
+// metadata, compared lexically, under buildMetadataPolicyOrder.
+func (sv *semverTemplate) versionLess(arch channelArchetype, a string, av semver.Version, b string, bv semver.Version) bool {
+	aOrdinal, aOK := sv.ordinals[arch][a]
+	bOrdinal, bOK := sv.ordinals[arch][b]
+	if aOK && bOK && releaseVersion(av) == releaseVersion(bv) {
+		return aOrdinal < bOrdinal
+	}
+	if sv.BuildMetadataPolicy == buildMetadataPolicyOrder && av.EQ(bv) {
+		return strings.Join(av.Build, ".") < strings.Join(bv.Build, ".")
+	}
+	return av.LT(bv)
+}
+
+// releaseVersion returns the release (major.minor.patch) of a version, without prerelease or build metadata
+func releaseVersion(v semver.Version) string {
+	return semver.Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch}.String()
+}
+
+// channelNamesFor returns the names of the channels generated for a bundle version of the given archetype, or the
+// declared channel itself
+func (sv *semverTemplate) channelNamesFor(arch channelArchetype, v semver.Version) []string {
+	if len(sv.Channels) != 0 {
+		return []string{string(arch)}
+	}
+	names := []string{}
+	if sv.GenerateMajorChannels {
+		names = append(names, sv.channelNameFromMajor(arch, v))
+	}
+	if sv.GenerateMinorChannels {
+		names = append(names, sv.channelNameFromMinor(arch, v))
+	}
+	return names
+}
+
+// annotateTestedFrom adds a testedFrom property to each channel whose head bundle declares the versions its upgrades were
+// validated from.  Every listed version must be one the channel can upgrade from: one of its entries, or a bundle its
+// entries replace or skip.  Only channels with an entry declaring testedFrom versions are considered, and those without
+// a single head are left unannotated.
+func (sv *semverTemplate) annotateTestedFrom(channels []declcfg.Channel, cfg *declcfg.DeclarativeConfig, versions *bundleVersions) error {
+	imageNames := make(map[string]string, len(cfg.Bundles))
+	for _, b := range cfg.Bundles {
+		imageNames[b.Image] = b.Name
+	}
+	sources := make(map[string][]semver.Version)
+	for _, arch := range sv.templateChannels() {
+		for _, entry := range sv.channelBundles(arch).Bundles {
+			for _, tf := range entry.TestedFrom {
+				v, err := semver.Parse(tf)
+				if err != nil {
+					return fmt.Errorf("bundle %q has invalid testedFrom version %q: %v", entry.ref(), tf, err)
+				}
+				sources[imageNames[entry.ref()]] = append(sources[imageNames[entry.ref()]], v)
+			}
+		}
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+
+	bundleVersion := make(map[string]semver.Version)
+	for _, bundles := range *versions {
+		for name, v := range bundles {
+			bundleVersion[name] = v
+		}
+	}
+
+	errs := []error{}
+	for i := range channels {
+		ch := &channels[i]
+		hasSource := false
+		for _, e := range ch.Entries {
+			if _, ok := sources[e.Name]; ok {
+				hasSource = true
+				break
+			}
+		}
+		if !hasSource {
+			continue
+		}
+		// channels whose head is ambiguous, as may be rendered under SkipChannelValidation, cannot be annotated
+		head, err := channelHead(ch)
+		if err != nil {
+			continue
+		}
+		tested, ok := sources[head]
+		if !ok {
+			continue
+		}
+
+		upgradeable := []semver.Version{}
+		for _, e := range ch.Entries {
+			for _, name := range append([]string{e.Name, e.Replaces}, e.Skips...) {
+				if v, ok := bundleVersion[name]; ok {
+					upgradeable = append(upgradeable, v)
+				}
+			}
+		}
+
+		tf := testedFrom{Bundle: head}
+		sort.Slice(tested, func(i, j int) bool { return tested[i].LT(tested[j]) })
+		for _, v := range tested {
+			found := false
+			for _, u := range upgradeable {
+				if v.EQ(u) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				errs = append(errs, fmt.Errorf("bundle %q is tested from version %q which is not in channel %q", head, v, ch.Name))
+				continue
+			}
+			tf.Versions = append(tf.Versions, v.String())
+		}
+		value, err := json.Marshal(tf)
+		if err != nil {
+			return err
+		}
+		ch.Properties = append(ch.Properties, property.Property{Type: testedFromPropertyType, Value: value})
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid testedFrom versions: %v", errors.NewAggregate(errs))
+	}
+	return nil
+}
+
+// channelHead returns the name of the single channel entry which is not replaced or skipped by any other entry
+func channelHead(ch *declcfg.Channel) (string, error) {
+	referenced := sets.NewString()
+	for _, e := range ch.Entries {
+		if e.Replaces != "" {
+			referenced.Insert(e.Replaces)
+		}
+		referenced.Insert(e.Skips...)
+	}
+	heads := []string{}
+	for _, e := range ch.Entries {
+		if !referenced.Has(e.Name) {
+			heads = append(heads, e.Name)
+		}
+	}
+	if len(heads) != 1 {
+		return "", fmt.Errorf("channel %q has %d heads %v, expected exactly 1", ch.Name, len(heads), heads)
+	}
+	return heads[0], nil
+}
+
+// channelBundles returns the template's bundle section for the given channel archetype
+func (sv *semverTemplate) channelBundles(arch channelArchetype) *semverTemplateChannelBundles {
+	switch arch {
+	case candidateChannelArchetype:
+		return &sv.Candidate
+	case fastChannelArchetype:
+		return &sv.Fast
+	case stableChannelArchetype:
+		return &sv.Stable
+	}
+	if ch, ok := sv.CustomChannels[string(arch)]; ok {
+		return &ch.semverTemplateChannelBundles
+	}
+	for i := range sv.Channels {
+		if sv.Channels[i].Name == string(arch) {
+			return &sv.Channels[i].semverTemplateChannelBundles
+		}
+	}
+	return &semverTemplateChannelBundles{}
+}
+
+// priority returns the stability of a channel archetype, where higher values indicate greater stability
+func (sv *semverTemplate) priority(arch channelArchetype) int {
+	if p, ok := channelPriorities[arch]; ok {
+		return p
+	}
+	if ch, ok := sv.CustomChannels[string(arch)]; ok {
+		return ch.Priority
+	}
+	return 0
+}
+
+// templateChannels returns the channel archetypes, or the declared channels, which the template's bundles are listed under
+func (sv *semverTemplate) templateChannels() []channelArchetype {
+	if len(sv.Channels) == 0 {
+		archs := []channelArchetype{candidateChannelArchetype, fastChannelArchetype, stableChannelArchetype}
+		for name := range sv.CustomChannels {
+			archs = append(archs, channelArchetype(name))
+		}
+		sort.Slice(archs, func(i, j int) bool {
+			return sv.priority(archs[i]) < sv.priority(archs[j])
+		})
+		return archs
+	}
+	archs := make([]channelArchetype, 0, len(sv.Channels))
+	for _, ch := range sv.Channels {
+		archs = append(archs, channelArchetype(ch.Name))
+	}
+	return archs
+}
+
+// channelNamer returns the strategy naming the generated major and minor channels
+func (sv *semverTemplate) channelNamer() ChannelNamer {
+	if sv.namer == nil {
+		return DefaultChannelNamer{}
 	}
+	return sv.namer
+}
 
-	return channels
+func (sv *semverTemplate) channelNameFromMinor(prefix channelArchetype, version semver.Version) string {
+	return sv.channelNamer().MinorChannelName(string(prefix), version)
 }
 
-func channelNameFromMinor(prefix channelArchetype, version semver.Version) string {
-	return fmt.Sprintf("%s-v%d.%d", prefix, version.Major, version.Minor)
+func (sv *semverTemplate) channelNameFromMajor(prefix channelArchetype, version semver.Version) string {
+	return sv.channelNamer().MajorChannelName(string(prefix), version)
 }
 
-func channelNameFromMajor(prefix channelArchetype, version semver.Version) string {
-	return fmt.Sprintf("%s-v%d", prefix, version.Major)
+// validateChannelNames ensures that the channel namer produces well-formed names for every bundle version
+func (sv *semverTemplate) validateChannelNames(versions *bundleVersions) error {
+	errs := []error{}
+	invalid := sets.NewString()
+	for _, arch := range sv.templateChannels() {
+		bundles := (*versions)[arch]
+		for _, name := range sortedBundleNames(bundles) {
+			for _, cName := range sv.channelNamesFor(arch, bundles[name]) {
+				if !channelNamePattern.MatchString(cName) && !invalid.Has(cName) {
+					errs = append(errs, fmt.Errorf("invalid channel name %q for %s bundle %q", cName, arch, name))
+					invalid.Insert(cName)
+				}
+			}
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid channel names: %v", errors.NewAggregate(errs))
+	}
+	return nil
 }
 
 func newPackage(name string) *declcfg.Package {
@@ -359,15 +2565,27 @@ func newChannel(pkgName string, chName string) *declcfg.Channel {
 	}
 }
 
-func combineConfigs(cfgs []declcfg.DeclarativeConfig) *declcfg.DeclarativeConfig {
+// combineConfigs concatenates the rendered configs, keeping a single package of each name.  Packages of the same name
+// must be identical.
+func combineConfigs(cfgs []declcfg.DeclarativeConfig) (*declcfg.DeclarativeConfig, error) {
 	out := &declcfg.DeclarativeConfig{}
+	packages := make(map[string]declcfg.Package)
 	for _, in := range cfgs {
-		out.Packages = append(out.Packages, in.Packages...)
+		for _, p := range in.Packages {
+			if existing, ok := packages[p.Name]; ok {
+				if !reflect.DeepEqual(existing, p) {
+					return nil, fmt.Errorf("rendered packages named %q differ", p.Name)
+				}
+				continue
+			}
+			packages[p.Name] = p
+			out.Packages = append(out.Packages, p)
+		}
 		out.Channels = append(out.Channels, in.Channels...)
 		out.Bundles = append(out.Bundles, in.Bundles...)
 		out.Others = append(out.Others, in.Others...)
 	}
-	return out
+	return out, nil
 }
 
 func getMinorVersion(v semver.Version) semver.Version {
@@ -385,6 +2603,7 @@ func getMajorVersion(v semver.Version) semver.Version {
 
 func withoutBuildMetadataConflict(versions *map[string]semver.Version) error {
 	errs := []error{}
+	conflicts := []string{}
 
 	// using the stringified semver because the semver package generates deterministic representations,
 	// and because the semver.Version contains slice fields which make it unsuitable as a map key
@@ -397,16 +2616,26 @@ func withoutBuildMetadataConflict(versions *map[string]semver.Version) error {
 		} else {
 			seen[stripped] = seen[stripped] + 1
 			errs = append(errs, fmt.Errorf("bundle version %q cannot be compared to %q", (*versions)[b].String(), stripped))
+			conflicts = append(conflicts, (*versions)[b].String())
 		}
 	}
 
 	if len(errs) != 0 {
-		return fmt.Errorf("encountered bundle versions which differ only by build metadata, which cannot be ordered: %v", errors.NewAggregate(errs))
+		return &ErrBuildMetadataConflict{Versions: conflicts, Err: errors.NewAggregate(errs)}
 	}
 
 	return nil
 }
 
+// validateBundleVersions ensures that the bundle versions of a channel archetype can be ordered, unless their build
+// metadata orders them
+func (sv *semverTemplate) validateBundleVersions(versions *map[string]semver.Version) error {
+	if sv.BuildMetadataPolicy == buildMetadataPolicyOrder {
+		return nil
+	}
+	return validateVersions(versions)
+}
+
 func validateVersions(versions *map[string]semver.Version) error {
 	// short-circuit if empty, since that is not an error
 	if len(*versions) == 0 {
@@ -1,14 +1,28 @@
 package semver
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/blang/semver/v4"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/sets"
 
+	"github.com/operator-framework/operator-registry/alpha/action"
 	"github.com/operator-framework/operator-registry/alpha/declcfg"
 	"github.com/operator-framework/operator-registry/alpha/property"
+	"github.com/operator-framework/operator-registry/pkg/image"
 )
 
 func TestLinkChannels(t *testing.T) {
@@ -116,7 +130,9 @@ func TestLinkChannels(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			sv := &semverTemplate{pkg: "a", GenerateMajorChannels: tt.generateMajorChannels, GenerateMinorChannels: tt.generateMinorChannels}
-			require.ElementsMatch(t, tt.out, sv.linkChannels(tt.unlinkedChannels, majorChannelEntries))
+			channels, err := sv.linkChannels(context.Background(), tt.unlinkedChannels, majorChannelEntries)
+			require.NoError(t, err)
+			require.ElementsMatch(t, tt.out, channels)
 		})
 	}
 }
@@ -234,7 +250,9 @@ func TestGenerateChannels(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			sv := &semverTemplate{GenerateMajorChannels: tt.generateMajorChannels, GenerateMinorChannels: tt.generateMinorChannels, pkg: "a"}
-			require.ElementsMatch(t, tt.out, sv.generateChannels(&channelOperatorVersions))
+			channels, err := sv.generateChannels(context.Background(), &channelOperatorVersions)
+			require.NoError(t, err)
+			require.ElementsMatch(t, tt.out, channels)
 		})
 	}
 }
@@ -250,7 +268,7 @@ func TestGetVersionsFromStandardChannel(t *testing.T) {
 			name: "sunny day case",
 			sv: semverTemplate{
 				Stable: semverTemplateChannelBundles{
-					[]semverTemplateBundleEntry{
+					Bundles: []semverTemplateBundleEntry{
 						{Image: "repo/origin/a-v0.1.0"},
 						{Image: "repo/origin/a-v0.1.1"},
 						{Image: "repo/origin/a-v1.1.0"},
@@ -317,7 +335,7 @@ func TestGetVersionsFromStandardChannel(t *testing.T) {
 func TestBailOnVersionBuildMetadata(t *testing.T) {
 	sv := semverTemplate{
 		Stable: semverTemplateChannelBundles{
-			[]semverTemplateBundleEntry{
+			Bundles: []semverTemplateBundleEntry{
 				{Image: "repo/origin/a-v0.1.0"},
 				{Image: "repo/origin/a-v0.1.1"},
 				{Image: "repo/origin/a-v1.1.0"},
@@ -447,8 +465,1897 @@ invalid:
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			sv, err := readFile(strings.NewReader(tc.input))
+			sv, err := Template{}.readFile(strings.NewReader(tc.input))
 			tc.assertions(t, sv, err)
 		})
 	}
 }
+
+func TestRenderVersionFilter(t *testing.T) {
+	bundles := testBundles("a", "0.1.0", "0.1.1", "1.0.0", "1.1.0", "1.2.0", "2.0.0")
+	input := `---
+schema: olm.semver
+stable:
+  bundles:
+`
+	for _, b := range bundles {
+		input += fmt.Sprintf("  - image: %s\n", b.image)
+	}
+
+	tmpl := Template{
+		Data:          strings.NewReader(input),
+		Registry:      newTestRegistry(bundles...),
+		VersionFilter: semver.MustParseRange(">=1.0.0 <2.0.0"),
+	}
+	out, err := tmpl.Render(context.Background())
+	require.NoError(t, err)
+
+	require.ElementsMatch(t, []declcfg.Channel{
+		{
+			Schema:  "olm.channel",
+			Name:    "stable-v1.0",
+			Package: "a",
+			Entries: []declcfg.ChannelEntry{
+				{Name: "a.v1.0.0", Replaces: "", Skips: []string{}},
+			},
+		},
+		{
+			Schema:  "olm.channel",
+			Name:    "stable-v1.1",
+			Package: "a",
+			Entries: []declcfg.ChannelEntry{
+				{Name: "a.v1.1.0", Replaces: "a.v1.0.0", Skips: []string{}},
+			},
+		},
+		{
+			Schema:  "olm.channel",
+			Name:    "stable-v1.2",
+			Package: "a",
+			Entries: []declcfg.ChannelEntry{
+				{Name: "a.v1.2.0", Replaces: "a.v1.1.0", Skips: []string{"a.v1.0.0"}},
+			},
+		},
+	}, out.Channels)
+
+	var names []string
+	for _, b := range out.Bundles {
+		names = append(names, b.Name)
+	}
+	require.ElementsMatch(t, []string{"a.v1.0.0", "a.v1.1.0", "a.v1.2.0"}, names)
+	require.Equal(t, "stable-v1.2", out.Packages[0].DefaultChannel)
+}
+
+func TestOnDefaultChannelSelected(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.0.1", "1.0.2", "2.0.0")
+	data := fmt.Sprintf("schema: olm.semver\ncandidate:\n  bundles:\n  - image: %s\n  - image: %s\n  - image: %s\n  - image: %s\nstable:\n  bundles:\n  - image: %s\n  - image: %s\n  - image: %s\n",
+		bundles[0].image, bundles[1].image, bundles[2].image, bundles[3].image, bundles[0].image, bundles[1].image, bundles[2].image)
+
+	type call struct {
+		name      string
+		archetype string
+		version   semver.Version
+	}
+	var calls []call
+	newTemplate := func() Template {
+		return Template{
+			Data:     strings.NewReader(data),
+			Registry: newTestRegistry(bundles...),
+			OnDefaultChannelSelected: func(name string, archetype string, version semver.Version) {
+				calls = append(calls, call{name: name, archetype: archetype, version: version})
+			},
+		}
+	}
+	_, err := newTemplate().Render(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []call{{name: "stable-v1.0", archetype: "stable", version: semver.MustParse("1.0.2")}}, calls)
+
+	t.Run("not invoked when validation fails", func(t *testing.T) {
+		calls = nil
+		tmpl := newTemplate()
+		tmpl.MaxSkipsPerEntry = 1
+		_, err := tmpl.Render(context.Background())
+		require.ErrorContains(t, err, "skips lists too long")
+		require.Empty(t, calls)
+	})
+
+	t.Run("declared channels", func(t *testing.T) {
+		calls = nil
+		tmpl := newTemplate()
+		tmpl.Data = strings.NewReader(fmt.Sprintf("schema: olm.semver\nchannels:\n- name: preview\n  bundles:\n  - image: %s\n  - image: %s\n- name: ga\n  bundles:\n  - image: %s\n  - image: %s\n",
+			bundles[1].image, bundles[2].image, bundles[0].image, bundles[1].image))
+		out, err := tmpl.Render(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "ga", out.Packages[0].DefaultChannel)
+		require.Equal(t, []call{{name: "ga", archetype: "ga", version: semver.MustParse("1.0.1")}}, calls)
+	})
+}
+
+func TestSeedFromPrevious(t *testing.T) {
+	channelOperatorVersions := bundleVersions{
+		stableChannelArchetype: {
+			"a-v1.0.0": semver.MustParse("1.0.0"),
+			"a-v1.1.0": semver.MustParse("1.1.0"),
+			"a-v2.0.0": semver.MustParse("2.0.0"),
+		},
+	}
+
+	tests := []struct {
+		name string
+		seed bool
+		out  []declcfg.Channel
+	}{
+		{
+			name: "v2 tail replaces v1 head when seeded",
+			seed: true,
+			out: []declcfg.Channel{
+				{Schema: "olm.channel", Name: "stable-v1", Package: "a", Entries: []declcfg.ChannelEntry{
+					{Name: "a-v1.0.0", Replaces: "", Skips: []string{}},
+					{Name: "a-v1.1.0", Replaces: "a-v1.0.0", Skips: []string{}},
+				}},
+				{Schema: "olm.channel", Name: "stable-v2", Package: "a", Entries: []declcfg.ChannelEntry{
+					{Name: "a-v2.0.0", Replaces: "a-v1.1.0", Skips: []string{}},
+				}},
+				{Schema: "olm.channel", Name: "stable-v1.0", Package: "a", Entries: []declcfg.ChannelEntry{
+					{Name: "a-v1.0.0", Replaces: "", Skips: []string{}},
+				}},
+				{Schema: "olm.channel", Name: "stable-v1.1", Package: "a", Entries: []declcfg.ChannelEntry{
+					{Name: "a-v1.1.0", Replaces: "a-v1.0.0", Skips: []string{}},
+				}},
+				{Schema: "olm.channel", Name: "stable-v2.0", Package: "a", Entries: []declcfg.ChannelEntry{
+					{Name: "a-v2.0.0", Replaces: "a-v1.1.0", Skips: []string{}},
+				}},
+			},
+		},
+		{
+			name: "no edge across majors by default",
+			seed: false,
+			out: []declcfg.Channel{
+				{Schema: "olm.channel", Name: "stable-v1", Package: "a", Entries: []declcfg.ChannelEntry{
+					{Name: "a-v1.0.0", Replaces: "", Skips: []string{}},
+					{Name: "a-v1.1.0", Replaces: "a-v1.0.0", Skips: []string{}},
+				}},
+				{Schema: "olm.channel", Name: "stable-v2", Package: "a", Entries: []declcfg.ChannelEntry{
+					{Name: "a-v2.0.0", Replaces: "", Skips: []string{}},
+				}},
+				{Schema: "olm.channel", Name: "stable-v1.0", Package: "a", Entries: []declcfg.ChannelEntry{
+					{Name: "a-v1.0.0", Replaces: "", Skips: []string{}},
+				}},
+				{Schema: "olm.channel", Name: "stable-v1.1", Package: "a", Entries: []declcfg.ChannelEntry{
+					{Name: "a-v1.1.0", Replaces: "a-v1.0.0", Skips: []string{}},
+				}},
+				{Schema: "olm.channel", Name: "stable-v2.0", Package: "a", Entries: []declcfg.ChannelEntry{
+					{Name: "a-v2.0.0", Replaces: "", Skips: []string{}},
+				}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sv := &semverTemplate{
+				GenerateMajorChannels: true,
+				GenerateMinorChannels: true,
+				Stable:                semverTemplateChannelBundles{SeedFromPrevious: tt.seed},
+				pkg:                   "a",
+			}
+			channels, err := sv.generateChannels(context.Background(), &channelOperatorVersions)
+			require.NoError(t, err)
+			require.ElementsMatch(t, tt.out, channels)
+		})
+	}
+}
+
+type blockingReader struct{}
+
+func (blockingReader) Read([]byte) (int, error) {
+	select {}
+}
+
+func TestReadFileLimits(t *testing.T) {
+	input := "schema: olm.semver\nstable:\n  bundles:\n  - image: repo/origin/a-v0.1.0\n"
+
+	t.Run("within default limit", func(t *testing.T) {
+		sv, err := Template{}.readFile(strings.NewReader(input))
+		require.NoError(t, err)
+		require.NotNil(t, sv)
+	})
+
+	t.Run("exactly at limit", func(t *testing.T) {
+		_, err := Template{MaxTemplateSize: int64(len(input))}.readFile(strings.NewReader(input))
+		require.NoError(t, err)
+	})
+
+	t.Run("oversized input is rejected", func(t *testing.T) {
+		_, err := Template{MaxTemplateSize: 16}.readFile(strings.NewReader(input))
+		var target *ErrTemplateTooLarge
+		require.ErrorAs(t, err, &target)
+		require.EqualValues(t, 16, target.Limit)
+		require.EqualError(t, err, "readFile: input file exceeds the maximum template size of 16 bytes")
+	})
+
+	t.Run("slow reader times out", func(t *testing.T) {
+		_, err := Template{ReadTimeout: 10 * time.Millisecond}.readFile(blockingReader{})
+		var target *ErrTemplateReadTimeout
+		require.ErrorAs(t, err, &target)
+	})
+}
+
+func TestTestedFrom(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.0.1", "1.1.0")
+	newTemplate := func(testedFrom string) Template {
+		return Template{
+			Data: strings.NewReader(fmt.Sprintf(`---
+schema: olm.semver
+stable:
+  bundles:
+  - image: %s
+  - image: %s
+  - image: %s
+    testedFrom: [%s]
+`, bundles[0].image, bundles[1].image, bundles[2].image, testedFrom)),
+			Registry: newTestRegistry(bundles...),
+		}
+	}
+
+	t.Run("head lists its validated sources", func(t *testing.T) {
+		tmpl := newTemplate("1.0.1, 1.0.0")
+		out, err := tmpl.Render(context.Background())
+		require.NoError(t, err)
+		for _, ch := range out.Channels {
+			switch ch.Name {
+			case "stable-v1.1":
+				require.Len(t, ch.Properties, 1)
+				require.Equal(t, testedFromPropertyType, ch.Properties[0].Type)
+				require.JSONEq(t, `{"bundle":"a.v1.1.0","versions":["1.0.0","1.0.1"]}`, string(ch.Properties[0].Value))
+			default:
+				require.Empty(t, ch.Properties)
+			}
+		}
+	})
+
+	t.Run("unknown source version", func(t *testing.T) {
+		tmpl := newTemplate("0.9.0")
+		_, err := tmpl.Render(context.Background())
+		require.ErrorContains(t, err, `bundle "a.v1.1.0" is tested from version "0.9.0" which is not in channel "stable-v1.1"`)
+	})
+
+	t.Run("channels without a single head", func(t *testing.T) {
+		sv := &semverTemplate{Stable: semverTemplateChannelBundles{Bundles: []semverTemplateBundleEntry{
+			{Image: "a-v1.0.0"}, {Image: "a-v1.0.1"}, {Image: "a-v1.1.0", TestedFrom: []string{"1.0.1"}},
+		}}}
+		cfg := &declcfg.DeclarativeConfig{Bundles: []declcfg.Bundle{
+			{Name: "a.v1.0.0", Image: "a-v1.0.0"}, {Name: "a.v1.0.1", Image: "a-v1.0.1"}, {Name: "a.v1.1.0", Image: "a-v1.1.0"},
+		}}
+		versions := bundleVersions{stableChannelArchetype: {
+			"a.v1.0.0": semver.MustParse("1.0.0"),
+			"a.v1.0.1": semver.MustParse("1.0.1"),
+			"a.v1.1.0": semver.MustParse("1.1.0"),
+		}}
+		channels := []declcfg.Channel{
+			// unrelated to the testedFrom bundle, and with two heads
+			{Name: "stable-v1.0", Entries: []declcfg.ChannelEntry{{Name: "a.v1.0.0"}, {Name: "a.v1.0.1"}}},
+			// with the testedFrom bundle, but with two heads
+			{Name: "stable-v1", Entries: []declcfg.ChannelEntry{{Name: "a.v1.0.0"}, {Name: "a.v1.1.0", Replaces: "a.v1.0.1"}}},
+			{Name: "stable-v1.1", Entries: []declcfg.ChannelEntry{{Name: "a.v1.1.0", Replaces: "a.v1.0.1"}}},
+		}
+		require.NoError(t, sv.annotateTestedFrom(channels, cfg, &versions))
+		require.Empty(t, channels[0].Properties)
+		require.Empty(t, channels[1].Properties)
+		require.Len(t, channels[2].Properties, 1)
+	})
+}
+
+func TestEntryNameTemplate(t *testing.T) {
+	bundles := []testBundle{
+		{image: testImage("a", "1.2.0"), pkg: "a", version: "1.2.0", csvName: "a-operator-1.2.0"},
+		{image: testImage("a", "1.2.1"), pkg: "a", version: "1.2.1", csvName: "a-operator-1.2.1"},
+		{image: testImage("a", "1.3.0"), pkg: "a", version: "1.3.0", csvName: "a-operator-1.3.0"},
+	}
+	newTemplate := func(nameTemplate string) Template {
+		input := fmt.Sprintf("schema: olm.semver\nentryNameTemplate: %q\nstable:\n  bundles:\n", nameTemplate)
+		for _, b := range bundles {
+			input += fmt.Sprintf("  - image: %s\n", b.image)
+		}
+		return Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...)}
+	}
+
+	t.Run("names and edges use the template", func(t *testing.T) {
+		out, err := newTemplate("{{.Package}}.v{{.Version}}").Render(context.Background())
+		require.NoError(t, err)
+
+		var names []string
+		for _, b := range out.Bundles {
+			names = append(names, b.Name)
+		}
+		require.ElementsMatch(t, []string{"a.v1.2.0", "a.v1.2.1", "a.v1.3.0"}, names)
+		require.ElementsMatch(t, []declcfg.Channel{
+			{Schema: "olm.channel", Name: "stable-v1.2", Package: "a", Entries: []declcfg.ChannelEntry{
+				{Name: "a.v1.2.0", Replaces: "", Skips: nil},
+				{Name: "a.v1.2.1", Replaces: "", Skips: []string{"a.v1.2.0"}},
+			}},
+			{Schema: "olm.channel", Name: "stable-v1.3", Package: "a", Entries: []declcfg.ChannelEntry{
+				{Name: "a.v1.3.0", Replaces: "a.v1.2.1", Skips: []string{"a.v1.2.0"}},
+			}},
+		}, out.Channels)
+	})
+
+	t.Run("names must be unique", func(t *testing.T) {
+		_, err := newTemplate("{{.Package}}").Render(context.Background())
+		require.ErrorContains(t, err, `entry name template produced name "a" for both bundle`)
+	})
+
+	t.Run("unknown fields are rejected", func(t *testing.T) {
+		_, err := newTemplate("{{.Channel}}").Render(context.Background())
+		require.ErrorContains(t, err, "evaluate entry name template")
+	})
+}
+
+func TestPackageNameOverride(t *testing.T) {
+	bundles := testBundles("a", "0.1.0", "0.1.1")
+	tmpl := Template{
+		Data: strings.NewReader(fmt.Sprintf(`---
+schema: olm.semver
+packageNameOverride: b
+stable:
+  bundles:
+  - image: %s
+  - image: %s
+`, bundles[0].image, bundles[1].image)),
+		Registry: newTestRegistry(bundles...),
+	}
+	out, err := tmpl.Render(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, out.Packages, 1)
+	require.Equal(t, "b", out.Packages[0].Name)
+	require.Equal(t, "stable-v0.1", out.Packages[0].DefaultChannel)
+	require.NotEmpty(t, out.Channels)
+	for _, ch := range out.Channels {
+		require.Equal(t, "b", ch.Package)
+	}
+	require.Len(t, out.Bundles, 2)
+	for _, b := range out.Bundles {
+		require.Equal(t, "b", b.Package)
+		props, err := property.Parse(b.Properties)
+		require.NoError(t, err)
+		require.Len(t, props.Packages, 1)
+		require.Equal(t, "b", props.Packages[0].PackageName)
+	}
+}
+
+func TestPackageNameOverrideCollision(t *testing.T) {
+	sv := semverTemplate{pkg: "a"}
+	cfg := declcfg.DeclarativeConfig{Packages: []declcfg.Package{*newPackage("a"), *newPackage("b")}}
+	require.EqualError(t, sv.renamePackage(&cfg, "b"), `cannot rename package "a" to "b": package "b" already exists`)
+}
+
+func TestChannelPlan(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.0.1", "1.1.0", "1.2.0")
+	tmpl := Template{
+		Data: strings.NewReader(fmt.Sprintf(`---
+schema: olm.semver
+channels:
+- name: preview
+  bundles:
+  - image: %s
+  - image: %s
+  - image: %s
+  - image: %s
+- name: ga
+  bundles:
+  - image: %s
+  - image: %s
+`, bundles[3].image, bundles[0].image, bundles[2].image, bundles[1].image, bundles[1].image, bundles[2].image)),
+		Registry: newTestRegistry(bundles...),
+	}
+	out, err := tmpl.Render(context.Background())
+	require.NoError(t, err)
+
+	require.Equal(t, "ga", out.Packages[0].DefaultChannel)
+	channels := make(map[string]declcfg.Channel)
+	for _, ch := range out.Channels {
+		channels[ch.Name] = ch
+	}
+	require.Len(t, channels, 2)
+	require.Equal(t, []declcfg.ChannelEntry{
+		{Name: "a.v1.0.0"},
+		{Name: "a.v1.0.1", Skips: []string{"a.v1.0.0"}},
+		{Name: "a.v1.1.0", Replaces: "a.v1.0.1", Skips: []string{"a.v1.0.0"}},
+		{Name: "a.v1.2.0", Replaces: "a.v1.1.0", Skips: []string{"a.v1.0.0", "a.v1.0.1"}},
+	}, channels["preview"].Entries)
+	require.Equal(t, []declcfg.ChannelEntry{
+		{Name: "a.v1.0.1", Skips: []string{}},
+		{Name: "a.v1.1.0", Replaces: "a.v1.0.1", Skips: []string{}},
+	}, channels["ga"].Entries)
+}
+
+func TestChannelPlanValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{
+			name: "mixed with archetypes",
+			data: "schema: olm.semver\nstable:\n  bundles:\n  - image: foo\nchannels:\n- name: ga\n",
+			err:  "readFile: template may declare either channel archetypes or channels, not both",
+		},
+		{
+			name: "unnamed",
+			data: "schema: olm.semver\nchannels:\n- bundles:\n  - image: foo\n",
+			err:  "readFile: declared channels must be named",
+		},
+		{
+			name: "duplicate",
+			data: "schema: olm.semver\nchannels:\n- name: ga\n- name: ga\n",
+			err:  `readFile: channel "ga" is declared more than once`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Template{}.readFile(strings.NewReader(tt.data))
+			require.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestDefaultChannelVersionRange(t *testing.T) {
+	channelOperatorVersions := bundleVersions{
+		stableChannelArchetype: {
+			"a-v1.0.0": semver.MustParse("1.0.0"),
+			"a-v1.1.0": semver.MustParse("1.1.0"),
+			"a-v2.0.0": semver.MustParse("2.0.0"),
+		},
+	}
+
+	sv := &semverTemplate{GenerateMinorChannels: true, pkg: "a"}
+	_, err := sv.generateChannels(context.Background(), &channelOperatorVersions)
+	require.NoError(t, err)
+	require.Equal(t, "stable-v2.0", sv.defaultChannel)
+
+	sv = &semverTemplate{GenerateMinorChannels: true, pkg: "a", defaultChannelRange: semver.MustParseRange(">=1.0.0 <2.0.0")}
+	_, err = sv.generateChannels(context.Background(), &channelOperatorVersions)
+	require.NoError(t, err)
+	require.Equal(t, "stable-v1.1", sv.defaultChannel)
+
+	t.Run("no channel qualifies", func(t *testing.T) {
+		bundles := testBundles("a", "2.0.0")
+		tmpl := Template{
+			Data: strings.NewReader(fmt.Sprintf(`---
+schema: olm.semver
+stable:
+  bundles:
+  - image: %s
+`, bundles[0].image)),
+			Registry:                   newTestRegistry(bundles...),
+			DefaultChannelVersionRange: semver.MustParseRange("<2.0.0"),
+		}
+		_, err := tmpl.Render(context.Background())
+		require.EqualError(t, err, "render: no channel head satisfies the default channel version range")
+	})
+}
+
+func TestHeadFlag(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.0.1", "1.0.2")
+	newTemplate := func(heads ...bool) Template {
+		data := "---\nschema: olm.semver\nstable:\n  bundles:\n"
+		for i, b := range bundles {
+			data += fmt.Sprintf("  - image: %s\n    head: %t\n", b.image, heads[i])
+		}
+		return Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}
+	}
+
+	out, report, err := newTemplate(false, true, false).RenderWithReport(context.Background())
+	require.NoError(t, err)
+	require.Len(t, out.Channels, 1)
+	require.ElementsMatch(t, []declcfg.ChannelEntry{
+		{Name: "a.v1.0.0"},
+		{Name: "a.v1.0.1", Skips: []string{"a.v1.0.0", "a.v1.0.2"}},
+		{Name: "a.v1.0.2"},
+	}, out.Channels[0].Entries)
+	require.Equal(t, map[string][]string{"a.v1.0.1": {"stable-v1.0"}}, report.Heads)
+	require.Equal(t, []string{`bundle "a.v1.0.1" is flagged as the head of channel "stable-v1.0", which has a higher version "1.0.2"`}, report.Warnings)
+
+	t.Run("multiple heads", func(t *testing.T) {
+		_, err := newTemplate(true, true, false).Render(context.Background())
+		require.ErrorContains(t, err, `are both flagged as the head of channel "stable-v1.0"`)
+	})
+}
+
+func TestSingleBundle(t *testing.T) {
+	bundles := testBundles("a", "0.1.0")
+	tmpl := Template{
+		Data: strings.NewReader(fmt.Sprintf(`---
+schema: olm.semver
+generateMajorChannels: false
+generateMinorChannels: true
+stable:
+  bundles:
+  - image: %s
+`, bundles[0].image)),
+		Registry:           newTestRegistry(bundles...),
+		WarnOnSingleBundle: true,
+	}
+	out, report, err := tmpl.RenderWithReport(context.Background())
+	require.NoError(t, err)
+
+	require.Equal(t, []declcfg.Channel{{
+		Schema:  "olm.channel",
+		Name:    "stable-v0.1",
+		Package: "a",
+		Entries: []declcfg.ChannelEntry{{Name: "a.v0.1.0", Skips: []string{}}},
+	}}, out.Channels)
+	require.Equal(t, "stable-v0.1", out.Packages[0].DefaultChannel)
+	require.Equal(t, map[string][]string{"a.v0.1.0": {"stable-v0.1"}}, report.Heads)
+	require.Equal(t, []string{`package "a" has a single bundle "a.v0.1.0", so no upgrade graph exists yet`}, report.Warnings)
+}
+
+func TestCascadingArchetypeDefault(t *testing.T) {
+	channelOperatorVersions := bundleVersions{
+		candidateChannelArchetype: {
+			"a-v3.0.0": semver.MustParse("3.0.0"),
+		},
+		fastChannelArchetype: {
+			"a-v1.0.0": semver.MustParse("1.0.0"),
+			"a-v2.0.0": semver.MustParse("2.0.0"),
+		},
+		stableChannelArchetype: {
+			"a-v1.0.0": semver.MustParse("1.0.0"),
+			"a-v1.0.1": semver.MustParse("1.0.1"),
+		},
+	}
+
+	sv := &semverTemplate{GenerateMajorChannels: true, GenerateMinorChannels: true, pkg: "a", cascadingDefault: true}
+	_, err := sv.generateChannels(context.Background(), &channelOperatorVersions)
+	require.NoError(t, err)
+	require.Equal(t, "stable-v1.0", sv.defaultChannel)
+
+	delete(channelOperatorVersions, stableChannelArchetype)
+	sv = &semverTemplate{GenerateMajorChannels: true, GenerateMinorChannels: true, pkg: "a", cascadingDefault: true}
+	_, err = sv.generateChannels(context.Background(), &channelOperatorVersions)
+	require.NoError(t, err)
+	require.Equal(t, "fast-v2.0", sv.defaultChannel)
+}
+
+func TestSkipsIndependentAcrossStreamKinds(t *testing.T) {
+	channelOperatorVersions := bundleVersions{
+		stableChannelArchetype: {
+			"a-v1.0.0": semver.MustParse("1.0.0"),
+			"a-v1.0.1": semver.MustParse("1.0.1"),
+			"a-v1.1.0": semver.MustParse("1.1.0"),
+			"a-v1.1.1": semver.MustParse("1.1.1"),
+			"a-v2.0.0": semver.MustParse("2.0.0"),
+			"a-v2.0.1": semver.MustParse("2.0.1"),
+		},
+	}
+	sv := &semverTemplate{GenerateMajorChannels: true, GenerateMinorChannels: true, pkg: "a"}
+	channels := map[string]declcfg.Channel{}
+	generated, err := sv.generateChannels(context.Background(), &channelOperatorVersions)
+	require.NoError(t, err)
+	for _, ch := range generated {
+		channels[ch.Name] = ch
+	}
+	headEntry := func(channel string, name string) declcfg.ChannelEntry {
+		for _, e := range channels[channel].Entries {
+			if e.Name == name {
+				return e
+			}
+		}
+		t.Fatalf("channel %q has no entry %q", channel, name)
+		return declcfg.ChannelEntry{}
+	}
+
+	// a-v1.1.1 heads both stable-v1.1 and stable-v1; the major head skips all lesser versions in the major, and
+	// the minor head sees the same history, since the minor channels of a major are linked the same way
+	minor := headEntry("stable-v1.1", "a-v1.1.1")
+	major := headEntry("stable-v1", "a-v1.1.1")
+	require.Equal(t, declcfg.ChannelEntry{Name: "a-v1.1.1", Replaces: "a-v1.0.1", Skips: []string{"a-v1.0.0", "a-v1.1.0"}}, minor)
+	require.Equal(t, minor, major)
+
+	// the first major's history does not leak into the next major, in either stream kind
+	require.Equal(t, declcfg.ChannelEntry{Name: "a-v2.0.1", Skips: []string{"a-v2.0.0"}}, headEntry("stable-v2.0", "a-v2.0.1"))
+	require.Equal(t, declcfg.ChannelEntry{Name: "a-v2.0.1", Skips: []string{"a-v2.0.0"}}, headEntry("stable-v2", "a-v2.0.1"))
+
+	// each context owns its skips
+	minor.Skips[0] = "mutated"
+	require.Equal(t, "a-v1.0.0", headEntry("stable-v1", "a-v1.1.1").Skips[0])
+}
+
+func TestPrereleaseOrdinals(t *testing.T) {
+	bundles := testBundles("a", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.1.0")
+	newTemplate := func(ordinals string) Template {
+		data := "---\nschema: olm.semver\ngenerateMajorChannels: true\ngenerateMinorChannels: false\nstable:\n  bundles:\n"
+		for i, b := range bundles {
+			data += fmt.Sprintf("  - image: %s\n", b.image)
+			if i < len(ordinals) && ordinals[i] != '-' {
+				data += fmt.Sprintf("    ordinal: %c\n", ordinals[i])
+			}
+		}
+		return Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}
+	}
+	entries := func(out *declcfg.DeclarativeConfig) map[string]declcfg.ChannelEntry {
+		require.Len(t, out.Channels, 1)
+		m := map[string]declcfg.ChannelEntry{}
+		for _, e := range out.Channels[0].Entries {
+			m[e.Name] = e
+		}
+		return m
+	}
+
+	// by semver precedence, numeric identifiers sort before alphanumeric ones
+	out, err := newTemplate("").Render(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, declcfg.ChannelEntry{Name: "a.v1.1.0", Replaces: "a.v1.0.0-alpha.beta", Skips: []string{"a.v1.0.0-alpha.1"}}, entries(out)["a.v1.1.0"])
+
+	// ordinals override that precedence, changing the replaces chain
+	out, err = newTemplate("21").Render(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, declcfg.ChannelEntry{Name: "a.v1.1.0", Replaces: "a.v1.0.0-alpha.1", Skips: []string{"a.v1.0.0-alpha.beta"}}, entries(out)["a.v1.1.0"])
+
+	t.Run("duplicate ordinals", func(t *testing.T) {
+		_, err := newTemplate("11").Render(context.Background())
+		require.EqualError(t, err, `render: stable bundles ["a.v1.0.0-alpha.1" "a.v1.0.0-alpha.beta"] have the same ordinal 1`)
+	})
+	t.Run("incomplete ordinals", func(t *testing.T) {
+		_, err := newTemplate("1").Render(context.Background())
+		require.EqualError(t, err, `render: stable bundle "a.v1.0.0-alpha.beta" has no ordinal, but other prereleases of "1.0.0" do`)
+	})
+	t.Run("ordinal on a release", func(t *testing.T) {
+		_, err := newTemplate("--1").Render(context.Background())
+		require.EqualError(t, err, `render: stable bundle "a.v1.1.0" has an ordinal, but version "1.1.0" is not a prerelease`)
+	})
+}
+
+func TestNormalizePackageName(t *testing.T) {
+	bundles := []testBundle{
+		{image: testImage("myoperator", "0.1.0"), pkg: "MyOperator", version: "0.1.0"},
+		{image: testImage("myoperator", "0.1.1"), pkg: "MyOperator", version: "0.1.1"},
+	}
+	newTemplate := func(normalize bool) Template {
+		return Template{
+			Data: strings.NewReader(fmt.Sprintf(`---
+schema: olm.semver
+stable:
+  bundles:
+  - image: %s
+  - image: %s
+`, bundles[0].image, bundles[1].image)),
+			Registry:             newTestRegistry(bundles...),
+			NormalizePackageName: normalize,
+		}
+	}
+	packageNames := func(out *declcfg.DeclarativeConfig) sets.String {
+		names := sets.NewString()
+		for _, p := range out.Packages {
+			names.Insert(p.Name)
+		}
+		for _, ch := range out.Channels {
+			names.Insert(ch.Package)
+		}
+		for _, b := range out.Bundles {
+			names.Insert(b.Package)
+			props, err := property.Parse(b.Properties)
+			require.NoError(t, err)
+			for _, p := range props.Packages {
+				names.Insert(p.PackageName)
+			}
+		}
+		return names
+	}
+
+	out, err := newTemplate(false).Render(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"MyOperator"}, packageNames(out).List())
+
+	out, err = newTemplate(true).Render(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"myoperator"}, packageNames(out).List())
+}
+
+func TestIncludeExcludeChannels(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.1.0", "2.0.0")
+	newTemplate := func(include []string, exclude []string) Template {
+		return Template{
+			Data: strings.NewReader(fmt.Sprintf(`---
+schema: olm.semver
+candidate:
+  bundles:
+  - image: %[1]s
+  - image: %[2]s
+  - image: %[3]s
+stable:
+  bundles:
+  - image: %[1]s
+  - image: %[2]s
+`, bundles[0].image, bundles[1].image, bundles[2].image)),
+			Registry:        newTestRegistry(bundles...),
+			IncludeChannels: include,
+			ExcludeChannels: exclude,
+		}
+	}
+	channelNames := func(out *declcfg.DeclarativeConfig) []string {
+		names := []string{}
+		for _, ch := range out.Channels {
+			names = append(names, ch.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	out, err := newTemplate(nil, []string{"candidate-*"}).Render(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"stable-v1.0", "stable-v1.1"}, channelNames(out))
+	require.Equal(t, "stable-v1.1", out.Packages[0].DefaultChannel)
+	require.Len(t, out.Bundles, 3)
+
+	// the default is re-selected when excluded
+	out, err = newTemplate(nil, []string{"stable-*"}).Render(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"candidate-v1.0", "candidate-v1.1", "candidate-v2.0"}, channelNames(out))
+	require.Equal(t, "candidate-v2.0", out.Packages[0].DefaultChannel)
+
+	out, err = newTemplate([]string{"*-v1.*"}, []string{"*-v1.0"}).Render(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"candidate-v1.1", "stable-v1.1"}, channelNames(out))
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		_, err := newTemplate([]string{"["}, nil).Render(context.Background())
+		require.EqualError(t, err, `render: invalid channel pattern "[": syntax error in pattern`)
+	})
+}
+
+func TestExcludeChannelsWithMajorChannels(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.1.0")
+	tmpl := Template{
+		Data: strings.NewReader(fmt.Sprintf(`---
+schema: olm.semver
+generateMajorChannels: true
+generateMinorChannels: true
+stable:
+  bundles:
+  - image: %s
+  - image: %s
+`, bundles[0].image, bundles[1].image)),
+		Registry:        newTestRegistry(bundles...),
+		ExcludeChannels: []string{"stable-v1.0"},
+	}
+	out, err := tmpl.Render(context.Background())
+	require.NoError(t, err)
+	require.Len(t, out.Channels, 2)
+}
+
+func TestAuthFiles(t *testing.T) {
+	data := "---\nschema: olm.semver\nstable:\n  bundles:\n  - image: test.registry/a-operator/a-bundle:v0.1.0\n"
+
+	t.Run("combined with a registry", func(t *testing.T) {
+		tmpl := Template{Data: strings.NewReader(data), Registry: newTestRegistry(), AuthFiles: []string{"auth.json"}}
+		_, err := tmpl.Render(context.Background())
+		require.EqualError(t, err, "render: auth files cannot be combined with a registry")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "auth.json")
+		tmpl := Template{Data: strings.NewReader(data), AuthFiles: []string{missing}}
+		_, err := tmpl.Render(context.Background())
+		require.ErrorContains(t, err, fmt.Sprintf("render: load auth file %q", missing))
+	})
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	tmpl := Template{Data: strings.NewReader("schema: olm.semver\nstable:\n  bundles:\n  - image: foo\n")}
+	data, err := tmpl.EffectiveConfig()
+	require.NoError(t, err)
+	require.Equal(t, `candidate: {}
+fast: {}
+generateMinorChannels: true
+schema: olm.semver
+schemaVersion: 1
+stable:
+  bundles:
+  - image: foo
+`, string(data))
+
+	t.Run("round trip", func(t *testing.T) {
+		bundles := testBundles("a", "0.1.0", "0.1.1", "0.2.0", "1.0.0")
+		input := fmt.Sprintf("schema: olm.semver\ngenerateMajorChannels: true\ncandidate:\n  bundles:\n  - image: %s\n  - image: %s\nstable:\n  bundles:\n  - image: %s\n  - image: %s\n",
+			bundles[0].image, bundles[1].image, bundles[2].image, bundles[3].image)
+		want, err := Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+		require.NoError(t, err)
+
+		data, err := Template{Data: strings.NewReader(input)}.EffectiveConfig()
+		require.NoError(t, err)
+		got, err := Template{Data: bytes.NewReader(data), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+
+		// the effective config of an effective config is itself
+		again, err := Template{Data: bytes.NewReader(data)}.EffectiveConfig()
+		require.NoError(t, err)
+		require.Equal(t, string(data), string(again))
+	})
+}
+
+func TestDisambiguateBundleNames(t *testing.T) {
+	bundles := []testBundle{
+		{image: testImage("a", "1.2.0"), pkg: "a", version: "1.2.0", csvName: "a-operator"},
+		{image: testImage("a", "1.2.1"), pkg: "a", version: "1.2.1", csvName: "a-operator"},
+		{image: testImage("a", "1.3.0"), pkg: "a", version: "1.3.0"},
+	}
+	newTemplate := func(disambiguate bool) Template {
+		input := "schema: olm.semver\nstable:\n  bundles:\n"
+		for _, b := range bundles {
+			input += fmt.Sprintf("  - image: %s\n", b.image)
+		}
+		return Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...), DisambiguateBundleNames: disambiguate}
+	}
+
+	_, err := newTemplate(false).Render(context.Background())
+	require.ErrorContains(t, err, `duplicate bundle name "a-operator"`)
+
+	out, err := newTemplate(true).Render(context.Background())
+	require.NoError(t, err)
+	var names []string
+	for _, b := range out.Bundles {
+		names = append(names, b.Name)
+	}
+	require.ElementsMatch(t, []string{"a-operator-v1.2.0", "a-operator-v1.2.1", "a.v1.3.0"}, names)
+	require.ElementsMatch(t, []declcfg.Channel{
+		{Schema: "olm.channel", Name: "stable-v1.2", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a-operator-v1.2.0", Replaces: "", Skips: nil},
+			{Name: "a-operator-v1.2.1", Replaces: "", Skips: []string{"a-operator-v1.2.0"}},
+		}},
+		{Schema: "olm.channel", Name: "stable-v1.3", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.3.0", Replaces: "a-operator-v1.2.1", Skips: []string{"a-operator-v1.2.0"}},
+		}},
+	}, out.Channels)
+}
+
+func TestMajorChannelsOnly(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.0.1", "1.1.0", "1.1.1", "1.2.0", "2.0.0", "2.1.0")
+	input := "schema: olm.semver\ngenerateMajorChannels: true\ngenerateMinorChannels: false\nstable:\n  bundles:\n"
+	for _, b := range bundles {
+		input += fmt.Sprintf("  - image: %s\n", b.image)
+	}
+	out, err := Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+	require.NoError(t, err)
+
+	// each major channel holds every minor and patch version of its major, with the Y-stream heads linked by a
+	// continuous replaces chain which skips everything else
+	require.ElementsMatch(t, []declcfg.Channel{
+		{Schema: "olm.channel", Name: "stable-v1", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.0.0", Replaces: "", Skips: nil},
+			{Name: "a.v1.0.1", Replaces: "", Skips: []string{"a.v1.0.0"}},
+			{Name: "a.v1.1.0", Replaces: "", Skips: nil},
+			{Name: "a.v1.1.1", Replaces: "a.v1.0.1", Skips: []string{"a.v1.0.0", "a.v1.1.0"}},
+			{Name: "a.v1.2.0", Replaces: "a.v1.1.1", Skips: []string{"a.v1.0.0", "a.v1.0.1", "a.v1.1.0"}},
+		}},
+		{Schema: "olm.channel", Name: "stable-v2", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v2.0.0", Replaces: "", Skips: []string{}},
+			{Name: "a.v2.1.0", Replaces: "a.v2.0.0", Skips: []string{}},
+		}},
+	}, out.Channels)
+	require.Equal(t, "stable-v2", out.Packages[0].DefaultChannel)
+}
+
+func TestDefaultChannelEntry(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.0.1", "1.1.0", "1.2.0")
+	newTemplate := func(entry string) Template {
+		input := "schema: olm.semver\nstable:\n  bundles:\n"
+		for _, b := range bundles {
+			input += fmt.Sprintf("  - image: %s\n", b.image)
+		}
+		return Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...), DefaultChannelEntry: entry}
+	}
+
+	head, err := newTemplate("").Render(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "stable-v1.2", head.Packages[0].DefaultChannel)
+	require.Empty(t, head.Packages[0].Properties)
+
+	tail, err := newTemplate(DefaultChannelEntryTail).Render(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "stable-v1.0", tail.Packages[0].DefaultChannel)
+	require.Equal(t, []property.Property{{Type: defaultEntryPropertyType, Value: json.RawMessage(`{"bundle":"a.v1.0.0","version":"1.0.0"}`)}}, tail.Packages[0].Properties)
+	// the channels remain terminated by their heads
+	require.ElementsMatch(t, head.Channels, tail.Channels)
+
+	_, err = newTemplate("middle").Render(context.Background())
+	require.EqualError(t, err, `render: invalid default channel entry "middle", expected "head" or "tail"`)
+}
+
+// slowRegistry blocks pulls of its slow images until the context is done
+type slowRegistry struct {
+	*image.MockRegistry
+	slow sets.String
+}
+
+func (r *slowRegistry) Pull(ctx context.Context, ref image.Reference) error {
+	if r.slow.Has(ref.String()) {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return r.MockRegistry.Pull(ctx, ref)
+}
+
+func TestRenderWithDeadline(t *testing.T) {
+	bundles := testBundles("a", "0.1.0", "0.1.1", "0.1.2")
+	input := "schema: olm.semver\nstable:\n  bundles:\n"
+	for _, b := range bundles {
+		input += fmt.Sprintf("  - image: %s\n", b.image)
+	}
+	reg := &slowRegistry{MockRegistry: newTestRegistry(bundles...), slow: sets.NewString(bundles[2].image)}
+
+	out, report, err := Template{Data: strings.NewReader(input), Registry: reg}.RenderWithDeadline(context.Background(), 100*time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, []string{bundles[2].image}, report.Unrendered)
+	require.Equal(t, []string{fmt.Sprintf("bundle image %q was skipped because it did not render before the deadline", bundles[2].image)}, report.Warnings)
+
+	var names []string
+	for _, b := range out.Bundles {
+		names = append(names, b.Name)
+	}
+	require.ElementsMatch(t, []string{"a.v0.1.0", "a.v0.1.1"}, names)
+	// the edges only refer to the bundles which rendered
+	require.Equal(t, []declcfg.Channel{
+		{Schema: "olm.channel", Name: "stable-v0.1", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v0.1.0", Replaces: "", Skips: nil},
+			{Name: "a.v0.1.1", Replaces: "", Skips: []string{"a.v0.1.0"}},
+		}},
+	}, out.Channels)
+	require.Equal(t, "stable-v0.1", out.Packages[0].DefaultChannel)
+
+	// outside of best-effort rendering, a bundle which does not render in time fails the render
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = Template{Data: strings.NewReader(input), Registry: reg}.Render(ctx)
+	require.Error(t, err)
+}
+
+func TestRecommendedChannel(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.0.1", "1.1.0", "1.1.1", "1.2.0")
+	newTemplate := func(candidate, stable string) Template {
+		input := "schema: olm.semver\ncandidate:\n  recommended: " + candidate + "\n  bundles:\n"
+		for _, b := range bundles {
+			input += fmt.Sprintf("  - image: %s\n", b.image)
+		}
+		input += "stable:\n  recommended: " + stable + "\n  bundles:\n"
+		for _, b := range bundles[:4] {
+			input += fmt.Sprintf("  - image: %s\n", b.image)
+		}
+		return Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...)}
+	}
+
+	out, err := newTemplate("[1.2.0]", "[1.0.1, 1.1.1]").Render(context.Background())
+	require.NoError(t, err)
+	var recommended *declcfg.Channel
+	for i := range out.Channels {
+		if out.Channels[i].Name == "recommended" {
+			recommended = &out.Channels[i]
+		}
+	}
+	require.NotNil(t, recommended)
+	require.Equal(t, []declcfg.ChannelEntry{
+		{Name: "a.v1.0.1"},
+		{Name: "a.v1.1.1", Replaces: "a.v1.0.1"},
+		{Name: "a.v1.2.0", Replaces: "a.v1.1.1"},
+	}, recommended.Entries)
+	// the generated channels are still present
+	require.Len(t, out.Channels, 6)
+	require.Equal(t, "stable-v1.1", out.Packages[0].DefaultChannel)
+
+	_, err = newTemplate("[]", "[1.2.0]").Render(context.Background())
+	require.EqualError(t, err, `render: invalid recommended versions: stable recommended version "1.2.0" is not one of its bundles`)
+}
+
+// inFlightRegistry records the most pulls it has served at once
+type inFlightRegistry struct {
+	*image.MockRegistry
+	mu             sync.Mutex
+	inFlight, most int
+}
+
+func (r *inFlightRegistry) Pull(ctx context.Context, ref image.Reference) error {
+	r.mu.Lock()
+	r.inFlight++
+	if r.inFlight > r.most {
+		r.most = r.inFlight
+	}
+	r.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	r.mu.Lock()
+	r.inFlight--
+	r.mu.Unlock()
+	return r.MockRegistry.Pull(ctx, ref)
+}
+
+func TestRenderConcurrency(t *testing.T) {
+	bundles := testBundles("a", "0.1.0", "0.1.1", "0.1.2", "0.2.0", "0.2.1", "1.0.0")
+	input := "schema: olm.semver\nstable:\n  bundles:\n"
+	for _, b := range bundles {
+		input += fmt.Sprintf("  - image: %s\n", b.image)
+	}
+	render := func(concurrency int) (*declcfg.DeclarativeConfig, int) {
+		reg := &inFlightRegistry{MockRegistry: newTestRegistry(bundles...)}
+		out, err := Template{Data: strings.NewReader(input), Registry: reg, MaxConcurrency: concurrency}.Render(context.Background())
+		require.NoError(t, err)
+		return out, reg.most
+	}
+
+	serial, most := render(1)
+	require.Equal(t, 1, most)
+	concurrent, most := render(3)
+	require.LessOrEqual(t, most, 3)
+	// the output does not depend on the order in which renders complete
+	require.Equal(t, serial.Bundles, concurrent.Bundles)
+	require.ElementsMatch(t, serial.Channels, concurrent.Channels)
+	require.Equal(t, serial.Packages, concurrent.Packages)
+
+	t.Run("failure", func(t *testing.T) {
+		_, err := Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles[1:]...), MaxConcurrency: 2}.Render(context.Background())
+		require.ErrorContains(t, err, bundles[0].image)
+	})
+}
+
+func TestMaxSkipsPerEntry(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.0.1", "1.0.2", "1.0.3", "1.0.4")
+	newTemplate := func(max int) Template {
+		input := "schema: olm.semver\nstable:\n  bundles:\n"
+		for _, b := range bundles {
+			input += fmt.Sprintf("  - image: %s\n", b.image)
+		}
+		return Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...), MaxSkipsPerEntry: max}
+	}
+
+	_, err := newTemplate(4).Render(context.Background())
+	require.NoError(t, err)
+
+	_, err = newTemplate(3).Render(context.Background())
+	require.EqualError(t, err, `render: skips lists too long, consider generating skipRanges instead: channel "stable-v1.0" entry "a.v1.0.4" skips 4 bundles, more than the limit of 3`)
+
+	// a skipRange replaces the skips altogether
+	tmpl := newTemplate(3)
+	tmpl.Data = io.MultiReader(strings.NewReader("generateSkipRange: true\n"), tmpl.Data)
+	_, err = tmpl.Render(context.Background())
+	require.NoError(t, err)
+}
+
+func TestGenerateSkipRange(t *testing.T) {
+	bundles := testBundles("a", "1.2.0", "1.2.1", "1.2.2", "1.3.0")
+	input := "schema: olm.semver\ngenerateSkipRange: true\ngenerateMajorChannels: true\nstable:\n  bundles:\n"
+	for _, b := range bundles {
+		input += fmt.Sprintf("  - image: %s\n", b.image)
+	}
+	out, err := Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+	require.NoError(t, err)
+	require.ElementsMatch(t, []declcfg.Channel{
+		{Schema: "olm.channel", Name: "stable-v1.2", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.2.0"},
+			{Name: "a.v1.2.1", Replaces: "a.v1.2.0"},
+			{Name: "a.v1.2.2", Replaces: "a.v1.2.1", SkipRange: ">=1.2.0 <1.2.2"},
+		}},
+		{Schema: "olm.channel", Name: "stable-v1.3", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.3.0", Replaces: "a.v1.2.2"},
+		}},
+		{Schema: "olm.channel", Name: "stable-v1", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.2.0"},
+			{Name: "a.v1.2.1", Replaces: "a.v1.2.0"},
+			{Name: "a.v1.2.2", Replaces: "a.v1.2.1", SkipRange: ">=1.2.0 <1.2.2"},
+			{Name: "a.v1.3.0", Replaces: "a.v1.2.2"},
+		}},
+	}, out.Channels)
+	// every channel is a valid upgrade graph, with a single head
+	for i := range out.Channels {
+		_, err := channelHead(&out.Channels[i])
+		require.NoError(t, err)
+	}
+
+	t.Run("flagged heads", func(t *testing.T) {
+		input := fmt.Sprintf("schema: olm.semver\ngenerateSkipRange: true\nstable:\n  bundles:\n  - image: %s\n    head: true\n  - image: %s\n", bundles[0].image, bundles[1].image)
+		_, err := Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+		require.EqualError(t, err, "render: flagged channel heads cannot be combined with generateSkipRange")
+	})
+
+	t.Run("stable is a subset of candidate", func(t *testing.T) {
+		input := fmt.Sprintf("schema: olm.semver\ngenerateSkipRange: true\ncandidate:\n  bundles:\n  - image: %[1]s\n  - image: %[2]s\n  - image: %[3]s\nstable:\n  bundles:\n  - image: %[1]s\n  - image: %[3]s\n",
+			bundles[0].image, bundles[1].image, bundles[2].image)
+		out, err := Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+		require.NoError(t, err)
+		// stable's skipRange harmlessly covers 1.2.1, which only candidate has
+		require.Contains(t, out.Channels, declcfg.Channel{Schema: "olm.channel", Name: "stable-v1.2", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.2.0"},
+			{Name: "a.v1.2.2", Replaces: "a.v1.2.0", SkipRange: ">=1.2.0 <1.2.2"},
+		}})
+	})
+}
+
+func TestChannelClassifier(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.1.0", "1.2.0", "1.3.0")
+	input := "schema: olm.semver\nstable:\n  bundles:\n"
+	for _, b := range bundles {
+		input += fmt.Sprintf("  - image: %s\n", b.image)
+	}
+	newTemplate := func(classifier func(semver.Version, string) []string) Template {
+		return Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...), ChannelClassifier: classifier}
+	}
+
+	// even minors are long-term support releases, and every release is in the archetype's all channel
+	out, err := newTemplate(func(v semver.Version, archetype string) []string {
+		names := []string{archetype + "-all"}
+		if v.Minor%2 == 0 {
+			names = append(names, archetype+"-lts")
+		}
+		return names
+	}).Render(context.Background())
+	require.NoError(t, err)
+	require.ElementsMatch(t, []declcfg.Channel{
+		{Schema: "olm.channel", Name: "stable-all", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.0.0", Replaces: "", Skips: []string{}},
+			{Name: "a.v1.1.0", Replaces: "a.v1.0.0", Skips: []string{}},
+			{Name: "a.v1.2.0", Replaces: "a.v1.1.0", Skips: []string{"a.v1.0.0"}},
+			{Name: "a.v1.3.0", Replaces: "a.v1.2.0", Skips: []string{"a.v1.0.0", "a.v1.1.0"}},
+		}},
+		{Schema: "olm.channel", Name: "stable-lts", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.0.0", Replaces: "", Skips: []string{}},
+			{Name: "a.v1.2.0", Replaces: "a.v1.0.0", Skips: []string{}},
+		}},
+	}, out.Channels)
+
+	_, err = newTemplate(func(semver.Version, string) []string { return []string{"not a name"} }).Render(context.Background())
+	require.ErrorContains(t, err, `render: invalid classified channels: [invalid channel name "not a name" for stable bundle "a.v1.0.0"`)
+}
+
+func TestCustomChannels(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.1.0", "1.2.0")
+	input := fmt.Sprintf(`---
+schema: olm.semver
+customChannels:
+  preview:
+    priority: -1
+    bundles:
+    - image: %[3]s
+  eus:
+    priority: 3
+    bundles:
+    - image: %[1]s
+stable:
+  bundles:
+  - image: %[1]s
+  - image: %[2]s
+`, bundles[0].image, bundles[1].image, bundles[2].image)
+	out, err := Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+	require.NoError(t, err)
+
+	var names []string
+	for _, ch := range out.Channels {
+		names = append(names, ch.Name)
+	}
+	require.ElementsMatch(t, []string{"preview-v1.2", "stable-v1.0", "stable-v1.1", "eus-v1.0"}, names)
+	// eus is the most stable archetype, despite its lower version
+	require.Equal(t, "eus-v1.0", out.Packages[0].DefaultChannel)
+
+	for _, tt := range []struct {
+		name, custom, err string
+	}{
+		{name: "built-in name", custom: "stable:\n    priority: 5", err: `custom channel "stable" has the name of a built-in channel archetype`},
+		{name: "duplicate priority", custom: "eus:\n    priority: 1", err: `custom channel "eus" has the same priority 1 as channel archetype "fast"`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			input := fmt.Sprintf("schema: olm.semver\ncustomChannels:\n  %s\n    bundles:\n    - image: %s\n", tt.custom, bundles[0].image)
+			_, err := Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+			require.EqualError(t, err, "render: unable to read file: readFile: "+tt.err)
+		})
+	}
+}
+
+func TestDefaultChannelOverride(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.1.0", "2.0.0")
+	newTemplate := func(defaultChannel string) Template {
+		input := fmt.Sprintf("schema: olm.semver\ndefaultChannel: %s\ncandidate:\n  bundles:\n  - image: %s\nstable:\n  bundles:\n", defaultChannel, bundles[2].image)
+		for _, b := range bundles[:2] {
+			input += fmt.Sprintf("  - image: %s\n", b.image)
+		}
+		return Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...)}
+	}
+
+	var selected string
+	tmpl := newTemplate("candidate-v2.0")
+	tmpl.OnDefaultChannelSelected = func(name string, _ string, _ semver.Version) { selected = name }
+	out, err := tmpl.Render(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "candidate-v2.0", out.Packages[0].DefaultChannel)
+	require.Equal(t, "candidate-v2.0", selected)
+
+	_, err = newTemplate("fast-v1.0").Render(context.Background())
+	require.EqualError(t, err, `render: default channel "fast-v1.0" is not one of the generated channels`)
+}
+
+func TestSkipVersions(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.0.1", "1.0.2", "1.1.0")
+	newTemplate := func(skip string) Template {
+		input := "schema: olm.semver\nskipVersions: [" + skip + "]\ngenerateMajorChannels: true\ngenerateMinorChannels: false\nstable:\n  bundles:\n"
+		for _, b := range bundles {
+			input += fmt.Sprintf("  - image: %s\n", b.image)
+		}
+		return Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...)}
+	}
+
+	out, err := newTemplate("1.0.0").Render(context.Background())
+	require.NoError(t, err)
+	require.Len(t, out.Bundles, 4)
+	require.Equal(t, []declcfg.Channel{
+		{Schema: "olm.channel", Name: "stable-v1", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.0.0", Replaces: "", Skips: nil},
+			// the known-bad version is skipped by its successor, but remains installable and upgradeable
+			{Name: "a.v1.0.1", Replaces: "", Skips: []string{"a.v1.0.0"}},
+			{Name: "a.v1.0.2", Replaces: "", Skips: []string{"a.v1.0.0", "a.v1.0.1"}},
+			{Name: "a.v1.1.0", Replaces: "a.v1.0.2", Skips: []string{"a.v1.0.0", "a.v1.0.1"}},
+		}},
+	}, out.Channels)
+
+	// a middle version, already skipped by the head of its Y-stream, is not added twice
+	out, err = newTemplate("1.0.1").Render(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"a.v1.0.0", "a.v1.0.1"}, out.Channels[0].Entries[2].Skips)
+
+	_, err = newTemplate("1.0.5").Render(context.Background())
+	require.EqualError(t, err, `render: invalid skip versions: skip version "1.0.5" is not the version of any bundle`)
+}
+
+func TestBundleFiles(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.1.0")
+	dir := writeTestBundleDir(t, testBundle{pkg: "a", version: "1.2.0"})
+	input := fmt.Sprintf("schema: olm.semver\nstable:\n  bundles:\n  - image: %s\n  - image: %s\n  - file: %s\n", bundles[0].image, bundles[1].image, dir)
+
+	out, err := Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+	require.NoError(t, err)
+	images := map[string]string{}
+	for _, b := range out.Bundles {
+		images[b.Name] = b.Image
+	}
+	// bundles rendered from directories are identified by their paths
+	require.Equal(t, map[string]string{"a.v1.0.0": bundles[0].image, "a.v1.1.0": bundles[1].image, "a.v1.2.0": dir}, images)
+	require.Equal(t, "stable-v1.2", out.Packages[0].DefaultChannel)
+
+	t.Run("image and file", func(t *testing.T) {
+		input := fmt.Sprintf("schema: olm.semver\nstable:\n  bundles:\n  - image: %s\n    file: %s\n", bundles[0].image, dir)
+		_, err := Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+		require.EqualError(t, err, fmt.Sprintf("render: bundle entry specifies both image %q and file %q, expected exactly one", bundles[0].image, dir))
+	})
+	t.Run("neither image nor file", func(t *testing.T) {
+		input := "schema: olm.semver\nstable:\n  bundles:\n  - head: true\n"
+		_, err := Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+		require.EqualError(t, err, "render: bundle entry specifies neither an image nor a file")
+	})
+}
+
+func TestBundleOCILayouts(t *testing.T) {
+	bundles := testBundles("a", "1.0.0")
+	layout := writeTestBundleOCILayout(t, testBundle{pkg: "a", version: "1.1.0"})
+	input := fmt.Sprintf("schema: olm.semver\nstable:\n  bundles:\n  - image: %s\n  - ociLayout: %s\n", bundles[0].image, layout)
+
+	out, err := Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+	require.NoError(t, err)
+	images := map[string]string{}
+	for _, b := range out.Bundles {
+		images[b.Name] = b.Image
+	}
+	// bundles rendered from OCI layouts are identified by their paths
+	require.Equal(t, map[string]string{"a.v1.0.0": bundles[0].image, "a.v1.1.0": layout}, images)
+	require.Equal(t, "stable-v1.1", out.Packages[0].DefaultChannel)
+
+	t.Run("invalid layouts", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "missing")
+		incomplete := writeTestBundleOCILayout(t, testBundle{pkg: "a", version: "1.2.0"})
+		require.NoError(t, os.WriteFile(filepath.Join(incomplete, "index.json"), []byte(`{"schemaVersion": 2, "manifests": []}`), 0644))
+		input := fmt.Sprintf("schema: olm.semver\nstable:\n  bundles:\n  - ociLayout: %s\n  - ociLayout: %s\n", missing, incomplete)
+		_, err := Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+		require.EqualError(t, err, fmt.Sprintf("render: invalid OCI layouts: [%q: open %s: no such file or directory, %q: index.json lists 0 manifests, expected exactly 1]", missing, filepath.Join(missing, ocispec.ImageLayoutFile), incomplete))
+	})
+	t.Run("image and OCI layout", func(t *testing.T) {
+		input := fmt.Sprintf("schema: olm.semver\nstable:\n  bundles:\n  - image: %s\n    ociLayout: %s\n", bundles[0].image, layout)
+		_, err := Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+		require.EqualError(t, err, fmt.Sprintf("render: bundle entry specifies OCI layout %q along with an image or a file, expected exactly one", layout))
+	})
+}
+
+func TestPrereleasePolicy(t *testing.T) {
+	bundles := testBundles("a", "1.1.0", "1.2.0-rc.1", "1.2.0", "1.2.1-alpha")
+	render := func(policy string) (*declcfg.DeclarativeConfig, *RenderReport, error) {
+		data := "schema: olm.semver\nprereleasePolicy: " + policy + "\ngenerateMajorChannels: true\ngenerateMinorChannels: true\nstable:\n  bundles:\n"
+		for _, b := range bundles {
+			data += fmt.Sprintf("  - image: %s\n", b.image)
+		}
+		return Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}.RenderWithReport(context.Background())
+	}
+	channelEntries := func(out *declcfg.DeclarativeConfig) map[string][]declcfg.ChannelEntry {
+		m := map[string][]declcfg.ChannelEntry{}
+		for _, ch := range out.Channels {
+			m[ch.Name] = ch.Entries
+		}
+		return m
+	}
+
+	// prereleases precede their release version, and follow the release versions they are patches of
+	out, report, err := render("include")
+	require.NoError(t, err)
+	require.Len(t, out.Bundles, 4)
+	require.Empty(t, report.Warnings)
+	require.Equal(t, map[string][]declcfg.ChannelEntry{
+		"stable-v1": {
+			{Name: "a.v1.1.0", Skips: []string{}},
+			{Name: "a.v1.2.0-rc.1"},
+			{Name: "a.v1.2.0"},
+			{Name: "a.v1.2.1-alpha", Replaces: "a.v1.1.0", Skips: []string{"a.v1.2.0", "a.v1.2.0-rc.1"}},
+		},
+		"stable-v1.1": {
+			{Name: "a.v1.1.0", Skips: []string{}},
+		},
+		"stable-v1.2": {
+			{Name: "a.v1.2.0-rc.1"},
+			{Name: "a.v1.2.0"},
+			{Name: "a.v1.2.1-alpha", Replaces: "a.v1.1.0", Skips: []string{"a.v1.2.0", "a.v1.2.0-rc.1"}},
+		},
+	}, channelEntries(out))
+
+	out, report, err = render("exclude")
+	require.NoError(t, err)
+	require.Len(t, out.Bundles, 2)
+	require.Equal(t, []string{
+		`prerelease bundle "a.v1.2.0-rc.1" was excluded by the prerelease policy`,
+		`prerelease bundle "a.v1.2.1-alpha" was excluded by the prerelease policy`,
+	}, report.Warnings)
+	require.Equal(t, map[string][]declcfg.ChannelEntry{
+		"stable-v1": {
+			{Name: "a.v1.1.0", Skips: []string{}},
+			{Name: "a.v1.2.0", Replaces: "a.v1.1.0", Skips: []string{}},
+		},
+		"stable-v1.1": {
+			{Name: "a.v1.1.0", Skips: []string{}},
+		},
+		"stable-v1.2": {
+			{Name: "a.v1.2.0", Replaces: "a.v1.1.0", Skips: []string{}},
+		},
+	}, channelEntries(out))
+
+	_, _, err = render("latest")
+	require.EqualError(t, err, `render: unable to read file: readFile: invalid prerelease policy "latest", expected "include" or "exclude"`)
+}
+
+func TestPreviewHead(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.1.0", "1.2.0-rc.1")
+	render := func(preview string) (*declcfg.DeclarativeConfig, error) {
+		data := "schema: olm.semver\ngenerateMajorChannels: true\ngenerateMinorChannels: false\nstable:\n  bundles:\n"
+		for _, b := range bundles {
+			data += fmt.Sprintf("  - image: %s\n", b.image)
+			if b.version == preview {
+				data += "    previewHead: true\n"
+			}
+		}
+		return Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+	}
+
+	out, err := render("1.2.0-rc.1")
+	require.NoError(t, err)
+	require.Len(t, out.Bundles, 3)
+	require.Equal(t, []declcfg.Channel{
+		{Schema: "olm.channel", Name: "stable-v1", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.0.0", Skips: []string{}},
+			{Name: "a.v1.1.0", Replaces: "a.v1.0.0", Skips: []string{}},
+		}},
+		// the preview head is only in the preview channel, skipping the stable head
+		{Schema: "olm.channel", Name: "preview", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.1.0"},
+			{Name: "a.v1.2.0-rc.1", Skips: []string{"a.v1.1.0"}},
+		}},
+	}, out.Channels)
+	require.Equal(t, "stable-v1", out.Packages[0].DefaultChannel)
+
+	_, err = render("1.0.0")
+	require.EqualError(t, err, `render: stable bundle "a.v1.0.0" is marked as the preview head, but is neither a prerelease nor higher than "a.v1.2.0-rc.1"`)
+}
+
+func TestExpectedPackage(t *testing.T) {
+	bundles := testBundles("a", "1.0.0")
+	newTemplate := func(expected string) Template {
+		data := fmt.Sprintf("schema: olm.semver\nstable:\n  bundles:\n  - image: %s\n", bundles[0].image)
+		return Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...), ExpectedPackage: expected}
+	}
+
+	_, err := newTemplate("a").Render(context.Background())
+	require.NoError(t, err)
+
+	_, err = newTemplate("b").Render(context.Background())
+	require.EqualError(t, err, `render: template bundles belong to package "a", expected package "b"`)
+}
+
+func TestRenderFromConfig(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.0.1", "1.1.0")
+	data := "schema: olm.semver\ngenerateMajorChannels: true\nstable:\n  bundles:\n"
+	for _, b := range bundles {
+		data += fmt.Sprintf("  - image: %s\n", b.image)
+	}
+	expected, err := Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+	require.NoError(t, err)
+
+	// an existing catalog, with its own channels and an unrelated package
+	others, err := Template{Data: strings.NewReader(fmt.Sprintf("schema: olm.semver\nstable:\n  bundles:\n  - image: %s\n", testImage("b", "0.1.0"))), Registry: newTestRegistry(testBundles("b", "0.1.0")...)}.Render(context.Background())
+	require.NoError(t, err)
+	catalog := cloneConfig(expected)
+	catalog.Packages = append(catalog.Packages, others.Packages...)
+	catalog.Channels = append(catalog.Channels, others.Channels...)
+	catalog.Bundles = append(catalog.Bundles, others.Bundles...)
+
+	// no registry is needed, since nothing is pulled
+	out, err := Template{Data: strings.NewReader(data)}.RenderFromConfig(context.Background(), catalog)
+	require.NoError(t, err)
+	require.Equal(t, expected.Packages, out.Packages)
+	require.ElementsMatch(t, expected.Channels, out.Channels)
+	require.Equal(t, expected.Bundles, out.Bundles)
+
+	t.Run("bundle missing from the config", func(t *testing.T) {
+		catalog := cloneConfig(expected)
+		catalog.Bundles = catalog.Bundles[1:]
+		_, err := Template{Data: strings.NewReader(data)}.RenderFromConfig(context.Background(), catalog)
+		require.EqualError(t, err, fmt.Sprintf("render: bundle images [%q] are not in the provided config", expected.Bundles[0].Image))
+	})
+}
+
+// unprefixedChannelNamer names channels without the "v" of the default naming
+type unprefixedChannelNamer struct{}
+
+func (unprefixedChannelNamer) MajorChannelName(archetype string, version semver.Version) string {
+	return fmt.Sprintf("%s-%d", archetype, version.Major)
+}
+
+func (unprefixedChannelNamer) MinorChannelName(archetype string, version semver.Version) string {
+	return fmt.Sprintf("%s-%d.%d", archetype, version.Major, version.Minor)
+}
+
+type badChannelNamer struct{ unprefixedChannelNamer }
+
+func (badChannelNamer) MinorChannelName(archetype string, version semver.Version) string {
+	return fmt.Sprintf("%s %d.%d", archetype, version.Major, version.Minor)
+}
+
+func TestChannelNamer(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.1.0", "2.0.0-rc.1")
+	input := fmt.Sprintf("schema: olm.semver\ngenerateMajorChannels: true\ngenerateMinorChannels: true\ncandidate:\n  bundles:\n  - image: %s\nstable:\n  bundles:\n  - image: %s\n  - image: %s\n", bundles[2].image, bundles[0].image, bundles[1].image)
+	newTemplate := func(namer ChannelNamer) Template {
+		return Template{Data: strings.NewReader(input), Registry: newTestRegistry(bundles...), ChannelNamer: namer}
+	}
+
+	out, err := newTemplate(unprefixedChannelNamer{}).Render(context.Background())
+	require.NoError(t, err)
+	names := []string{}
+	for _, ch := range out.Channels {
+		names = append(names, ch.Name)
+	}
+	require.ElementsMatch(t, []string{"candidate-2", "candidate-2.0", "stable-1", "stable-1.0", "stable-1.1"}, names)
+	require.Equal(t, "stable-1.1", out.Packages[0].DefaultChannel)
+
+	_, err = newTemplate(badChannelNamer{}).Render(context.Background())
+	require.EqualError(t, err, `render: invalid channel names: [invalid channel name "candidate 2.0" for candidate bundle "a.v2.0.0-rc.1", invalid channel name "stable 1.0" for stable bundle "a.v1.0.0", invalid channel name "stable 1.1" for stable bundle "a.v1.1.0"]`)
+}
+
+func TestBundlePropertiesPreserved(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.1.0")
+	entries := "stable:\n  bundles:\n"
+	for _, b := range bundles {
+		entries += fmt.Sprintf("  - image: %s\n", b.image)
+	}
+	rendered, err := Template{Data: strings.NewReader("schema: olm.semver\n" + entries), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+	require.NoError(t, err)
+
+	// properties unknown to the template, like the csv metadata read by OLM v1, pass through generation untouched
+	catalog := cloneConfig(rendered)
+	for i := range catalog.Bundles {
+		catalog.Bundles[i].Properties = append(catalog.Bundles[i].Properties, property.Property{Type: "olm.csv.metadata", Value: json.RawMessage(fmt.Sprintf(`{"displayName":"A %d"}`, i))})
+	}
+	data := "schema: olm.semver\ngenerateMinorChannels: true\npackageNameOverride: b\nentryNameTemplate: '{{.Package}}-{{.Version}}'\n" + entries
+	out, err := Template{Data: strings.NewReader(data)}.RenderFromConfig(context.Background(), catalog)
+	require.NoError(t, err)
+	require.Len(t, out.Bundles, len(catalog.Bundles))
+	for i, b := range out.Bundles {
+		require.Equal(t, catalog.Bundles[i].Image, b.Image)
+		require.Equal(t, property.MustBuildPackage("b", bundles[i].version), b.Properties[0])
+		require.Equal(t, catalog.Bundles[i].Properties[1:], b.Properties[1:])
+	}
+}
+
+func TestMinVersion(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.1.0", "1.2.0", "2.0.0-rc.1")
+	render := func(floors string) (*declcfg.DeclarativeConfig, *RenderReport, error) {
+		data := fmt.Sprintf("schema: olm.semver\ngenerateMajorChannels: true\ngenerateMinorChannels: false\n%scandidate:\n  minVersion: 1.2.0\n  bundles:\n  - image: %s\n  - image: %s\nstable:\n  bundles:\n", floors, bundles[2].image, bundles[3].image)
+		for _, b := range bundles[:3] {
+			data += fmt.Sprintf("  - image: %s\n", b.image)
+		}
+		return Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}.RenderWithReport(context.Background())
+	}
+	channelEntries := func(out *declcfg.DeclarativeConfig) map[string][]declcfg.ChannelEntry {
+		m := map[string][]declcfg.ChannelEntry{}
+		for _, ch := range out.Channels {
+			m[ch.Name] = ch.Entries
+		}
+		return m
+	}
+
+	// the template's floor applies to stable, and candidate's own floor to candidate; edges skip the pruned bundles
+	out, report, err := render("minVersion: 1.1.0\n")
+	require.NoError(t, err)
+	require.Len(t, out.Bundles, 3)
+	require.Equal(t, []string{`bundles ["a.v1.0.0"] are below the minimum version and were pruned`}, report.Warnings)
+	require.Equal(t, map[string][]declcfg.ChannelEntry{
+		"candidate-v1": {{Name: "a.v1.2.0", Skips: []string{}}},
+		"candidate-v2": {{Name: "a.v2.0.0-rc.1", Skips: []string{}}},
+		"stable-v1": {
+			{Name: "a.v1.1.0", Skips: []string{}},
+			{Name: "a.v1.2.0", Replaces: "a.v1.1.0", Skips: []string{}},
+		},
+	}, channelEntries(out))
+
+	// candidate's floor takes the place of the template's
+	out, _, err = render("minVersion: 3.0.0\n")
+	require.NoError(t, err)
+	require.Len(t, out.Bundles, 2)
+	require.Equal(t, []string{"candidate-v1", "candidate-v2"}, sets.StringKeySet(channelEntries(out)).List())
+
+	_, _, err = render("minVersion: v1\n")
+	require.ErrorContains(t, err, `readFile: invalid minimum version "v1"`)
+}
+
+func TestHeadOnly(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.1.0", "1.1.1")
+	render := func(flagged string) *declcfg.DeclarativeConfig {
+		data := fmt.Sprintf("schema: olm.semver\ngenerateMajorChannels: true\ngenerateMinorChannels: true\ncandidate:\n  bundles:\n  - image: %s\nstable:\n  headOnly: true\n  bundles:\n", bundles[0].image)
+		for _, b := range bundles {
+			data += fmt.Sprintf("  - image: %s\n", b.image)
+			if b.version == flagged {
+				data += "    head: true\n"
+			}
+		}
+		out, err := Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+		require.NoError(t, err)
+		return out
+	}
+	bundleNames := func(out *declcfg.DeclarativeConfig) []string {
+		names := []string{}
+		for _, b := range out.Bundles {
+			names = append(names, b.Name)
+		}
+		return names
+	}
+
+	// bundles listed under other channel types are kept for their channels
+	out := render("")
+	require.ElementsMatch(t, []string{"a.v1.0.0", "a.v1.1.1"}, bundleNames(out))
+	require.ElementsMatch(t, []declcfg.Channel{
+		{Schema: "olm.channel", Name: "candidate-v1", Package: "a", Entries: []declcfg.ChannelEntry{{Name: "a.v1.0.0", Skips: []string{}}}},
+		{Schema: "olm.channel", Name: "candidate-v1.0", Package: "a", Entries: []declcfg.ChannelEntry{{Name: "a.v1.0.0", Skips: []string{}}}},
+		{Schema: "olm.channel", Name: "stable-v1", Package: "a", Entries: []declcfg.ChannelEntry{{Name: "a.v1.1.1", Skips: []string{}}}},
+		{Schema: "olm.channel", Name: "stable-v1.1", Package: "a", Entries: []declcfg.ChannelEntry{{Name: "a.v1.1.1", Skips: []string{}}}},
+	}, out.Channels)
+	require.Equal(t, "stable-v1.1", out.Packages[0].DefaultChannel)
+
+	// a flagged head takes the place of the highest version
+	out = render("1.1.0")
+	require.ElementsMatch(t, []string{"a.v1.0.0", "a.v1.1.0"}, bundleNames(out))
+	for _, ch := range out.Channels {
+		if strings.HasPrefix(ch.Name, "stable-") {
+			require.Equal(t, []declcfg.ChannelEntry{{Name: "a.v1.1.0", Skips: []string{}}}, ch.Entries)
+		}
+	}
+}
+
+func TestSources(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.1.0", "1.2.0-rc.1")
+	settings := "schema: olm.semver\ngenerateMajorChannels: true\ngenerateMinorChannels: false\n"
+	stable := fmt.Sprintf("schema: olm.semver\nstable:\n  bundles:\n  - image: %s\n  - image: %s\n", bundles[0].image, bundles[1].image)
+	candidate := fmt.Sprintf("schema: olm.semver\ncandidate:\n  bundles:\n  - image: %s\n  - image: %s\n", bundles[1].image, bundles[2].image)
+	render := func(data io.Reader, sources ...string) (*declcfg.DeclarativeConfig, error) {
+		readers := []io.Reader{}
+		for _, s := range sources {
+			readers = append(readers, strings.NewReader(s))
+		}
+		return Template{Data: data, Sources: readers, Registry: newTestRegistry(bundles...)}.Render(context.Background())
+	}
+
+	// settings are taken from the first source, and the same bundle may be listed under other channel types
+	out, err := render(strings.NewReader(settings), stable, candidate)
+	require.NoError(t, err)
+	require.Len(t, out.Bundles, 3)
+	require.ElementsMatch(t, []declcfg.Channel{
+		{Schema: "olm.channel", Name: "candidate-v1", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.1.0", Skips: []string{}},
+			{Name: "a.v1.2.0-rc.1", Replaces: "a.v1.1.0", Skips: []string{}},
+		}},
+		{Schema: "olm.channel", Name: "stable-v1", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.0.0", Skips: []string{}},
+			{Name: "a.v1.1.0", Replaces: "a.v1.0.0", Skips: []string{}},
+		}},
+	}, out.Channels)
+
+	// without Data, the first of Sources is the first template
+	out, err = render(nil, settings, stable)
+	require.NoError(t, err)
+	require.Len(t, out.Channels, 1)
+
+	_, err = render(strings.NewReader(settings), stable, stable)
+	require.EqualError(t, err, fmt.Sprintf("render: unable to read file: readFile: bundle %q is listed under stable by both source 1 and source 2", bundles[0].image))
+
+	_, err = render(strings.NewReader(settings), "schema: olm.foo\n")
+	require.ErrorAs(t, err, new(*ErrUnknownSchema))
+	require.EqualError(t, err, `render: unable to read file: source 1: readFile: input file has unknown schema, should be "olm.semver"`)
+}
+
+func TestBundlePackage(t *testing.T) {
+	bundle := func(props ...property.Property) declcfg.Bundle {
+		return declcfg.Bundle{Name: "a.v1.0.0", Image: "test.registry/a:v1.0.0", Properties: props}
+	}
+
+	// required packages are dependencies, not the bundle's own package
+	pkg, err := bundlePackage(bundle(property.MustBuildPackage("a", "1.0.0"), property.MustBuildPackageRequired("b", ">=1.0.0")))
+	require.NoError(t, err)
+	require.Equal(t, property.Package{PackageName: "a", Version: "1.0.0"}, *pkg)
+
+	_, err = bundlePackage(bundle(property.MustBuildPackageRequired("b", ">=1.0.0")))
+	require.EqualError(t, err, `bundle "a.v1.0.0" (image "test.registry/a:v1.0.0") has no "olm.package" property`)
+
+	_, err = bundlePackage(bundle(property.MustBuildPackage("a", "1.0.0"), property.MustBuildPackage("b", "1.0.0")))
+	require.EqualError(t, err, `bundle "a.v1.0.0" (image "test.registry/a:v1.0.0") has 2 "olm.package" properties, for packages ["a" "b"], expected exactly 1`)
+}
+
+func TestCatalogFormat(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.1.0")
+	data := "schema: olm.semver\nstable:\n  bundles:\n"
+	for _, b := range bundles {
+		data += fmt.Sprintf("  - image: %s\n", b.image)
+	}
+	render := func(format string) (*declcfg.DeclarativeConfig, error) {
+		return Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...), CatalogFormat: format}.Render(context.Background())
+	}
+
+	out, err := render(action.CurrentFormat)
+	require.NoError(t, err)
+	require.Len(t, out.Bundles, 2)
+	for _, b := range out.Bundles {
+		props, err := property.Parse(b.Properties)
+		require.NoError(t, err)
+		require.Empty(t, props.BundleObjects)
+		require.Len(t, props.CSVMetadatas, 1)
+	}
+
+	// the original format needs no migration
+	out, err = render(action.FormatBundleObject)
+	require.NoError(t, err)
+	for _, b := range out.Bundles {
+		props, err := property.Parse(b.Properties)
+		require.NoError(t, err)
+		require.NotEmpty(t, props.BundleObjects)
+		require.Empty(t, props.CSVMetadatas)
+	}
+
+	_, err = render("v2")
+	require.ErrorContains(t, err, `render: unknown catalog format "v2"`)
+}
+
+func TestReproducibleOutput(t *testing.T) {
+	bundles := testBundles("a", "0.1.0", "0.2.0", "1.0.0", "1.1.0", "1.1.1", "2.0.0", "2.1.0", "3.0.0")
+	data := "schema: olm.semver\ngenerateMajorChannels: true\ngenerateMinorChannels: true\n"
+	for _, arch := range []string{"candidate", "fast", "stable"} {
+		data += arch + ":\n  bundles:\n"
+		for _, b := range bundles {
+			data += fmt.Sprintf("  - image: %s\n", b.image)
+		}
+	}
+	render := func() string {
+		out, err := Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+		require.NoError(t, err)
+		require.True(t, sort.SliceIsSorted(out.Channels, func(i, j int) bool { return out.Channels[i].Name < out.Channels[j].Name }))
+		var buf bytes.Buffer
+		require.NoError(t, WriteConfig(out, &buf, FormatJSON))
+		return buf.String()
+	}
+
+	expected := render()
+	for i := 0; i < 5; i++ {
+		require.Equal(t, expected, render())
+	}
+}
+
+func TestCombineConfigs(t *testing.T) {
+	pkg := declcfg.Package{Schema: "olm.package", Name: "a", DefaultChannel: "stable"}
+	cfgs := []declcfg.DeclarativeConfig{
+		{Packages: []declcfg.Package{pkg}, Bundles: []declcfg.Bundle{{Name: "a.v1.0.0"}}},
+		{Packages: []declcfg.Package{pkg}, Bundles: []declcfg.Bundle{{Name: "a.v1.1.0"}}},
+	}
+	out, err := combineConfigs(cfgs)
+	require.NoError(t, err)
+	require.Equal(t, []declcfg.Package{pkg}, out.Packages)
+	require.Len(t, out.Bundles, 2)
+
+	other := pkg
+	other.DefaultChannel = "fast"
+	cfgs[1].Packages = []declcfg.Package{other}
+	_, err = combineConfigs(cfgs)
+	require.EqualError(t, err, `rendered packages named "a" differ`)
+}
+
+func TestGenerateCancelled(t *testing.T) {
+	channelOperatorVersions := bundleVersions{
+		stableChannelArchetype: {
+			"a-v1.0.0": semver.MustParse("1.0.0"),
+			"a-v1.0.1": semver.MustParse("1.0.1"),
+			"a-v1.1.0": semver.MustParse("1.1.0"),
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, skipRange := range []bool{false, true} {
+		sv := &semverTemplate{GenerateMajorChannels: true, GenerateMinorChannels: true, GenerateSkipRange: skipRange, pkg: "a"}
+		_, err := sv.generateChannels(ctx, &channelOperatorVersions)
+		require.ErrorIs(t, err, context.Canceled)
+	}
+
+	bundles := testBundles("a", "1.0.0", "1.1.0")
+	data := "schema: olm.semver\nstable:\n  bundles:\n"
+	for _, b := range bundles {
+		data += fmt.Sprintf("  - image: %s\n", b.image)
+	}
+	catalog, err := Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+	require.NoError(t, err)
+	_, err = Template{Data: strings.NewReader(data)}.RenderFromConfig(ctx, catalog)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestInheritChannels(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "1.1.0", "1.2.0-rc.1")
+	data := fmt.Sprintf(`schema: olm.semver
+generateMinorChannels: false
+generateMajorChannels: true
+inheritChannels: %%t
+candidate:
+  bundles:
+  - image: %s
+fast:
+  bundles:
+  - image: %s
+stable:
+  bundles:
+  - image: %s
+`, bundles[2].image, bundles[1].image, bundles[0].image)
+	render := func(inherit bool) (*declcfg.DeclarativeConfig, *RenderReport, error) {
+		return Template{Data: strings.NewReader(fmt.Sprintf(data, inherit)), Registry: newTestRegistry(bundles...)}.RenderWithReport(context.Background())
+	}
+	entries := func(out *declcfg.DeclarativeConfig) map[string][]string {
+		names := make(map[string][]string)
+		for _, ch := range out.Channels {
+			for _, e := range ch.Entries {
+				names[ch.Name] = append(names[ch.Name], e.Name)
+			}
+		}
+		return names
+	}
+
+	out, _, err := render(false)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{
+		"candidate-v1": {"a.v1.2.0-rc.1"},
+		"fast-v1":      {"a.v1.1.0"},
+		"stable-v1":    {"a.v1.0.0"},
+	}, entries(out))
+
+	out, _, err = render(true)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{
+		"candidate-v1": {"a.v1.0.0", "a.v1.1.0", "a.v1.2.0-rc.1"},
+		"fast-v1":      {"a.v1.0.0", "a.v1.1.0"},
+		"stable-v1":    {"a.v1.0.0"},
+	}, entries(out))
+	require.Equal(t, "stable-v1", out.Packages[0].DefaultChannel)
+
+	// identical inherited archetypes are not reported
+	data = fmt.Sprintf("schema: olm.semver\ninheritChannels: true\nstable:\n  bundles:\n  - image: %s\n  - image: %s\n", bundles[0].image, bundles[1].image)
+	out, report, err := Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}.RenderWithReport(context.Background())
+	require.NoError(t, err)
+	require.Len(t, out.Channels, 6)
+	require.Empty(t, report.Warnings)
+
+	data = "schema: olm.semver\ninheritChannels: true\nchannels:\n- name: alpha\n  bundles:\n  - image: " + bundles[0].image + "\n"
+	_, err = Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+	require.ErrorContains(t, err, "readFile: declared channels cannot inherit bundles")
+}
+
+func TestBuildMetadataPolicy(t *testing.T) {
+	bundles := testBundles("a", "1.1.0", "1.2.0+build.5", "1.2.0+build.4")
+	for i := range bundles {
+		// "+" is not valid in an image tag
+		bundles[i].image = testImage("a", strings.ReplaceAll(bundles[i].version, "+", "_"))
+	}
+	render := func(policy string) (*declcfg.DeclarativeConfig, error) {
+		data := fmt.Sprintf("schema: olm.semver\nbuildMetadataPolicy: %q\nstable:\n  bundles:\n", policy)
+		for _, b := range bundles {
+			data += fmt.Sprintf("  - image: %s\n", b.image)
+		}
+		return Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+	}
+
+	for _, policy := range []string{"", "error"} {
+		_, err := render(policy)
+		var conflict *ErrBuildMetadataConflict
+		require.ErrorAs(t, err, &conflict)
+	}
+
+	out, err := render("order")
+	require.NoError(t, err)
+	require.Equal(t, []declcfg.Channel{
+		{Schema: "olm.channel", Name: "stable-v1.1", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.1.0", Skips: []string{}},
+		}},
+		{Schema: "olm.channel", Name: "stable-v1.2", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.2.0+build.4"},
+			{Name: "a.v1.2.0+build.5", Replaces: "a.v1.1.0", Skips: []string{"a.v1.2.0+build.4"}},
+		}},
+	}, out.Channels)
+	require.Equal(t, "stable-v1.2", out.Packages[0].DefaultChannel)
+
+	_, err = render("ignore")
+	require.EqualError(t, err, `render: unable to read file: readFile: invalid build metadata policy "ignore", expected "error" or "order"`)
+
+	t.Run("order with generateSkipRange", func(t *testing.T) {
+		data := "schema: olm.semver\nbuildMetadataPolicy: order\ngenerateSkipRange: true\nstable:\n  bundles:\n"
+		for _, b := range bundles {
+			data += fmt.Sprintf("  - image: %s\n", b.image)
+		}
+		out, err := Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+		require.NoError(t, err)
+		entries := map[string]declcfg.ChannelEntry{}
+		for _, e := range out.Channels[1].Entries {
+			entries[e.Name] = e
+		}
+		require.Equal(t, "stable-v1.2", out.Channels[1].Name)
+		// build.5 orders after build.4, so its replaces edge runs from a lower version
+		require.Equal(t, "a.v1.2.0+build.4", entries["a.v1.2.0+build.5"].Replaces)
+	})
+}
+
+func TestChannelMutator(t *testing.T) {
+	bundles := testBundles("a", "1.0.0", "2.0.0")
+	data := fmt.Sprintf("schema: olm.semver\ngenerateMajorChannels: true\ngenerateMinorChannels: false\nstable:\n  bundles:\n  - image: %s\n  - image: %s\n", bundles[0].image, bundles[1].image)
+	render := func(mutator func([]declcfg.Channel) ([]declcfg.Channel, error)) (*declcfg.DeclarativeConfig, error) {
+		return Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...), ChannelMutator: mutator}.Render(context.Background())
+	}
+
+	out, err := render(func(channels []declcfg.Channel) ([]declcfg.Channel, error) {
+		for i := range channels {
+			if channels[i].Name == "stable-v1" {
+				channels[i].Name = "legacy"
+			}
+			if channels[i].Name == "stable-v2" {
+				channels[i].Entries = append([]declcfg.ChannelEntry{{Name: "a.v1.0.0"}}, channels[i].Entries...)
+				channels[i].Entries[1].Replaces = "a.v1.0.0"
+			}
+		}
+		return channels, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "stable-v2", out.Packages[0].DefaultChannel)
+	require.Equal(t, []declcfg.Channel{
+		{Schema: "olm.channel", Name: "legacy", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.0.0", Skips: []string{}},
+		}},
+		{Schema: "olm.channel", Name: "stable-v2", Package: "a", Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.0.0"},
+			{Name: "a.v2.0.0", Replaces: "a.v1.0.0", Skips: []string{}},
+		}},
+	}, out.Channels)
+
+	_, err = render(func(channels []declcfg.Channel) ([]declcfg.Channel, error) {
+		return channels[:1], nil
+	})
+	require.EqualError(t, err, `render: default channel "stable-v2" is not one of the generated channels`)
+
+	_, err = render(func(channels []declcfg.Channel) ([]declcfg.Channel, error) {
+		return nil, fmt.Errorf("no edges")
+	})
+	require.EqualError(t, err, "render: channel mutator: no edges")
+}
@@ -0,0 +1,73 @@
+package semver
+
+import (
+	"context"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+// ConfigSink accepts rendered catalog objects one at a time, so that consumers need not hold the whole catalog.  An
+// error returned by the sink stops rendering.
+type ConfigSink interface {
+	AddPackage(declcfg.Package) error
+	AddChannel(declcfg.Channel) error
+	AddBundle(declcfg.Bundle) error
+	AddOther(declcfg.Meta) error
+}
+
+// DeclarativeConfigSink is a ConfigSink which collects the objects it accepts into a DeclarativeConfig
+type DeclarativeConfigSink struct {
+	Config declcfg.DeclarativeConfig
+}
+
+var _ ConfigSink = &DeclarativeConfigSink{}
+
+func (s *DeclarativeConfigSink) AddPackage(p declcfg.Package) error {
+	s.Config.Packages = append(s.Config.Packages, p)
+	return nil
+}
+
+func (s *DeclarativeConfigSink) AddChannel(c declcfg.Channel) error {
+	s.Config.Channels = append(s.Config.Channels, c)
+	return nil
+}
+
+func (s *DeclarativeConfigSink) AddBundle(b declcfg.Bundle) error {
+	s.Config.Bundles = append(s.Config.Bundles, b)
+	return nil
+}
+
+func (s *DeclarativeConfigSink) AddOther(m declcfg.Meta) error {
+	s.Config.Others = append(s.Config.Others, m)
+	return nil
+}
+
+// RenderToSink renders the template and pushes the result to sink: packages first, then channels, bundles, and any
+// other objects.  Objects are only pushed once the whole template has rendered and validated successfully.
+func (t Template) RenderToSink(ctx context.Context, sink ConfigSink) error {
+	out, err := t.Render(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range out.Packages {
+		if err := sink.AddPackage(p); err != nil {
+			return err
+		}
+	}
+	for _, c := range out.Channels {
+		if err := sink.AddChannel(c); err != nil {
+			return err
+		}
+	}
+	for _, b := range out.Bundles {
+		if err := sink.AddBundle(b); err != nil {
+			return err
+		}
+	}
+	for _, m := range out.Others {
+		if err := sink.AddOther(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
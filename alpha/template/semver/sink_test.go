@@ -0,0 +1,55 @@
+package semver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+type failingSink struct {
+	DeclarativeConfigSink
+}
+
+func (s *failingSink) AddChannel(declcfg.Channel) error {
+	return errors.New("sink full")
+}
+
+func TestRenderToSink(t *testing.T) {
+	bundles := testBundles("a", "0.1.0", "0.1.1", "0.2.0")
+	newTemplate := func() Template {
+		return Template{
+			Data: strings.NewReader(fmt.Sprintf(`---
+schema: olm.semver
+stable:
+  bundles:
+  - image: %s
+  - image: %s
+  - image: %s
+`, bundles[0].image, bundles[1].image, bundles[2].image)),
+			Registry: newTestRegistry(bundles...),
+		}
+	}
+
+	expected, err := newTemplate().Render(context.Background())
+	require.NoError(t, err)
+
+	sink := &DeclarativeConfigSink{}
+	require.NoError(t, newTemplate().RenderToSink(context.Background(), sink))
+	require.ElementsMatch(t, expected.Packages, sink.Config.Packages)
+	require.ElementsMatch(t, expected.Channels, sink.Config.Channels)
+	require.ElementsMatch(t, expected.Bundles, sink.Config.Bundles)
+	require.ElementsMatch(t, expected.Others, sink.Config.Others)
+
+	t.Run("sink errors stop rendering", func(t *testing.T) {
+		sink := &failingSink{}
+		require.EqualError(t, newTemplate().RenderToSink(context.Background(), sink), "sink full")
+		require.Len(t, sink.Config.Packages, 1)
+		require.Empty(t, sink.Config.Bundles)
+	})
+}
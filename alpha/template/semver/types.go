@@ -3,8 +3,14 @@ package semver
 import (
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/blang/semver/v4"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/property"
 	"github.com/operator-framework/operator-registry/pkg/image"
 )
 
@@ -12,33 +18,391 @@ import (
 type Template struct {
 	Data     io.Reader
 	Registry image.Registry
+
+	// Sources, when set, are additional templates read after Data (if set), for templates maintained as several files.
+	// Each is validated as a complete template, and their bundle lists are merged: see readSources.
+	Sources []io.Reader
+
+	// MaxConcurrency is the maximum number of bundle images rendered at once; if zero, runtime.NumCPU() is used
+	MaxConcurrency int
+
+	// BundleCache, when set, is consulted before each bundle image is rendered, and stores the images rendered; see
+	// BundleCache.  Bundle files are not cached.
+	BundleCache BundleCache
+
+	// AuthFiles, when set, are docker config files from which registry credentials are merged (later files taking
+	// precedence) for a registry created for the render.  It cannot be combined with Registry.
+	AuthFiles []string
+
+	// VersionFilter, when set, limits the generated channels (and the bundles included in the
+	// output) to bundles whose version satisfies the range
+	VersionFilter semver.Range
+
+	// DefaultChannelVersionRange, when set, restricts the default channel to channels whose head version satisfies the
+	// range, e.g. to keep a not-yet-GA major version from becoming the default
+	DefaultChannelVersionRange semver.Range
+
+	// IncludeChannels, when set, limits the output to the generated channels whose names match one of these patterns
+	// (in path.Match syntax).  ExcludeChannels drops the generated channels whose names match one of its patterns.
+	// Bundles are kept either way, and the default channel is selected from the remaining channels.
+	IncludeChannels []string
+	ExcludeChannels []string
+
+	// CascadingArchetypeDefault selects the default channel strictly by archetype: the highest channel head of stable
+	// if it has bundles, else of fast, else of candidate, regardless of version differences between archetypes
+	CascadingArchetypeDefault bool
+
+	// DefaultChannelEntry selects which entry of the default channel is the package's recommended starting point:
+	// DefaultChannelEntryHead (the default) or DefaultChannelEntryTail.  With DefaultChannelEntryTail, the default
+	// channel is the most stable channel with the lowest tail, and the tail bundle is recorded in the package's
+	// olm.semver.defaultEntry property.  The channels' edges are the same either way.
+	DefaultChannelEntry string
+
+	// ChannelClassifier, when set, replaces the generated major and minor channels: it is called once for each bundle of
+	// each channel archetype with the bundle's version and archetype name, and returns the names of the channels the bundle
+	// belongs to.  Each channel's edges are generated as for any other channel.  A channel may only contain bundles of a
+	// single archetype.
+	ChannelClassifier func(version semver.Version, archetype string) []string
+
+	// ChannelNamer, when set, names the generated major and minor channels in place of DefaultChannelNamer
+	ChannelNamer ChannelNamer
+
+	// ChannelMutator, when set, is called with the generated channels once they are validated and the default channel
+	// has been selected, and returns the channels to output in their place, as to add edges or rename channels.  The
+	// default channel must still be one of the returned channels.
+	ChannelMutator func([]declcfg.Channel) ([]declcfg.Channel, error)
+
+	// OnDefaultChannelSelected, when set, is invoked once per render after the package's default
+	// channel has been selected and the generated channels have been validated, with the channel name, its archetype,
+	// and the version of its head.  It is not invoked for renders which fail validation; RenderVariants invokes it
+	// once for each variant.
+	OnDefaultChannelSelected func(name string, archetype string, version semver.Version)
+
+	// MaxTemplateSize is the maximum number of bytes read from Data; if zero, DefaultMaxTemplateSize is used
+	MaxTemplateSize int64
+	// ReadTimeout bounds the time spent reading Data; if zero, reads are not time-limited
+	ReadTimeout time.Duration
+
+	// WarnOnBuildMetadata adds a report warning for every bundle whose version carries build metadata, which is not
+	// meaningful for ordering
+	WarnOnBuildMetadata bool
+
+	// WarnOnSingleBundle adds a report warning when the output contains a single bundle, and so no upgrade graph exists
+	// yet, as for a package's first release
+	WarnOnSingleBundle bool
+
+	// WarnOnPromotionAnomalies adds a report warning for every prerelease version in the stable archetype, and for every
+	// stable release version which appears in neither the candidate nor the fast archetype, as a release or prerelease
+	WarnOnPromotionAnomalies bool
+
+	// WarnOnRepeatedImages adds a report warning for every bundle image (or file) listed under several channel archetypes,
+	// since each is rendered only once, and so has the same version in all of them
+	WarnOnRepeatedImages bool
+
+	// PreviousChannelNames, when set, are the channel names generated by a previous render of the template.  A report
+	// warning is added for each which is no longer generated, as when the channel naming has changed; new channels are
+	// expected, and are not reported.
+	PreviousChannelNames []string
+
+	// EmitPredecessors populates the report's Predecessors, mapping each channel's versions to the versions they replace
+	EmitPredecessors bool
+
+	// EmitRenderDurations populates the report's RenderDurations, the time taken to render each bundle image
+	EmitRenderDurations bool
+
+	// VerifyTagMatchesVersion fails rendering when a bundle image referenced by a semver tag has a different version
+	VerifyTagMatchesVersion bool
+
+	// RequireStrictPromotion fails rendering unless every stable bundle is also listed in the candidate and fast channels
+	RequireStrictPromotion bool
+
+	// Platform, when set as "<os>/<arch>", limits the generated channels (and the bundles included in the output) to
+	// bundles which support that platform, according to their CSV's operatorframework.io/os and /arch labels
+	Platform string
+
+	// ExpectedPackage, when set, fails rendering unless it is the package name detected from the bundles, to catch a
+	// template rendered by the wrong job.  It only asserts the name; see packageNameOverride to change it.
+	ExpectedPackage string
+
+	// NormalizePackageName lowercases the package name detected from the bundles throughout the output
+	NormalizePackageName bool
+
+	// DisambiguateBundleNames renames rendered bundles which share a name (as when identical CSV names are reused across
+	// rebuilds) by suffixing their versions, so that every bundle and channel entry can be told apart
+	DisambiguateBundleNames bool
+
+	// SkipsWarningThreshold is the number of skips of a generated channel entry above which a warning suggesting
+	// generateSkipRange is logged and reported; if zero, DefaultSkipsWarningThreshold is used, and if negative, no
+	// warning is given
+	SkipsWarningThreshold int
+
+	// Log, when set, receives the warnings about the generated channels which Render cannot otherwise return, such as
+	// those for long skips lists
+	Log *logrus.Entry
+
+	// MaxSkipsPerEntry, when positive, fails rendering if any generated channel entry skips more bundles than this
+	MaxSkipsPerEntry int
+
+	// SkipChannelValidation disables the check that every entry of each generated channel is reachable from the
+	// channel's head, for advanced users who intentionally generate disconnected channels
+	SkipChannelValidation bool
+
+	// AllowedRegistries, when set, restricts the template's bundle images to those hosted by one of the listed registries
+	AllowedRegistries []string
+
+	// Policy, when set, declares rules the template must satisfy; see ApplyPolicy
+	Policy *Policy
+
+	// CatalogFormat, when set, migrates the output's deprecated property shapes to this catalog format, as
+	// action.MigrateConfig does, e.g. action.CurrentFormat
+	CatalogFormat string
+
+	// Catalog, when set, is an existing file-based catalog against which the template's range bundle entries are
+	// resolved to the images of its bundles
+	Catalog *declcfg.DeclarativeConfig
+
+	// FailOnDanglingBundles fails rendering, instead of reporting a warning, when a rendered bundle is not an entry of
+	// any channel
+	FailOnDanglingBundles bool
+}
+
+// ChannelNamer names the channels generated for the major and minor versions of each channel archetype
+type ChannelNamer interface {
+	MajorChannelName(archetype string, version semver.Version) string
+	MinorChannelName(archetype string, version semver.Version) string
+}
+
+// DefaultChannelNamer names channels "<archetype>-v<major>" and "<archetype>-v<major>.<minor>"
+type DefaultChannelNamer struct{}
+
+func (DefaultChannelNamer) MajorChannelName(archetype string, version semver.Version) string {
+	return fmt.Sprintf("%s-v%d", archetype, version.Major)
+}
+
+func (DefaultChannelNamer) MinorChannelName(archetype string, version semver.Version) string {
+	return fmt.Sprintf("%s-v%d.%d", archetype, version.Major, version.Minor)
 }
 
 // IO structs -- BEGIN
 type semverTemplateBundleEntry struct {
 	Image string `json:"image,omitempty"`
+	// File is the path of an unpacked bundle directory, rendered in place of an image; the bundle then takes the path
+	// as its image.  Each entry specifies exactly one of Image, File, or OCILayout.
+	File string `json:"file,omitempty"`
+	// OCILayout is the path of an OCI image layout directory holding a single bundle image, which is unpacked and
+	// rendered in place of an image; like a file, the bundle then takes the path as its image
+	OCILayout string `json:"ociLayout,omitempty"`
+	// TestedFrom lists the versions from which upgrades into this bundle have been validated
+	TestedFrom []string `json:"testedFrom,omitempty"`
+	// Head marks the bundle as the intended head of the channels generated for it, even if it is not the highest version
+	Head bool `json:"head,omitempty"`
+	// Ordinal overrides semver precedence among the prereleases of the same release version, which are ordered by
+	// ascending ordinal
+	Ordinal *int `json:"ordinal,omitempty"`
+	// PreviewHead holds the bundle back from the channels of its channel archetype, and makes it the head of the
+	// "preview" channel instead, skipping the archetype's head, so that users may opt into testing it
+	PreviewHead bool `json:"previewHead,omitempty"`
+	// Range, in place of an image or a file, selects the bundles of Package in the template's Catalog whose versions
+	// satisfy it; the entry is replaced by an image entry for each of them, see resolveRanges
+	Range   string `json:"range,omitempty"`
+	Package string `json:"package,omitempty"`
+}
+
+// ref returns the reference the entry's bundle is rendered from
+func (e semverTemplateBundleEntry) ref() string {
+	if e.File != "" {
+		return e.File
+	}
+	if e.OCILayout != "" {
+		return e.OCILayout
+	}
+	return e.Image
 }
 
 type semverTemplateChannelBundles struct {
 	Bundles []semverTemplateBundleEntry `json:"bundles,omitempty"`
+	// SeedFromPrevious links the first Y-stream of each new major version to the head of the prior major version
+	// within the same channel archetype
+	SeedFromPrevious bool `json:"seedFromPrevious,omitempty"`
+	// Recommended lists the versions of this archetype's bundles which are added to the curated "recommended" channel
+	Recommended []string `json:"recommended,omitempty"`
+	// MinVersion, when set, prunes this archetype's bundles whose versions are below it, in place of the template's
+	MinVersion string `json:"minVersion,omitempty"`
+	// HeadOnly reduces this archetype to its head bundle, for install-only packages without upgrades: its channels have a
+	// single entry, without replaces or skips, and its other bundles are pruned
+	HeadOnly bool `json:"headOnly,omitempty"`
+}
+
+// semverTemplateCustomChannel declares an additional channel archetype, ordered among the others by its priority
+type semverTemplateCustomChannel struct {
+	Priority int `json:"priority"`
+	semverTemplateChannelBundles
+}
+
+// semverTemplateChannelPlan declares a channel and its exact membership; only its edges are generated
+type semverTemplateChannelPlan struct {
+	Name string `json:"name"`
+	semverTemplateChannelBundles
+}
+
+// semverTemplatePackage declares the channel archetypes of one package of a template of several packages
+type semverTemplatePackage struct {
+	Name      string                       `json:"name"`
+	Candidate semverTemplateChannelBundles `json:"candidate,omitempty"`
+	Fast      semverTemplateChannelBundles `json:"fast,omitempty"`
+	Stable    semverTemplateChannelBundles `json:"stable,omitempty"`
+}
+
+// semverTemplateBundleProperties lists the properties added to the bundles whose versions satisfy a range
+type semverTemplateBundleProperties struct {
+	Versions   string              `json:"versions"`
+	Properties []property.Property `json:"properties"`
+}
+
+// semverTemplateDeprecation deprecates either the named bundle or the bundles whose versions satisfy a range
+type semverTemplateDeprecation struct {
+	Bundle   string `json:"bundle,omitempty"`
+	Versions string `json:"versions,omitempty"`
+	Message  string `json:"message"`
 }
 
 type semverTemplate struct {
 	Schema                string                       `json:"schema"`
+	SchemaVersion         int                          `json:"schemaVersion,omitempty"`
 	GenerateMajorChannels bool                         `json:"generateMajorChannels,omitempty"`
 	GenerateMinorChannels bool                         `json:"generateMinorChannels,omitempty"`
 	Candidate             semverTemplateChannelBundles `json:"candidate,omitempty"`
 	Fast                  semverTemplateChannelBundles `json:"fast,omitempty"`
 	Stable                semverTemplateChannelBundles `json:"stable,omitempty"`
+	// CustomChannels declares channel archetypes beyond candidate, fast, and stable, keyed by name.  Their priorities,
+	// relative to candidate (0), fast (1), and stable (2), place them in order of increasing stability.
+	CustomChannels map[string]*semverTemplateCustomChannel `json:"customChannels,omitempty"`
+	// GenerateSkipRange replaces the skips of each Y-stream head with a skipRange covering the lower versions of its
+	// Y-stream; discrete skips are then not generated, and instead every entry replaces the next-lower version
+	GenerateSkipRange bool `json:"generateSkipRange,omitempty"`
+	// EntryNameTemplate is an optional Go text/template, evaluated with .Package, .Version, and .BundleName, used to
+	// compute the name of each bundle and every channel entry referring to it
+	EntryNameTemplate string `json:"entryNameTemplate,omitempty"`
+	// DefaultChannelOverride, when set, names the package's default channel in place of the one selected from the
+	// generated channels; it must be one of them
+	DefaultChannelOverride string `json:"defaultChannel,omitempty"`
+	// PrereleasePolicy decides how bundles with prerelease versions are treated: prereleasePolicyInclude (the default)
+	// orders them before their release versions, by semver precedence, and prereleasePolicyExclude drops them
+	PrereleasePolicy string `json:"prereleasePolicy,omitempty"`
+	// BuildMetadataPolicy decides how bundles whose versions differ only by build metadata are treated:
+	// buildMetadataPolicyError (the default) fails rendering, since semver gives them equal precedence, and
+	// buildMetadataPolicyOrder orders them by their build metadata, compared lexically
+	BuildMetadataPolicy string `json:"buildMetadataPolicy,omitempty"`
+	// MinVersion, when set, prunes the bundles of every channel archetype whose versions are below it, unless the channel
+	// archetype sets its own minVersion
+	MinVersion string `json:"minVersion,omitempty"`
+	// SkipVersions lists known-bad versions, which are kept in the output but added to the skips of the next-higher entry
+	// of each channel they are in
+	SkipVersions []string `json:"skipVersions,omitempty"`
+	// Deprecations marks output bundles as deprecated, producing an olm.deprecations object
+	Deprecations []semverTemplateDeprecation `json:"deprecations,omitempty"`
+	// BundleProperties attaches additional properties to the output bundles whose versions satisfy a range
+	BundleProperties []semverTemplateBundleProperties `json:"bundleProperties,omitempty"`
+	// PackageNameOverride, when set, renames the package detected from the bundles throughout the output
+	PackageNameOverride string `json:"packageNameOverride,omitempty"`
+	// InheritChannels includes the bundles of each channel archetype in every less stable channel archetype, as when a
+	// bundle listed only under stable is also generated into the fast and candidate channels
+	InheritChannels bool `json:"inheritChannels,omitempty"`
+	// Packages, when set, renders several packages into one catalog: each block declares the channel archetypes of one
+	// package, which are generated as if by a template of their own, with the template's other attributes
+	Packages []semverTemplatePackage `json:"packages,omitempty"`
+	// Channels, when set, replaces the channel archetypes: each listed channel is generated with exactly the listed
+	// bundles, in order of increasing stability, and only the edges between them are computed
+	Channels []semverTemplateChannelPlan `json:"channels,omitempty"`
+
+	pkg             string                              `json:"-"` // the derived package name
+	heads           map[channelArchetype]sets.String    `json:"-"` // names of the bundles flagged as channel heads
+	ordinals        map[channelArchetype]map[string]int `json:"-"` // prerelease ordering overrides by bundle name
+	defaultChannel  string                              `json:"-"` // detected "most stable" channel head
+	defaultHead     *semver.Version                     `json:"-"` // head version of the default channel when it was selected
+	defaultArch     channelArchetype                    `json:"-"` // archetype of the default channel when it was selected
+	unrendered      []string                            `json:"-"` // bundle images skipped by a best-effort render
+	ociLayouts      sets.String                         `json:"-"` // the bundle entries' OCI image layout directories
+	renderDurations []BundleRenderDuration              `json:"-"` // wall-clock render time of each rendered bundle image
+	preview         *previewHead                        `json:"-"` // the bundle marked as the preview head, if any
+	diagnostics     *Diagnostics                        `json:"-"` // decisions explained for RenderWithDiagnostics, if requested
+	channelOrigins  map[string]channelOrigin            `json:"-"` // the archetype and kind each generated channel was created for
 
-	pkg            string `json:"-"` // the derived package name
-	defaultChannel string `json:"-"` // detected "most stable" channel head
+	includeChannels     []string                                 `json:"-"`
+	excludeChannels     []string                                 `json:"-"`
+	platform            *platform                                `json:"-"`
+	classified          map[channelArchetype]map[string][]string `json:"-"` // ChannelClassifier's channels, by archetype and bundle
+	defaultEntryTail    bool                                     `json:"-"`
+	cascadingDefault    bool                                     `json:"-"`
+	defaultChannelRange semver.Range                             `json:"-"`
+	namer               ChannelNamer                             `json:"-"`
 }
 
 // IO structs -- END
 
+// recommendedChannelName is the name of the curated channel of the archetypes' recommended versions
+const recommendedChannelName = "recommended"
+
+// previewChannelName is the name of the channel headed by the bundle marked as the preview head
+const previewChannelName = "preview"
+
+// previewHead is a bundle held back from its channel archetype to head the preview channel, skipping base, the head of
+// the archetype's remaining bundles (if any)
+type previewHead struct {
+	name string
+	base string
+}
+
+// testedFromPropertyType is the channel property type recording the validated upgrade sources of a channel head
+const testedFromPropertyType = "olm.semver.testedFrom"
+
+type testedFrom struct {
+	Bundle   string   `json:"bundle"`
+	Versions []string `json:"versions"`
+}
+
+// defaultEntryPropertyType is the package property type recording the recommended starting bundle of the default
+// channel, when it is not the channel head
+const defaultEntryPropertyType = "olm.semver.defaultEntry"
+
+type defaultEntry struct {
+	Bundle  string `json:"bundle"`
+	Version string `json:"version"`
+}
+
+// the entries of the default channel which may be surfaced as the package's recommended starting point
+const (
+	DefaultChannelEntryHead = "head"
+	DefaultChannelEntryTail = "tail"
+)
+
+// the treatments of prerelease bundle versions
+const (
+	prereleasePolicyInclude = "include"
+	prereleasePolicyExclude = "exclude"
+)
+
+// the treatments of bundle versions which differ only by build metadata
+const (
+	buildMetadataPolicyError = "error"
+	buildMetadataPolicyOrder = "order"
+)
+
 const schema string = "olm.semver"
 
+// the range of schema versions of the template format which can be read; a template which does not declare its
+// schemaVersion is of the current version
+const (
+	minSchemaVersion     = 1
+	currentSchemaVersion = 1
+)
+
+// DefaultMaxTemplateSize is the default upper bound on the size of a template file
+const DefaultMaxTemplateSize int64 = 16 << 20
+
+// DefaultSkipsWarningThreshold is the default number of skips of a channel entry above which a warning is given
+const DefaultSkipsWarningThreshold = 50
+
 // channel "archetypes", restricted in this iteration to just these
 type channelArchetype string
 
@@ -51,15 +415,6 @@ const (
 // mapping channel name --> stability, where higher values indicate greater stability
 var channelPriorities = map[channelArchetype]int{candidateChannelArchetype: 0, fastChannelArchetype: 1, stableChannelArchetype: 2}
 
-// sorting capability for a slice according to the assigned channelPriorities
-type byChannelPriority []channelArchetype
-
-func (b byChannelPriority) Len() int { return len(b) }
-func (b byChannelPriority) Less(i, j int) bool {
-	return channelPriorities[b[i]] < channelPriorities[b[j]]
-}
-func (b byChannelPriority) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
-
 type streamType string
 
 const minorStreamType streamType = "minor"
@@ -74,12 +429,21 @@ type bundleVersions map[channelArchetype]map[string]semver.Version // e.g. srcv[
 // later as the package's defaultChannel attribute
 type highwaterChannel struct {
 	archetype channelArchetype
+	priority  int
+	kind      streamType
 	version   semver.Version
 	name      string
 }
 
 func (h *highwaterChannel) gt(ih *highwaterChannel) bool {
-	return (channelPriorities[h.archetype] > channelPriorities[ih.archetype]) || (h.version.GT(ih.version))
+	return (h.priority > ih.priority) || (h.version.GT(ih.version))
+}
+
+// channelOrigin records the channel archetype (or declared channel) a channel was generated for, and whether it is a
+// minor or major channel; the kind of classified and declared channels is empty
+type channelOrigin struct {
+	archetype channelArchetype
+	kind      streamType
 }
 
 type entryTuple struct {
@@ -89,6 +453,7 @@ type entryTuple struct {
 	parent  string
 	index   int
 	version semver.Version
+	head    bool
 }
 
 func (t entryTuple) String() string {
@@ -0,0 +1,659 @@
+package semver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/docker/distribution/reference"
+
+	"k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/property"
+	"github.com/operator-framework/operator-registry/pkg/image/containerdregistry"
+)
+
+// validatePackageChannels ensures that every package in the output has at least one channel, and that its default
+// channel is one of them
+func validatePackageChannels(cfg *declcfg.DeclarativeConfig) error {
+	channelsByPackage := make(map[string]sets.String)
+	for _, ch := range cfg.Channels {
+		if _, ok := channelsByPackage[ch.Package]; !ok {
+			channelsByPackage[ch.Package] = sets.NewString()
+		}
+		channelsByPackage[ch.Package].Insert(ch.Name)
+	}
+
+	errs := []error{}
+	for _, p := range cfg.Packages {
+		channels, ok := channelsByPackage[p.Name]
+		if !ok || channels.Len() == 0 {
+			errs = append(errs, fmt.Errorf("package %q has no channels", p.Name))
+			continue
+		}
+		if !channels.Has(p.DefaultChannel) {
+			errs = append(errs, fmt.Errorf("package %q default channel %q is not one of its channels %v", p.Name, p.DefaultChannel, channels.List()))
+		}
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid package channels: %v", errors.NewAggregate(errs))
+	}
+	return nil
+}
+
+// validateChannelPackages ensures that every channel in the output belongs to one of its packages
+func validateChannelPackages(cfg *declcfg.DeclarativeConfig) error {
+	packages := sets.NewString()
+	for _, p := range cfg.Packages {
+		packages.Insert(p.Name)
+	}
+
+	errs := []error{}
+	for _, ch := range cfg.Channels {
+		if !packages.Has(ch.Package) {
+			errs = append(errs, fmt.Errorf("channel %q references package %q, which is not one of %v", ch.Name, ch.Package, packages.List()))
+		}
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid channel packages: %v", errors.NewAggregate(errs))
+	}
+	return nil
+}
+
+// validateSkipRanges ensures that each channel entry's skipRange only covers versions in the same Y-stream as the entry
+// itself, so that a malformed or overly-broad range cannot skip bundles belonging to other channels.  A range may cover
+// bundles of the Y-stream which the channel does not have, as when stable lists a subset of candidate's bundles.
+func validateSkipRanges(channels []declcfg.Channel, versions *bundleVersions) error {
+	bundleVersion := make(map[string]semver.Version)
+	for _, bundles := range *versions {
+		for name, v := range bundles {
+			bundleVersion[name] = v
+		}
+	}
+	names := make([]string, 0, len(bundleVersion))
+	for name := range bundleVersion {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	errs := []error{}
+	for _, ch := range channels {
+		for _, e := range ch.Entries {
+			if e.SkipRange == "" {
+				continue
+			}
+			skipRange, err := semver.ParseRange(e.SkipRange)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("channel %q entry %q has invalid skipRange %q: %v", ch.Name, e.Name, e.SkipRange, err))
+				continue
+			}
+			entryVersion, ok := bundleVersion[e.Name]
+			if !ok {
+				continue
+			}
+			for _, name := range names {
+				v := bundleVersion[name]
+				if name == e.Name || !skipRange(v) {
+					continue
+				}
+				if !getMinorVersion(v).EQ(getMinorVersion(entryVersion)) {
+					errs = append(errs, fmt.Errorf("channel %q entry %q skipRange %q covers version %q from a different Y-stream", ch.Name, e.Name, e.SkipRange, v))
+				}
+			}
+		}
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid skipRanges: %v", errors.NewAggregate(errs))
+	}
+	return nil
+}
+
+// danglingBundles returns the (sorted) names of bundles in the output which no channel entry refers to.  Such
+// bundles bloat the catalog without being installable from any channel.
+func danglingBundles(cfg *declcfg.DeclarativeConfig) []string {
+	entries := sets.NewString()
+	for _, ch := range cfg.Channels {
+		for _, e := range ch.Entries {
+			entries.Insert(e.Name)
+		}
+	}
+	dangling := sets.NewString()
+	for _, b := range cfg.Bundles {
+		if !entries.Has(b.Name) {
+			dangling.Insert(b.Name)
+		}
+	}
+	return dangling.List()
+}
+
+// validateImageReferences ensures that every bundle image parses as an image reference, as the registry parses it, so
+// that all malformed references are reported at once, before any image is pulled
+func validateImageReferences(images []string) error {
+	sorted := append([]string{}, images...)
+	sort.Strings(sorted)
+	errs := []error{}
+	for _, img := range sorted {
+		if _, err := reference.ParseNormalizedNamed(img); err != nil {
+			errs = append(errs, fmt.Errorf("image %q: %v", img, err))
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid bundle image references: %v", errors.NewAggregate(errs))
+	}
+	return nil
+}
+
+// validateOCILayouts ensures that every OCI layout directory exists and holds a single, complete image, before any
+// bundle is rendered
+func validateOCILayouts(layouts []string) error {
+	errs := []error{}
+	for _, dir := range layouts {
+		if _, err := containerdregistry.ReadOCILayout(dir); err != nil {
+			errs = append(errs, fmt.Errorf("%q: %v", dir, err))
+		}
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid OCI layouts: %v", errors.NewAggregate(errs))
+	}
+	return nil
+}
+
+// validateAllowedRegistries ensures that every image is hosted by one of the allowed registries
+func validateAllowedRegistries(images []string, allowed []string) error {
+	allowedHosts := sets.NewString(allowed...)
+	disallowed := []string{}
+	for _, img := range images {
+		ref, err := reference.ParseNormalizedNamed(img)
+		if err != nil || !allowedHosts.Has(reference.Domain(ref)) {
+			disallowed = append(disallowed, img)
+		}
+	}
+	if len(disallowed) != 0 {
+		sort.Strings(disallowed)
+		return fmt.Errorf("bundle images %q are not from an allowed registry %v", disallowed, allowedHosts.List())
+	}
+	return nil
+}
+
+// validateTagsMatchVersions ensures that each bundle whose image is referenced by a semver tag has that version.  Tags
+// which are not semver are not checked.
+func validateTagsMatchVersions(cfg *declcfg.DeclarativeConfig) error {
+	errs := []error{}
+	for _, b := range cfg.Bundles {
+		ref, err := reference.ParseNormalizedNamed(b.Image)
+		if err != nil {
+			continue
+		}
+		tagged, ok := ref.(reference.Tagged)
+		if !ok {
+			continue
+		}
+		tagVersion, err := semver.ParseTolerant(tagged.Tag())
+		if err != nil {
+			continue
+		}
+		pkg, err := bundlePackage(b)
+		if err != nil {
+			return err
+		}
+		v, err := semver.Parse(pkg.Version)
+		if err != nil {
+			// reported with more context when versions are collected
+			continue
+		}
+		if !v.EQ(tagVersion) {
+			errs = append(errs, fmt.Errorf("bundle image %q is tagged %q, but has version %q", b.Image, tagged.Tag(), v))
+		}
+	}
+	if len(errs) != 0 {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+		return fmt.Errorf("bundle image tags do not match versions: %v", errors.NewAggregate(errs))
+	}
+	return nil
+}
+
+// validateMajorChannelCompleteness ensures that, when both major and minor channels are generated, each major channel's
+// entries are exactly the union of the entries of its minor channels.  Channels excluded from the output are ignored.
+func validateMajorChannelCompleteness(channels []declcfg.Channel, versions *bundleVersions, namer ChannelNamer) error {
+	entries := make(map[string]sets.String, len(channels))
+	for _, ch := range channels {
+		entries[ch.Name] = sets.NewString()
+		for _, e := range ch.Entries {
+			entries[ch.Name].Insert(e.Name)
+		}
+	}
+
+	errs := []error{}
+	archs := make([]string, 0, len(*versions))
+	for arch := range *versions {
+		archs = append(archs, string(arch))
+	}
+	sort.Strings(archs)
+	for _, arch := range archs {
+		bundles := (*versions)[channelArchetype(arch)]
+		for _, name := range sortedBundleNames(bundles) {
+			v := bundles[name]
+			minor, major := namer.MinorChannelName(arch, v), namer.MajorChannelName(arch, v)
+			if _, ok := entries[minor]; !ok {
+				// excluded from the output
+				continue
+			}
+			if _, ok := entries[major]; !ok {
+				continue
+			}
+			inMinor, inMajor := entries[minor].Has(name), entries[major].Has(name)
+			switch {
+			case inMinor && !inMajor:
+				errs = append(errs, fmt.Errorf("version %q is in channel %q but missing from channel %q", v, minor, major))
+			case inMajor && !inMinor:
+				errs = append(errs, fmt.Errorf("version %q is in channel %q but missing from channel %q", v, major, minor))
+			}
+		}
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("incomplete major channels: %v", errors.NewAggregate(errs))
+	}
+	return nil
+}
+
+// validateReplacesOrder ensures that each entry replaces a lower version than its own, when the replaced bundle is in
+// the same channel.  Versions are ordered as the channel's archetype orders them, so that ordinals and ordered build
+// metadata are respected.  Bundles flagged as heads are intentionally terminal, and are exempt.
+func (sv *semverTemplate) validateReplacesOrder(channels []declcfg.Channel, versions *bundleVersions, heads sets.String) error {
+	bundleVersion := make(map[string]semver.Version)
+	for _, bundles := range *versions {
+		for name, v := range bundles {
+			bundleVersion[name] = v
+		}
+	}
+
+	errs := []error{}
+	for _, ch := range channels {
+		members := sets.NewString()
+		for _, e := range ch.Entries {
+			members.Insert(e.Name)
+		}
+		for _, e := range ch.Entries {
+			if e.Replaces == "" || !members.Has(e.Replaces) || heads.Has(e.Name) {
+				continue
+			}
+			v, ok := bundleVersion[e.Name]
+			replaced, replacedOK := bundleVersion[e.Replaces]
+			if !ok || !replacedOK {
+				continue
+			}
+			if !sv.versionLess(sv.channelOrigins[ch.Name].archetype, e.Replaces, replaced, e.Name, v) {
+				errs = append(errs, fmt.Errorf("channel %q entry %q (%s) replaces %q, which is not a lower version (%s)", ch.Name, e.Name, v, e.Replaces, replaced))
+			}
+		}
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid replaces edges: %v", errors.NewAggregate(errs))
+	}
+	return nil
+}
+
+// validateReplacesTargets ensures that every entry replaces an entry of its own channel, or, where a replaces edge
+// links channels by design (as the head of a minor channel replaces the head of the previous Y-stream's channel), the
+// head of the previous Y-stream (or, for major channels, major version) of the same archetype, so that no generated
+// edge dangles or crosses into another archetype or kind of channel.  Channels which are not generated from an
+// archetype, as the recommended channel, may replace any bundle of the template.
+func (sv *semverTemplate) validateReplacesTargets(channels []declcfg.Channel, versions *bundleVersions) error {
+	bundles := sets.NewString()
+	for _, names := range *versions {
+		for name := range names {
+			bundles.Insert(name)
+		}
+	}
+
+	errs := []error{}
+	for _, ch := range channels {
+		members := sets.NewString()
+		for _, e := range ch.Entries {
+			members.Insert(e.Name)
+		}
+		origin, generated := sv.channelOrigins[ch.Name]
+		for _, e := range ch.Entries {
+			if e.Replaces == "" || members.Has(e.Replaces) {
+				continue
+			}
+			switch {
+			case !generated:
+				if !bundles.Has(e.Replaces) {
+					errs = append(errs, fmt.Errorf("channel %q entry %q replaces %q, which is neither an entry of the channel nor a bundle of the template", ch.Name, e.Name, e.Replaces))
+				}
+			case origin.kind == "":
+				// classified and declared channels are each linked on their own
+				errs = append(errs, fmt.Errorf("channel %q entry %q replaces %q, which is not an entry of the channel", ch.Name, e.Name, e.Replaces))
+			default:
+				v, ok := (*versions)[origin.archetype][e.Name]
+				if !ok || e.Replaces != sv.previousStreamHead(origin.archetype, origin.kind, v, versions) {
+					errs = append(errs, fmt.Errorf("channel %q entry %q replaces %q, which is neither an entry of the channel nor the head of the previous %s stream of %s", ch.Name, e.Name, e.Replaces, origin.kind, origin.archetype))
+				}
+			}
+		}
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("dangling replaces edges: %v", errors.NewAggregate(errs))
+	}
+	return nil
+}
+
+// previousStreamHead returns the head of the Y-stream (or, for major kind, the major version) of the archetype which
+// precedes that of version v, or "" if none does.  A stream's head is its flagged head, if it has one, or else its
+// highest version.
+func (sv *semverTemplate) previousStreamHead(arch channelArchetype, kind streamType, v semver.Version, versions *bundleVersions) string {
+	stream := getMinorVersion
+	if kind == majorStreamType {
+		stream = getMajorVersion
+	}
+	current := stream(v)
+
+	var head string
+	var headVersion, headStream semver.Version
+	for name, bv := range (*versions)[arch] {
+		s := stream(bv)
+		if !s.LT(current) {
+			continue
+		}
+		switch {
+		case head == "" || s.GT(headStream):
+		case s.EQ(headStream) && !sv.heads[arch].Has(head) && (sv.heads[arch].Has(name) || sv.versionLess(arch, head, headVersion, name, bv)):
+		default:
+			continue
+		}
+		head, headVersion, headStream = name, bv, s
+	}
+	return head
+}
+
+// validateEntrySkips ensures that no channel entry skips itself, or lists the same skipped bundle more than once
+func validateEntrySkips(channels []declcfg.Channel) error {
+	errs := []error{}
+	for _, ch := range channels {
+		for _, e := range ch.Entries {
+			seen := sets.NewString()
+			for _, skip := range e.Skips {
+				if skip == e.Name {
+					errs = append(errs, fmt.Errorf("channel %q entry %q skips itself", ch.Name, e.Name))
+				} else if seen.Has(skip) {
+					errs = append(errs, fmt.Errorf("channel %q entry %q skips %q more than once", ch.Name, e.Name, skip))
+				}
+				seen.Insert(skip)
+			}
+		}
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid skips: %v", errors.NewAggregate(errs))
+	}
+	return nil
+}
+
+// validateAcyclicEdges ensures that the union of each channel's replaces and skips edges forms a directed acyclic
+// graph, reporting a cycle of every channel which has one
+func validateAcyclicEdges(channels []declcfg.Channel) error {
+	errs := []error{}
+	for _, ch := range channels {
+		edges := make(map[string][]string, len(ch.Entries))
+		names := make([]string, 0, len(ch.Entries))
+		for _, e := range ch.Entries {
+			names = append(names, e.Name)
+			if e.Replaces != "" {
+				edges[e.Name] = append(edges[e.Name], e.Replaces)
+			}
+			edges[e.Name] = append(edges[e.Name], e.Skips...)
+		}
+		sort.Strings(names)
+
+		// depth-first search, tracking the path to the entry being visited so a cycle can be reported in full
+		const (
+			unvisited = iota
+			visiting
+			visited
+		)
+		state := make(map[string]int, len(names))
+		path := []string{}
+		var cycle []string
+		var visit func(name string) bool
+		visit = func(name string) bool {
+			switch state[name] {
+			case visiting:
+				for i := range path {
+					if path[i] == name {
+						cycle = append(append([]string{}, path[i:]...), name)
+						break
+					}
+				}
+				return true
+			case visited:
+				return false
+			}
+			state[name] = visiting
+			path = append(path, name)
+			for _, next := range edges[name] {
+				if visit(next) {
+					return true
+				}
+			}
+			path = path[:len(path)-1]
+			state[name] = visited
+			return false
+		}
+		for _, name := range names {
+			if state[name] == unvisited && visit(name) {
+				errs = append(errs, fmt.Errorf("channel %q has an upgrade edge cycle: %s", ch.Name, strings.Join(cycle, " -> ")))
+				break
+			}
+		}
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid upgrade graph: %v", errors.NewAggregate(errs))
+	}
+	return nil
+}
+
+// validateChannelReachability ensures that every entry of each channel can be reached from the channel's head, its
+// highest version which no other entry replaces or skips, by following replaces and skips edges.  Entries which cannot
+// are orphaned: no upgrade from the head's history leads through them.
+func validateChannelReachability(channels []declcfg.Channel, versions *bundleVersions) error {
+	bundleVersion := make(map[string]semver.Version)
+	for _, bundles := range *versions {
+		for name, v := range bundles {
+			bundleVersion[name] = v
+		}
+	}
+
+	errs := []error{}
+	for _, ch := range channels {
+		if len(ch.Entries) == 0 {
+			continue
+		}
+		edges := make(map[string][]string, len(ch.Entries))
+		referenced := sets.NewString()
+		for _, e := range ch.Entries {
+			if e.Replaces != "" {
+				edges[e.Name] = append(edges[e.Name], e.Replaces)
+			}
+			edges[e.Name] = append(edges[e.Name], e.Skips...)
+			referenced.Insert(edges[e.Name]...)
+		}
+		head := ""
+		for _, e := range ch.Entries {
+			if referenced.Has(e.Name) {
+				continue
+			}
+			if head == "" || bundleVersion[e.Name].GT(bundleVersion[head]) {
+				head = e.Name
+			}
+		}
+		if head == "" {
+			errs = append(errs, fmt.Errorf("channel %q has no head", ch.Name))
+			continue
+		}
+
+		reached := sets.NewString(head)
+		for queue := []string{head}; len(queue) != 0; queue = queue[1:] {
+			for _, next := range edges[queue[0]] {
+				if !reached.Has(next) {
+					reached.Insert(next)
+					queue = append(queue, next)
+				}
+			}
+		}
+		for _, e := range ch.Entries {
+			if !reached.Has(e.Name) {
+				errs = append(errs, fmt.Errorf("channel %q entry %q is not reachable from its head %q", ch.Name, e.Name, head))
+			}
+		}
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("orphaned channel entries: %v", errors.NewAggregate(errs))
+	}
+	return nil
+}
+
+// validateMaxSkips ensures that no channel entry skips more than max bundles
+func validateMaxSkips(channels []declcfg.Channel, max int) error {
+	errs := []error{}
+	for _, ch := range channels {
+		for _, e := range ch.Entries {
+			if len(e.Skips) > max {
+				errs = append(errs, fmt.Errorf("channel %q entry %q skips %d bundles, more than the limit of %d", ch.Name, e.Name, len(e.Skips), max))
+			}
+		}
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("skips lists too long, consider generating skipRanges instead: %v", errors.NewAggregate(errs))
+	}
+	return nil
+}
+
+// validateDefaultChannel ensures that a default channel was selected, and that it is one of the generated channels, so
+// that a template which selects none fails here rather than in catalog validation
+func validateDefaultChannel(channels []declcfg.Channel, defaultChannel string) error {
+	if defaultChannel == "" {
+		if len(channels) == 0 {
+			return fmt.Errorf("no default channel was selected: no channels were generated, check that the template lists bundles")
+		}
+		return fmt.Errorf("no default channel was selected from the %d generated channels", len(channels))
+	}
+	for _, ch := range channels {
+		if ch.Name == defaultChannel {
+			return nil
+		}
+	}
+	return fmt.Errorf("default channel %q is not one of the generated channels", defaultChannel)
+}
+
+// validateDefaultChannelHead ensures that the head of the default channel is the bundle whose version it was selected
+// for, so that default channel selection and channel generation cannot silently diverge
+func validateDefaultChannelHead(channels []declcfg.Channel, defaultChannel string, head semver.Version, versions *bundleVersions) error {
+	for i := range channels {
+		if channels[i].Name != defaultChannel {
+			continue
+		}
+		name, err := channelHead(&channels[i])
+		if err != nil {
+			return fmt.Errorf("default channel: %v", err)
+		}
+		for _, bundles := range *versions {
+			if v, ok := bundles[name]; ok {
+				if !v.EQ(head) {
+					return fmt.Errorf("default channel %q was selected for head version %q, but its head %q has version %q", defaultChannel, head, name, v)
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("default channel %q head %q is not a rendered bundle", defaultChannel, name)
+	}
+	return fmt.Errorf("default channel %q is not one of the generated channels", defaultChannel)
+}
+
+// validateStrictPromotion ensures that every stable bundle has been released to both the candidate and fast channels
+func validateStrictPromotion(versions *bundleVersions) error {
+	errs := []error{}
+	stable := (*versions)[stableChannelArchetype]
+	for _, name := range sortedBundleNames(stable) {
+		missing := []string{}
+		for _, ring := range []channelArchetype{candidateChannelArchetype, fastChannelArchetype} {
+			if _, ok := (*versions)[ring][name]; !ok {
+				missing = append(missing, string(ring))
+			}
+		}
+		if len(missing) != 0 {
+			errs = append(errs, fmt.Errorf("stable version %q was not released to %v", stable[name], missing))
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("bundles skipped promotion: %v", errors.NewAggregate(errs))
+	}
+	return nil
+}
+
+// bundlePropertyKey identifies a bundle property for comparison; package properties are compared by type alone, since
+// the package may be renamed during generation
+func bundlePropertyKey(p property.Property) string {
+	if p.Type == property.TypePackage {
+		return p.Type
+	}
+	return p.Type + "=" + string(p.Value)
+}
+
+// bundlePropertiesByImage records the property keys of each bundle in the config, by bundle image, before generation
+func bundlePropertiesByImage(cfg *declcfg.DeclarativeConfig) map[string][]string {
+	keys := make(map[string][]string, len(cfg.Bundles))
+	for _, b := range cfg.Bundles {
+		for _, p := range b.Properties {
+			keys[b.Image] = append(keys[b.Image], bundlePropertyKey(p))
+		}
+	}
+	return keys
+}
+
+// validateBundleProperties ensures that generation neither dropped nor added properties of the output bundles: each has
+// exactly the properties it was rendered with, allowing only for a renamed package
+func validateBundleProperties(cfg *declcfg.DeclarativeConfig, rendered map[string][]string) error {
+	errs := []error{}
+	for _, b := range cfg.Bundles {
+		before := make(map[string]int)
+		for _, key := range rendered[b.Image] {
+			before[key]++
+		}
+		for _, p := range b.Properties {
+			key := bundlePropertyKey(p)
+			if before[key] == 0 {
+				errs = append(errs, fmt.Errorf("bundle %q gained a %q property", b.Name, p.Type))
+				continue
+			}
+			before[key]--
+		}
+		lost := []string{}
+		for key, n := range before {
+			for ; n > 0; n-- {
+				lost = append(lost, strings.SplitN(key, "=", 2)[0])
+			}
+		}
+		sort.Strings(lost)
+		for _, typ := range lost {
+			errs = append(errs, fmt.Errorf("bundle %q lost a %q property", b.Name, typ))
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("modified bundle properties: %v", errors.NewAggregate(errs))
+	}
+	return nil
+}
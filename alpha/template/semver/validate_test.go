@@ -0,0 +1,477 @@
+package semver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/blang/semver/v4"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/property"
+)
+
+func TestValidatePackageChannels(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  declcfg.DeclarativeConfig
+		err  string
+	}{
+		{
+			name: "valid",
+			cfg: declcfg.DeclarativeConfig{
+				Packages: []declcfg.Package{{Schema: "olm.package", Name: "a", DefaultChannel: "stable-v1.0"}},
+				Channels: []declcfg.Channel{{Schema: "olm.channel", Name: "stable-v1.0", Package: "a"}},
+			},
+		},
+		{
+			name: "package whose versions were all excluded",
+			cfg: declcfg.DeclarativeConfig{
+				Packages: []declcfg.Package{
+					{Schema: "olm.package", Name: "a", DefaultChannel: "stable-v1.0"},
+					{Schema: "olm.package", Name: "b", DefaultChannel: ""},
+				},
+				Channels: []declcfg.Channel{{Schema: "olm.channel", Name: "stable-v1.0", Package: "a"}},
+			},
+			err: `invalid package channels: package "b" has no channels`,
+		},
+		{
+			name: "default channel not among package channels",
+			cfg: declcfg.DeclarativeConfig{
+				Packages: []declcfg.Package{{Schema: "olm.package", Name: "a", DefaultChannel: "stable-v2.0"}},
+				Channels: []declcfg.Channel{
+					{Schema: "olm.channel", Name: "stable-v1.0", Package: "a"},
+					{Schema: "olm.channel", Name: "stable-v2.0", Package: "b"},
+				},
+			},
+			err: `invalid package channels: package "a" default channel "stable-v2.0" is not one of its channels [stable-v1.0]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePackageChannels(&tt.cfg)
+			if tt.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestValidateSkipRanges(t *testing.T) {
+	versions := bundleVersions{
+		stableChannelArchetype: {
+			"a.v1.1.0": semver.MustParse("1.1.0"),
+			"a.v1.2.0": semver.MustParse("1.2.0"),
+			"a.v1.2.1": semver.MustParse("1.2.1"),
+			"a.v1.2.2": semver.MustParse("1.2.2"),
+		},
+	}
+	channel := func(skipRange string) []declcfg.Channel {
+		return []declcfg.Channel{{
+			Schema:  "olm.channel",
+			Name:    "stable-v1.2",
+			Package: "a",
+			Entries: []declcfg.ChannelEntry{
+				{Name: "a.v1.2.0"},
+				{Name: "a.v1.2.1"},
+				{Name: "a.v1.2.2", SkipRange: skipRange},
+			},
+		}}
+	}
+
+	tests := []struct {
+		name      string
+		skipRange string
+		err       string
+	}{
+		{
+			name:      "no skipRange",
+			skipRange: "",
+		},
+		{
+			name:      "within the Y-stream",
+			skipRange: ">=1.2.0 <1.2.2",
+		},
+		{
+			name:      "spans a minor boundary",
+			skipRange: ">=1.1.0 <1.2.2",
+			err:       `invalid skipRanges: channel "stable-v1.2" entry "a.v1.2.2" skipRange ">=1.1.0 <1.2.2" covers version "1.1.0" from a different Y-stream`,
+		},
+		{
+			name:      "invalid",
+			skipRange: "foo",
+			err:       `invalid skipRanges: channel "stable-v1.2" entry "a.v1.2.2" has invalid skipRange "foo": Could not get version from string: "foo"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSkipRanges(channel(tt.skipRange), &versions)
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestDanglingBundles(t *testing.T) {
+	cfg := declcfg.DeclarativeConfig{
+		Channels: []declcfg.Channel{{Schema: "olm.channel", Name: "stable-v1.0", Package: "a", Entries: []declcfg.ChannelEntry{{Name: "a.v1.0.0"}}}},
+		Bundles: []declcfg.Bundle{
+			{Schema: "olm.bundle", Name: "a.v1.0.1", Package: "a"},
+			{Schema: "olm.bundle", Name: "a.v1.0.0", Package: "a"},
+			{Schema: "olm.bundle", Name: "a.v0.9.0", Package: "a"},
+		},
+	}
+	require.Equal(t, []string{"a.v0.9.0", "a.v1.0.1"}, danglingBundles(&cfg))
+
+	cfg.Bundles = cfg.Bundles[1:2]
+	require.Empty(t, danglingBundles(&cfg))
+}
+
+func TestValidateChannelPackages(t *testing.T) {
+	cfg := declcfg.DeclarativeConfig{
+		Packages: []declcfg.Package{
+			{Schema: "olm.package", Name: "a", DefaultChannel: "stable-v1.0"},
+			{Schema: "olm.package", Name: "b", DefaultChannel: "stable-v1.0"},
+		},
+		Channels: []declcfg.Channel{
+			{Schema: "olm.channel", Name: "stable-v1.0", Package: "a"},
+			{Schema: "olm.channel", Name: "stable-v1.0", Package: "b"},
+		},
+	}
+	require.NoError(t, validateChannelPackages(&cfg))
+
+	cfg.Channels = append(cfg.Channels, declcfg.Channel{Schema: "olm.channel", Name: "stable-v2.0", Package: "c"})
+	require.EqualError(t, validateChannelPackages(&cfg), `invalid channel packages: channel "stable-v2.0" references package "c", which is not one of [a b]`)
+}
+
+func TestAllowedRegistries(t *testing.T) {
+	bundles := append(testBundles("a", "0.1.0"), testBundle{image: "evil.example.com/a-operator/a-bundle:v0.1.1", pkg: "a", version: "0.1.1"})
+	tmpl := Template{
+		Data: strings.NewReader(fmt.Sprintf(`---
+schema: olm.semver
+stable:
+  bundles:
+  - image: %s
+  - image: %s
+`, bundles[0].image, bundles[1].image)),
+		Registry:          newTestRegistry(bundles...),
+		AllowedRegistries: []string{"test.registry", "quay.io"},
+	}
+	_, err := tmpl.Render(context.Background())
+	require.EqualError(t, err, `render: bundle images ["evil.example.com/a-operator/a-bundle:v0.1.1"] are not from an allowed registry [quay.io test.registry]`)
+
+	require.NoError(t, validateAllowedRegistries([]string{bundles[0].image}, []string{"test.registry"}))
+}
+
+func TestValidateImageReferences(t *testing.T) {
+	bundles := testBundles("a", "0.1.0")
+	tmpl := Template{
+		Data: strings.NewReader(fmt.Sprintf(`---
+schema: olm.semver
+stable:
+  bundles:
+  - image: %s
+  - image: test.registry/A-operator/a-bundle:v0.1.1
+  - image: test.registry/a-operator/a-bundle:v0.1.2+build
+  - file: bundles/a.v0.1.3
+`, bundles[0].image)),
+		// nothing may be pulled
+		Registry: newTestRegistry(),
+	}
+	_, err := tmpl.Render(context.Background())
+	require.EqualError(t, err, `render: invalid bundle image references: [image "test.registry/A-operator/a-bundle:v0.1.1": invalid reference format: repository name must be lowercase, image "test.registry/a-operator/a-bundle:v0.1.2+build": invalid reference format]`)
+
+	require.NoError(t, validateImageReferences([]string{bundles[0].image, "quay.io/foo/olm@sha256:" + strings.Repeat("a", 64)}))
+}
+
+func TestVerifyTagMatchesVersion(t *testing.T) {
+	bundles := []testBundle{
+		{image: "test.registry/a-operator/a-bundle:1.2.0", pkg: "a", version: "1.2.1"},
+		{image: "test.registry/a-operator/a-bundle:v1.1.0", pkg: "a", version: "1.1.0"},
+		{image: "test.registry/a-operator/a-bundle:latest", pkg: "a", version: "1.0.0"},
+	}
+	newTemplate := func(images ...string) Template {
+		data := "---\nschema: olm.semver\nstable:\n  bundles:\n"
+		for _, img := range images {
+			data += fmt.Sprintf("  - image: %s\n", img)
+		}
+		return Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...), VerifyTagMatchesVersion: true}
+	}
+
+	_, err := newTemplate(bundles[0].image, bundles[1].image).Render(context.Background())
+	require.EqualError(t, err, `render: bundle image tags do not match versions: bundle image "test.registry/a-operator/a-bundle:1.2.0" is tagged "1.2.0", but has version "1.2.1"`)
+
+	// matching and non-semver tags pass
+	_, err = newTemplate(bundles[1].image, bundles[2].image).Render(context.Background())
+	require.NoError(t, err)
+}
+
+func TestValidateMajorChannelCompleteness(t *testing.T) {
+	versions := bundleVersions{
+		stableChannelArchetype: {
+			"a.v1.0.0": semver.MustParse("1.0.0"),
+			"a.v1.1.0": semver.MustParse("1.1.0"),
+			"a.v1.1.1": semver.MustParse("1.1.1"),
+		},
+	}
+	sv := &semverTemplate{GenerateMajorChannels: true, GenerateMinorChannels: true, pkg: "a"}
+	channels, err := sv.generateChannels(context.Background(), &versions)
+	require.NoError(t, err)
+	require.NoError(t, validateMajorChannelCompleteness(channels, &versions, DefaultChannelNamer{}))
+
+	// exclude a version from the major channel only
+	for i := range channels {
+		if channels[i].Name != "stable-v1" {
+			continue
+		}
+		entries := []declcfg.ChannelEntry{}
+		for _, e := range channels[i].Entries {
+			if e.Name != "a.v1.1.0" {
+				entries = append(entries, e)
+			}
+		}
+		channels[i].Entries = entries
+	}
+	require.EqualError(t, validateMajorChannelCompleteness(channels, &versions, DefaultChannelNamer{}), `incomplete major channels: version "1.1.0" is in channel "stable-v1.1" but missing from channel "stable-v1"`)
+}
+
+func TestValidateReplacesOrder(t *testing.T) {
+	versions := bundleVersions{
+		stableChannelArchetype: {
+			"a.v1.0.0": semver.MustParse("1.0.0"),
+			"a.v1.1.0": semver.MustParse("1.1.0"),
+			"a.v1.2.0": semver.MustParse("1.2.0"),
+		},
+	}
+	channels := []declcfg.Channel{{
+		Schema:  "olm.channel",
+		Name:    "stable-v1",
+		Package: "a",
+		Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.0.0"},
+			{Name: "a.v1.1.0", Replaces: "a.v1.0.0"},
+			{Name: "a.v1.2.0", Replaces: "a.v1.1.0"},
+		},
+	}}
+	require.NoError(t, (&semverTemplate{}).validateReplacesOrder(channels, &versions, sets.NewString()))
+
+	// point a replaces edge backwards, at a higher version
+	channels[0].Entries[1].Replaces = "a.v1.2.0"
+	require.EqualError(t, (&semverTemplate{}).validateReplacesOrder(channels, &versions, sets.NewString()), `invalid replaces edges: channel "stable-v1" entry "a.v1.1.0" (1.1.0) replaces "a.v1.2.0", which is not a lower version (1.2.0)`)
+
+	// unless the entry was flagged as the channel's head
+	require.NoError(t, (&semverTemplate{}).validateReplacesOrder(channels, &versions, sets.NewString("a.v1.1.0")))
+}
+
+func TestValidateReplacesTargets(t *testing.T) {
+	versions := bundleVersions{
+		candidateChannelArchetype: {
+			"a.v1.1.0-rc.1": semver.MustParse("1.1.0-rc.1"),
+		},
+		stableChannelArchetype: {
+			"a.v1.0.0": semver.MustParse("1.0.0"),
+			"a.v1.0.1": semver.MustParse("1.0.1"),
+			"a.v1.1.0": semver.MustParse("1.1.0"),
+			"a.v1.2.0": semver.MustParse("1.2.0"),
+		},
+	}
+	sv := &semverTemplate{}
+	sv.recordChannelOrigin("candidate-v1.1", candidateChannelArchetype, minorStreamType)
+	for _, name := range []string{"stable-v1.0", "stable-v1.1", "stable-v1.2"} {
+		sv.recordChannelOrigin(name, stableChannelArchetype, minorStreamType)
+	}
+	// the minor channels of several Y-streams, each head replacing the head of the previous Y-stream's channel
+	newChannels := func() []declcfg.Channel {
+		return []declcfg.Channel{
+			{Schema: "olm.channel", Name: "candidate-v1.1", Package: "a", Entries: []declcfg.ChannelEntry{
+				{Name: "a.v1.1.0-rc.1"},
+			}},
+			{Schema: "olm.channel", Name: "stable-v1.0", Package: "a", Entries: []declcfg.ChannelEntry{
+				{Name: "a.v1.0.0"},
+				{Name: "a.v1.0.1", Replaces: "a.v1.0.0"},
+			}},
+			{Schema: "olm.channel", Name: "stable-v1.1", Package: "a", Entries: []declcfg.ChannelEntry{
+				{Name: "a.v1.1.0", Replaces: "a.v1.0.1"},
+			}},
+			{Schema: "olm.channel", Name: "stable-v1.2", Package: "a", Entries: []declcfg.ChannelEntry{
+				{Name: "a.v1.2.0", Replaces: "a.v1.1.0"},
+			}},
+		}
+	}
+	require.NoError(t, sv.validateReplacesTargets(newChannels(), &versions))
+
+	// replace a bundle of another Y-stream which the template does not have
+	channels := newChannels()
+	channels[3].Entries[0].Replaces = "a.v1.1.1"
+	channels[1].Entries[1].Replaces = "a.v0.9.0"
+	require.EqualError(t, sv.validateReplacesTargets(channels, &versions), `dangling replaces edges: [channel "stable-v1.0" entry "a.v1.0.1" replaces "a.v0.9.0", which is neither an entry of the channel nor the head of the previous minor stream of stable, channel "stable-v1.2" entry "a.v1.2.0" replaces "a.v1.1.1", which is neither an entry of the channel nor the head of the previous minor stream of stable]`)
+
+	// replace the head of the previous Y-stream of another archetype, or of a Y-stream before the previous one
+	channels = newChannels()
+	channels[2].Entries[0].Replaces = "a.v1.1.0-rc.1"
+	channels[3].Entries[0].Replaces = "a.v1.0.1"
+	require.EqualError(t, sv.validateReplacesTargets(channels, &versions), `dangling replaces edges: [channel "stable-v1.1" entry "a.v1.1.0" replaces "a.v1.1.0-rc.1", which is neither an entry of the channel nor the head of the previous minor stream of stable, channel "stable-v1.2" entry "a.v1.2.0" replaces "a.v1.0.1", which is neither an entry of the channel nor the head of the previous minor stream of stable]`)
+
+	// replace a bundle of the previous Y-stream which is not its head
+	channels = newChannels()
+	channels[2].Entries[0].Replaces = "a.v1.0.0"
+	require.EqualError(t, sv.validateReplacesTargets(channels, &versions), `dangling replaces edges: channel "stable-v1.1" entry "a.v1.1.0" replaces "a.v1.0.0", which is neither an entry of the channel nor the head of the previous minor stream of stable`)
+
+	// channels which are not generated from an archetype may replace any bundle of the template
+	channels = append(newChannels(), declcfg.Channel{Schema: "olm.channel", Name: "recommended", Package: "a", Entries: []declcfg.ChannelEntry{
+		{Name: "a.v1.2.0", Replaces: "a.v1.0.0"},
+	}})
+	require.NoError(t, sv.validateReplacesTargets(channels, &versions))
+}
+
+func TestValidateEntrySkips(t *testing.T) {
+	channels := []declcfg.Channel{{
+		Schema:  "olm.channel",
+		Name:    "stable-v1.0",
+		Package: "a",
+		Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.0.0"},
+			{Name: "a.v1.0.1", Skips: []string{"a.v1.0.0"}},
+			{Name: "a.v1.0.2", Skips: []string{"a.v1.0.0", "a.v1.0.1"}},
+		},
+	}}
+	require.NoError(t, validateEntrySkips(channels))
+
+	// an override which adds the entry to its own skips, and repeats another
+	channels[0].Entries[2].Skips = append(channels[0].Entries[2].Skips, "a.v1.0.2", "a.v1.0.0")
+	require.EqualError(t, validateEntrySkips(channels), `invalid skips: [channel "stable-v1.0" entry "a.v1.0.2" skips itself, channel "stable-v1.0" entry "a.v1.0.2" skips "a.v1.0.0" more than once]`)
+}
+
+func TestValidateAcyclicEdges(t *testing.T) {
+	channels := []declcfg.Channel{{
+		Schema:  "olm.channel",
+		Name:    "stable-v1",
+		Package: "a",
+		Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.0.0"},
+			{Name: "a.v1.0.1", Skips: []string{"a.v1.0.0"}},
+			{Name: "a.v1.1.0", Replaces: "a.v1.0.1", Skips: []string{"a.v1.0.0"}},
+		},
+	}}
+	require.NoError(t, validateAcyclicEdges(channels))
+
+	// an override which has the tail skip the head closes a cycle through both kinds of edge
+	channels[0].Entries[0].Skips = []string{"a.v1.1.0"}
+	require.EqualError(t, validateAcyclicEdges(channels), `invalid upgrade graph: channel "stable-v1" has an upgrade edge cycle: a.v1.0.0 -> a.v1.1.0 -> a.v1.0.1 -> a.v1.0.0`)
+}
+
+func TestValidateChannelReachability(t *testing.T) {
+	versions := bundleVersions{
+		stableChannelArchetype: {
+			"a.v1.0.0": semver.MustParse("1.0.0"),
+			"a.v1.0.1": semver.MustParse("1.0.1"),
+			"a.v1.1.0": semver.MustParse("1.1.0"),
+		},
+	}
+	channels := []declcfg.Channel{{
+		Schema:  "olm.channel",
+		Name:    "stable-v1",
+		Package: "a",
+		Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.0.0"},
+			{Name: "a.v1.0.1", Skips: []string{"a.v1.0.0"}},
+			{Name: "a.v1.1.0", Replaces: "a.v1.0.1", Skips: []string{"a.v1.0.0"}},
+		},
+	}}
+	require.NoError(t, validateChannelReachability(channels, &versions))
+
+	// without the replaces edge, the lower Z-stream is orphaned from the highest head
+	channels[0].Entries[2].Replaces = ""
+	channels[0].Entries[2].Skips = nil
+	require.EqualError(t, validateChannelReachability(channels, &versions), `orphaned channel entries: [channel "stable-v1" entry "a.v1.0.0" is not reachable from its head "a.v1.1.0", channel "stable-v1" entry "a.v1.0.1" is not reachable from its head "a.v1.1.0"]`)
+
+	// every entry of a cycle is referenced, so there is no head to walk from
+	channels[0].Entries[0].Replaces = "a.v1.0.1"
+	channels[0].Entries = channels[0].Entries[:2]
+	require.EqualError(t, validateChannelReachability(channels, &versions), `orphaned channel entries: channel "stable-v1" has no head`)
+}
+
+func TestValidateDefaultChannelHead(t *testing.T) {
+	versions := bundleVersions{
+		stableChannelArchetype: {
+			"a.v1.0.0": semver.MustParse("1.0.0"),
+			"a.v1.0.1": semver.MustParse("1.0.1"),
+		},
+	}
+	channels := []declcfg.Channel{{
+		Schema:  "olm.channel",
+		Name:    "stable-v1.0",
+		Package: "a",
+		Entries: []declcfg.ChannelEntry{
+			{Name: "a.v1.0.0"},
+			{Name: "a.v1.0.1", Skips: []string{"a.v1.0.0"}},
+		},
+	}}
+	require.NoError(t, validateDefaultChannelHead(channels, "stable-v1.0", semver.MustParse("1.0.1"), &versions))
+
+	// selected for a version which is not the channel's head
+	require.EqualError(t, validateDefaultChannelHead(channels, "stable-v1.0", semver.MustParse("1.0.0"), &versions), `default channel "stable-v1.0" was selected for head version "1.0.0", but its head "a.v1.0.1" has version "1.0.1"`)
+	require.EqualError(t, validateDefaultChannelHead(channels, "stable-v1.1", semver.MustParse("1.0.1"), &versions), `default channel "stable-v1.1" is not one of the generated channels`)
+}
+
+func TestValidateDefaultChannel(t *testing.T) {
+	channels := []declcfg.Channel{
+		{Schema: "olm.channel", Name: "stable-v1", Package: "a"},
+		{Schema: "olm.channel", Name: "stable-v2", Package: "a"},
+	}
+	require.NoError(t, validateDefaultChannel(channels, "stable-v2"))
+
+	require.EqualError(t, validateDefaultChannel(channels, ""), "no default channel was selected from the 2 generated channels")
+	require.EqualError(t, validateDefaultChannel(nil, ""), "no default channel was selected: no channels were generated, check that the template lists bundles")
+	require.EqualError(t, validateDefaultChannel(channels, "stable-v3"), `default channel "stable-v3" is not one of the generated channels`)
+}
+
+func TestValidateStrictPromotion(t *testing.T) {
+	versions := bundleVersions{
+		candidateChannelArchetype: {
+			"a.v1.0.0": semver.MustParse("1.0.0"),
+			"a.v1.1.0": semver.MustParse("1.1.0"),
+			"a.v1.2.0": semver.MustParse("1.2.0"),
+		},
+		fastChannelArchetype: {
+			"a.v1.0.0": semver.MustParse("1.0.0"),
+		},
+		stableChannelArchetype: {
+			"a.v1.0.0": semver.MustParse("1.0.0"),
+		},
+	}
+	require.NoError(t, validateStrictPromotion(&versions))
+
+	versions[stableChannelArchetype]["a.v1.1.0"] = semver.MustParse("1.1.0")
+	require.EqualError(t, validateStrictPromotion(&versions), `bundles skipped promotion: stable version "1.1.0" was not released to [fast]`)
+}
+
+func TestValidateBundleProperties(t *testing.T) {
+	cfg := &declcfg.DeclarativeConfig{Bundles: []declcfg.Bundle{{
+		Name:  "a.v1.0.0",
+		Image: "test.registry/a:v1.0.0",
+		Properties: []property.Property{
+			property.MustBuildPackage("a", "1.0.0"),
+			{Type: "olm.csv.metadata", Value: json.RawMessage(`{"displayName":"A"}`)},
+			{Type: "example.com/custom", Value: json.RawMessage(`{"tier":"gold"}`)},
+		},
+	}}}
+	rendered := bundlePropertiesByImage(cfg)
+
+	// a renamed package keeps the bundle's properties intact
+	cfg.Bundles[0].Properties[0] = property.MustBuildPackage("b", "1.0.0")
+	require.NoError(t, validateBundleProperties(cfg, rendered))
+
+	cfg.Bundles[0].Properties = append(cfg.Bundles[0].Properties[:1], property.Property{Type: "example.com/custom", Value: json.RawMessage(`{"tier":"silver"}`)})
+	require.EqualError(t, validateBundleProperties(cfg, rendered), `modified bundle properties: [bundle "a.v1.0.0" gained a "example.com/custom" property, bundle "a.v1.0.0" lost a "example.com/custom" property, bundle "a.v1.0.0" lost a "olm.csv.metadata" property]`)
+}
@@ -0,0 +1,86 @@
+package semver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/blang/semver/v4"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/property"
+)
+
+// VariantSpec describes one output of RenderVariants by how far back the upgrade history of each channel archetype
+// is kept.  The zero value keeps the full history.
+type VariantSpec struct {
+	// Name identifies the variant to the caller
+	Name string
+	// MaxDepth, when positive, keeps only the MaxDepth highest versions of each channel archetype
+	MaxDepth int
+	// Floor, when set, drops the bundles whose version is lower than it
+	Floor *semver.Version
+}
+
+// RenderVariants renders the template's bundles once and generates an independent catalog for each variant, in the
+// order given, each with its own channels and default channel.  Like Render, it consumes Data.
+func (t Template) RenderVariants(ctx context.Context, variants []VariantSpec) ([]*declcfg.DeclarativeConfig, error) {
+	sv, rendered, err := t.renderBundles(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	outs := make([]*declcfg.DeclarativeConfig, 0, len(variants))
+	for i := range variants {
+		// each variant is generated from pristine copies, since generation mutates both the template and the bundles
+		svCopy := *sv
+		out, _, err := t.generate(ctx, &svCopy, cloneConfig(rendered), &variants[i])
+		if err != nil {
+			return nil, fmt.Errorf("variant %q: %w", variants[i].Name, err)
+		}
+		outs = append(outs, out)
+	}
+	return outs, nil
+}
+
+// prune drops the bundles outside the variant from every channel archetype
+func (v VariantSpec) prune(versions *bundleVersions) {
+	for _, bundles := range *versions {
+		if v.Floor != nil {
+			for name, version := range bundles {
+				if version.LT(*v.Floor) {
+					delete(bundles, name)
+				}
+			}
+		}
+		if v.MaxDepth > 0 && len(bundles) > v.MaxDepth {
+			names := make([]string, 0, len(bundles))
+			for name := range bundles {
+				names = append(names, name)
+			}
+			sort.Slice(names, func(i, j int) bool {
+				if c := bundles[names[i]].Compare(bundles[names[j]]); c != 0 {
+					return c > 0
+				}
+				return names[i] < names[j]
+			})
+			for _, name := range names[v.MaxDepth:] {
+				delete(bundles, name)
+			}
+		}
+	}
+}
+
+// cloneConfig copies the parts of a rendered config which generation may modify
+func cloneConfig(in *declcfg.DeclarativeConfig) *declcfg.DeclarativeConfig {
+	out := &declcfg.DeclarativeConfig{
+		Packages: append([]declcfg.Package{}, in.Packages...),
+		Channels: append([]declcfg.Channel{}, in.Channels...),
+		Bundles:  append([]declcfg.Bundle{}, in.Bundles...),
+		Others:   append([]declcfg.Meta{}, in.Others...),
+	}
+	for i := range out.Bundles {
+		out.Bundles[i].Properties = append([]property.Property{}, in.Bundles[i].Properties...)
+	}
+	return out
+}
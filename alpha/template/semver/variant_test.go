@@ -0,0 +1,102 @@
+package semver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/pkg/image"
+)
+
+// pullCountingRegistry counts the pulls made through a registry
+type pullCountingRegistry struct {
+	image.Registry
+	pulls int32
+}
+
+func (r *pullCountingRegistry) Pull(ctx context.Context, ref image.Reference) error {
+	atomic.AddInt32(&r.pulls, 1)
+	return r.Registry.Pull(ctx, ref)
+}
+
+func TestRenderVariants(t *testing.T) {
+	bundles := testBundles("a", "0.1.0", "0.1.1", "0.2.0", "0.2.1")
+	data := "---\nschema: olm.semver\nstable:\n  bundles:\n"
+	for _, b := range bundles {
+		data += fmt.Sprintf("  - image: %s\n", b.image)
+	}
+	reg := &pullCountingRegistry{Registry: newTestRegistry(bundles...)}
+	floor := semver.MustParse("0.1.1")
+
+	outs, err := Template{Data: strings.NewReader(data), Registry: reg}.RenderVariants(context.Background(), []VariantSpec{
+		{Name: "full"},
+		{Name: "recent", MaxDepth: 2},
+		{Name: "floor", Floor: &floor},
+	})
+	require.NoError(t, err)
+	require.Len(t, outs, 3)
+	require.Equal(t, int32(len(bundles)), atomic.LoadInt32(&reg.pulls))
+
+	bundleNames := func(cfg *declcfg.DeclarativeConfig) []string {
+		var names []string
+		for _, b := range cfg.Bundles {
+			names = append(names, b.Name)
+		}
+		return names
+	}
+	entries := func(cfg *declcfg.DeclarativeConfig) map[string][]declcfg.ChannelEntry {
+		m := map[string][]declcfg.ChannelEntry{}
+		for _, ch := range cfg.Channels {
+			m[ch.Name] = ch.Entries
+		}
+		return m
+	}
+
+	full := outs[0]
+	require.ElementsMatch(t, []string{"a.v0.1.0", "a.v0.1.1", "a.v0.2.0", "a.v0.2.1"}, bundleNames(full))
+	require.Equal(t, map[string][]declcfg.ChannelEntry{
+		"stable-v0.1": {
+			{Name: "a.v0.1.0"},
+			{Name: "a.v0.1.1", Skips: []string{"a.v0.1.0"}},
+		},
+		"stable-v0.2": {
+			{Name: "a.v0.2.0"},
+			{Name: "a.v0.2.1", Replaces: "a.v0.1.1", Skips: []string{"a.v0.1.0", "a.v0.2.0"}},
+		},
+	}, entries(full))
+	require.Equal(t, "stable-v0.2", full.Packages[0].DefaultChannel)
+
+	recent := outs[1]
+	require.ElementsMatch(t, []string{"a.v0.2.0", "a.v0.2.1"}, bundleNames(recent))
+	require.Equal(t, map[string][]declcfg.ChannelEntry{
+		"stable-v0.2": {
+			{Name: "a.v0.2.0"},
+			{Name: "a.v0.2.1", Skips: []string{"a.v0.2.0"}},
+		},
+	}, entries(recent))
+	require.Equal(t, "stable-v0.2", recent.Packages[0].DefaultChannel)
+
+	floored := outs[2]
+	require.ElementsMatch(t, []string{"a.v0.1.1", "a.v0.2.0", "a.v0.2.1"}, bundleNames(floored))
+	require.Equal(t, map[string][]declcfg.ChannelEntry{
+		"stable-v0.1": {
+			{Name: "a.v0.1.1", Skips: []string{}},
+		},
+		"stable-v0.2": {
+			{Name: "a.v0.2.0"},
+			{Name: "a.v0.2.1", Replaces: "a.v0.1.1", Skips: []string{"a.v0.2.0"}},
+		},
+	}, entries(floored))
+
+	t.Run("variant pruning every bundle", func(t *testing.T) {
+		floor := semver.MustParse("1.0.0")
+		_, err := Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}.RenderVariants(context.Background(), []VariantSpec{{Name: "empty", Floor: &floor}})
+		require.EqualError(t, err, `variant "empty": render: no bundles remain after pruning`)
+	})
+}
@@ -0,0 +1,58 @@
+package semver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+// Output formats supported by WriteConfig
+const (
+	// FormatJSON writes indented JSON objects, as opm does
+	FormatJSON = "json"
+	// FormatJSONLines writes one compact JSON object per line
+	FormatJSONLines = "jsonl"
+	// FormatYAML writes a stream of YAML documents, as opm does
+	FormatYAML = "yaml"
+)
+
+// WriteConfig serializes a rendered catalog to w in the given format.  Objects are written in the same canonical order
+// as opm writes file-based catalogs: each package, followed by its channels and bundles, then any other objects.
+func WriteConfig(cfg *declcfg.DeclarativeConfig, w io.Writer, format string) error {
+	switch format {
+	case FormatJSON:
+		return declcfg.WriteJSON(*cfg, w)
+	case FormatYAML:
+		return declcfg.WriteYAML(*cfg, w)
+	case FormatJSONLines:
+		return writeJSONLines(cfg, w)
+	}
+	return fmt.Errorf("invalid output format %q, expected %q, %q, or %q", format, FormatJSON, FormatJSONLines, FormatYAML)
+}
+
+// writeJSONLines writes the catalog's objects in opm's order, compacting each onto its own line
+func writeJSONLines(cfg *declcfg.DeclarativeConfig, w io.Writer) error {
+	var buf bytes.Buffer
+	if err := declcfg.WriteJSON(*cfg, &buf); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var obj json.RawMessage
+		if err := dec.Decode(&obj); err != nil {
+			return err
+		}
+		var line bytes.Buffer
+		if err := json.Compact(&line, obj); err != nil {
+			return err
+		}
+		line.WriteByte('\n')
+		if _, err := w.Write(line.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
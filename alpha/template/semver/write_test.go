@@ -0,0 +1,66 @@
+package semver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+// marshalBundlesByName serializes bundles in name order
+func marshalBundlesByName(t *testing.T, bundles []declcfg.Bundle) []byte {
+	sorted := append([]declcfg.Bundle{}, bundles...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	data, err := json.Marshal(sorted)
+	require.NoError(t, err)
+	return data
+}
+
+func TestWriteConfig(t *testing.T) {
+	bundles := testBundles("a", "0.1.0", "0.1.1", "0.2.0")
+	data := "schema: olm.semver\ngenerateMajorChannels: true\ndeprecations:\n- bundle: a.v0.1.0\n  message: upgrade to 0.1.1\nstable:\n  bundles:\n"
+	for _, b := range bundles {
+		data += fmt.Sprintf("  - image: %s\n", b.image)
+	}
+	rendered, err := Template{Data: strings.NewReader(data), Registry: newTestRegistry(bundles...)}.Render(context.Background())
+	require.NoError(t, err)
+
+	for _, format := range []string{FormatJSON, FormatJSONLines, FormatYAML} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, WriteConfig(rendered, &buf, format))
+			written := buf.String()
+
+			out, err := declcfg.LoadReader(&buf)
+			require.NoError(t, err)
+			require.Equal(t, rendered.Packages, out.Packages)
+			require.ElementsMatch(t, rendered.Channels, out.Channels)
+			// property values are reindented when written, so bundles are compared by their serialized form
+			require.JSONEq(t, string(marshalBundlesByName(t, rendered.Bundles)), string(marshalBundlesByName(t, out.Bundles)))
+			require.Len(t, out.Others, 1)
+			require.JSONEq(t, string(rendered.Others[0].Blob), string(out.Others[0].Blob))
+
+			// the serialization is canonical: writing the loaded catalog reproduces it exactly
+			buf.Reset()
+			require.NoError(t, WriteConfig(out, &buf, format))
+			require.Equal(t, written, buf.String())
+		})
+	}
+
+	t.Run("jsonl", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteConfig(rendered, &buf, FormatJSONLines))
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		require.Len(t, lines, 1+len(rendered.Channels)+len(rendered.Bundles)+1)
+		require.True(t, strings.HasPrefix(lines[0], `{"schema":"olm.package","name":"a"`), lines[0])
+	})
+
+	require.EqualError(t, WriteConfig(rendered, &bytes.Buffer{}, "toml"), `invalid output format "toml", expected "json", "jsonl", or "yaml"`)
+}
@@ -1,22 +1,33 @@
 package template
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
 
+	blangsemver "github.com/blang/semver/v4"
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
 
+	"github.com/operator-framework/operator-registry/alpha/action"
 	"github.com/operator-framework/operator-registry/alpha/declcfg"
 	"github.com/operator-framework/operator-registry/alpha/template/semver"
 	"github.com/operator-framework/operator-registry/cmd/opm/internal/util"
+	"github.com/operator-framework/operator-registry/pkg/image"
 	"github.com/spf13/cobra"
 )
 
 func newSemverTemplateCmd() *cobra.Command {
 	output := ""
+	versionFilter := ""
+	skipsWarningThreshold := 0
+	diffRef := ""
+	diffOutput := ""
+	bundleCacheDir := ""
 	cmd := &cobra.Command{
 		Use: "semver [FILE]",
 		Short: `Generate a file-based catalog from a single 'semver template' file
@@ -49,6 +60,19 @@ When FILE is '-' or not provided, the template is read from standard input`,
 			default:
 				return fmt.Errorf("invalid output format %q", output)
 			}
+			switch diffOutput {
+			case "text", "json":
+			default:
+				return fmt.Errorf("invalid diff output format %q", diffOutput)
+			}
+
+			var filter blangsemver.Range
+			if versionFilter != "" {
+				filter, err = blangsemver.ParseRange(versionFilter)
+				if err != nil {
+					return fmt.Errorf("invalid version filter %q: %v", versionFilter, err)
+				}
+			}
 
 			// The bundle loading impl is somewhat verbose, even on the happy path,
 			// so discard all logrus default logger logs. Any important failures will be
@@ -61,15 +85,32 @@ When FILE is '-' or not provided, the template is read from standard input`,
 			}
 			defer reg.Destroy()
 
+			// warnings about the generated catalog are still shown
+			warnLogger := logrus.New()
+			warnLogger.SetOutput(os.Stderr)
+
 			template := semver.Template{
-				Data:     data,
-				Registry: reg,
+				Data:                  data,
+				Registry:              reg,
+				VersionFilter:         filter,
+				SkipsWarningThreshold: skipsWarningThreshold,
+				Log:                   logrus.NewEntry(warnLogger),
+			}
+			if bundleCacheDir != "" {
+				template.BundleCache = semver.FSBundleCache{Dir: bundleCacheDir}
 			}
 			out, err := template.Render(cmd.Context())
 			if err != nil {
 				log.Fatalf("semver %q: %v", source, err)
 			}
 
+			if diffRef != "" {
+				if err := writeCatalogDiff(cmd.Context(), reg, diffRef, out, diffOutput); err != nil {
+					log.Fatalf("diff %q: %v", diffRef, err)
+				}
+				return nil
+			}
+
 			if out != nil {
 				if err := write(*out, os.Stdout); err != nil {
 					log.Fatal(err)
@@ -81,5 +122,55 @@ When FILE is '-' or not provided, the template is read from standard input`,
 	}
 
 	cmd.Flags().StringVarP(&output, "output", "o", "json", "Output format (json|yaml|mermaid)")
+	cmd.Flags().StringVar(&versionFilter, "version-filter", "", "Only render bundles whose version satisfies this semver range (e.g. '>=1.0.0 <2.0.0')")
+	cmd.Flags().StringVar(&diffRef, "diff", "", "Instead of the rendered catalog, write how it differs from this existing catalog (an image, directory, or file) for the rendered packages")
+	cmd.Flags().StringVar(&diffOutput, "diff-output", "text", "Diff output format (text|json)")
+	cmd.Flags().StringVar(&bundleCacheDir, "bundle-cache-dir", "", "Cache rendered bundle images in this directory, by digest, to reuse them across renders")
+	cmd.Flags().IntVar(&skipsWarningThreshold, "skips-warning-threshold", 0, fmt.Sprintf("Warn about channel entries with more skips than this (default %d, negative to disable)", semver.DefaultSkipsWarningThreshold))
 	return cmd
 }
+
+// writeCatalogDiff renders the existing catalog ref and writes how the rendered catalog differs from its packages of the
+// same names, so that the catalog's other packages are not reported as removed
+func writeCatalogDiff(ctx context.Context, reg image.Registry, ref string, rendered *declcfg.DeclarativeConfig, format string) error {
+	existing, err := action.Render{
+		Refs:           []string{ref},
+		Registry:       reg,
+		AllowedRefMask: action.RefDCImage | action.RefDCDir | action.RefSqliteImage | action.RefSqliteFile,
+	}.Run(ctx)
+	if err != nil {
+		return err
+	}
+
+	packages := sets.NewString()
+	for _, p := range rendered.Packages {
+		packages.Insert(p.Name)
+	}
+	scoped := &declcfg.DeclarativeConfig{}
+	for _, p := range existing.Packages {
+		if packages.Has(p.Name) {
+			scoped.Packages = append(scoped.Packages, p)
+		}
+	}
+	for _, c := range existing.Channels {
+		if packages.Has(c.Package) {
+			scoped.Channels = append(scoped.Channels, c)
+		}
+	}
+	for _, b := range existing.Bundles {
+		if packages.Has(b.Package) {
+			scoped.Bundles = append(scoped.Bundles, b)
+		}
+	}
+
+	diff, err := semver.DiffConfigs(scoped, rendered)
+	if err != nil {
+		return err
+	}
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "    ")
+		return enc.Encode(diff)
+	}
+	return diff.WriteText(os.Stdout)
+}
@@ -0,0 +1,110 @@
+package containerdregistry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/containerd/containerd/images"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ReadOCILayout validates the OCI image layout directory at dir, which must hold a single image, and returns the image's
+// manifest.  Every blob the manifest references must be present in the layout.
+func ReadOCILayout(dir string) (*ocispec.Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ocispec.ImageLayoutFile))
+	if err != nil {
+		return nil, err
+	}
+	var layout ocispec.ImageLayout
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", ocispec.ImageLayoutFile, err)
+	}
+	if layout.Version != ocispec.ImageLayoutVersion {
+		return nil, fmt.Errorf("unsupported image layout version %q", layout.Version)
+	}
+
+	data, err = os.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		return nil, err
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("invalid index.json: %v", err)
+	}
+	if len(index.Manifests) != 1 {
+		return nil, fmt.Errorf("index.json lists %d manifests, expected exactly 1", len(index.Manifests))
+	}
+	desc := index.Manifests[0]
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageManifest, images.MediaTypeDockerSchema2Manifest:
+	default:
+		return nil, fmt.Errorf("unsupported manifest media type %q", desc.MediaType)
+	}
+
+	data, err = readLayoutBlob(dir, desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	if desc.Digest.Algorithm().FromBytes(data) != desc.Digest {
+		return nil, fmt.Errorf("manifest %s does not match its digest", desc.Digest)
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %v", desc.Digest, err)
+	}
+	for _, blob := range append([]ocispec.Descriptor{manifest.Config}, manifest.Layers...) {
+		if err := blob.Digest.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid blob digest %q: %v", blob.Digest, err)
+		}
+		if _, err := os.Stat(layoutBlobPath(dir, blob.Digest)); err != nil {
+			return nil, fmt.Errorf("missing blob %s: %v", blob.Digest, err)
+		}
+	}
+	return &manifest, nil
+}
+
+// UnpackOCILayout writes the unpackaged content of the single image of the OCI image layout directory at layoutDir to
+// dir, as Unpack does for an image pulled from a registry.
+func UnpackOCILayout(ctx context.Context, layoutDir string, dir string) error {
+	manifest, err := ReadOCILayout(layoutDir)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	for _, layer := range manifest.Layers {
+		if err := unpackLayoutLayer(ctx, layoutDir, layer, dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func unpackLayoutLayer(ctx context.Context, layoutDir string, layer ocispec.Descriptor, dir string) error {
+	f, err := os.Open(layoutBlobPath(layoutDir, layer.Digest))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return applyLayer(ctx, f, dir)
+}
+
+func layoutBlobPath(dir string, dgst digest.Digest) string {
+	return filepath.Join(dir, "blobs", dgst.Algorithm().String(), dgst.Encoded())
+}
+
+func readLayoutBlob(dir string, dgst digest.Digest) ([]byte, error) {
+	if err := dgst.Validate(); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(layoutBlobPath(dir, dgst))
+}
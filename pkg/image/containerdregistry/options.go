@@ -24,6 +24,7 @@ type RegistryConfig struct {
 	SkipTLSVerify     bool
 	PlainHTTP         bool
 	Roots             *x509.CertPool
+	AuthFiles         []string
 }
 
 func (r *RegistryConfig) apply(options []RegistryOption) {
@@ -91,16 +92,27 @@ func NewRegistry(options ...RegistryOption) (registry *Registry, err error) {
 	}
 
 	var resolver remotes.Resolver
-	resolver, err = NewResolver(config.ResolverConfigDir, config.SkipTLSVerify, config.PlainHTTP, config.Roots)
-	if err != nil {
-		return
+	var authSource func(host string) string
+	if len(config.AuthFiles) != 0 {
+		var auth *authFiles
+		if auth, err = loadAuthFiles(config.AuthFiles); err != nil {
+			return
+		}
+		resolver = newResolver(auth.credential, config.SkipTLSVerify, config.PlainHTTP, config.Roots)
+		authSource = auth.source
+	} else {
+		resolver, err = NewResolver(config.ResolverConfigDir, config.SkipTLSVerify, config.PlainHTTP, config.Roots)
+		if err != nil {
+			return
+		}
 	}
 
 	registry = &Registry{
-		Store:    newStore(metadata.NewDB(bdb, cs, nil)),
-		destroy:  destroy,
-		log:      config.Log,
-		resolver: resolver,
+		Store:      newStore(metadata.NewDB(bdb, cs, nil)),
+		destroy:    destroy,
+		log:        config.Log,
+		resolver:   resolver,
+		authSource: authSource,
 		platform: platforms.Ordered(platforms.DefaultSpec(), specs.Platform{
 			OS:           "linux",
 			Architecture: "amd64",
@@ -152,3 +164,11 @@ func WithPlainHTTP(insecure bool) RegistryOption {
 		config.PlainHTTP = insecure
 	}
 }
+
+// WithAuthFiles merges registry credentials from the given docker config files, in place of the resolver config
+// directory.  When several files hold credentials for the same registry, the last one wins.
+func WithAuthFiles(paths ...string) RegistryOption {
+	return func(config *RegistryConfig) {
+		config.AuthFiles = paths
+	}
+}
@@ -19,6 +19,8 @@ import (
 	"github.com/containerd/containerd/namespaces"
 	"github.com/containerd/containerd/platforms"
 	"github.com/containerd/containerd/remotes"
+	"github.com/docker/distribution/reference"
+	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -34,9 +36,14 @@ type Registry struct {
 	log      *logrus.Entry
 	resolver remotes.Resolver
 	platform platforms.MatchComparer
+
+	// authSource, when set, returns the auth file which provided the credentials for a registry host
+	authSource func(host string) string
 }
 
 var _ image.Registry = &Registry{}
+var _ image.DigestResolver = &Registry{}
+var _ image.RemoteDigestResolver = &Registry{}
 
 var nonRetriablePullError = regexp.MustCompile("specified image is a docker schema v1 manifest, which is not supported")
 
@@ -47,7 +54,7 @@ func (r *Registry) Pull(ctx context.Context, ref image.Reference) error {
 
 	name, root, err := r.resolver.Resolve(ctx, ref.String())
 	if err != nil {
-		return fmt.Errorf("error resolving name for image ref %s: %v", ref.String(), err)
+		return fmt.Errorf("error resolving name for image ref %s%s: %v", ref.String(), r.credentialsFrom(ref), err)
 	}
 	r.log.Debugf("resolved name: %s", name)
 
@@ -89,6 +96,21 @@ func (r *Registry) Pull(ctx context.Context, ref image.Reference) error {
 	return err
 }
 
+// credentialsFrom describes the auth file which provided the credentials used to pull ref, if known
+func (r *Registry) credentialsFrom(ref image.Reference) string {
+	if r.authSource == nil {
+		return ""
+	}
+	named, err := reference.ParseNormalizedNamed(ref.String())
+	if err != nil {
+		return ""
+	}
+	if path := r.authSource(reference.Domain(named)); path != "" {
+		return fmt.Sprintf(" (using credentials from %s)", path)
+	}
+	return ""
+}
+
 // Unpack writes the unpackaged content of an image to a directory.
 // If the referenced image does not exist in the registry, an error is returned.
 func (r *Registry) Unpack(ctx context.Context, ref image.Reference, dir string) error {
@@ -131,6 +153,31 @@ func (r *Registry) Labels(ctx context.Context, ref image.Reference) (map[string]
 	return imageConfig.Config.Labels, nil
 }
 
+// Digest returns the digest of the manifest (or index) of an image which is already stored.
+func (r *Registry) Digest(ctx context.Context, ref image.Reference) (digest.Digest, error) {
+	// Set the default namespace if unset
+	ctx = ensureNamespace(ctx)
+
+	img, err := r.Images().Get(ctx, ref.String())
+	if err != nil {
+		return "", err
+	}
+	return img.Target.Digest, nil
+}
+
+// ResolveDigest returns the digest of the manifest (or index) of an image in its remote registry.  Only the manifest's
+// descriptor is requested; the image is neither fetched nor stored.
+func (r *Registry) ResolveDigest(ctx context.Context, ref image.Reference) (digest.Digest, error) {
+	// Set the default namespace if unset
+	ctx = ensureNamespace(ctx)
+
+	_, desc, err := r.resolver.Resolve(ctx, ref.String())
+	if err != nil {
+		return "", fmt.Errorf("error resolving name for image ref %s%s: %v", ref.String(), r.credentialsFrom(ref), err)
+	}
+	return desc.Digest, nil
+}
+
 // Destroy cleans up the on-disk boltdb file and other cache files, unless preserve cache is true
 func (r *Registry) Destroy() (err error) {
 	return r.destroy()
@@ -202,7 +249,12 @@ func (r *Registry) unpackLayer(ctx context.Context, layer ocispec.Descriptor, di
 	defer ra.Close()
 
 	// TODO(njhale): Chunk layer reading
-	decompressed, err := compression.DecompressStream(io.NewSectionReader(ra, 0, ra.Size()))
+	return applyLayer(ctx, io.NewSectionReader(ra, 0, ra.Size()), dir)
+}
+
+// applyLayer decompresses a layer and writes its content to dir, as the current user
+func applyLayer(ctx context.Context, layer io.Reader, dir string) error {
+	decompressed, err := compression.DecompressStream(layer)
 	if err != nil {
 		return err
 	}
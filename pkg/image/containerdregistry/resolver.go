@@ -3,6 +3,7 @@ package containerdregistry
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"fmt"
 	"net"
 	"net/http"
 	"os"
@@ -16,10 +17,19 @@ import (
 	"github.com/docker/cli/cli/config"
 	"github.com/docker/cli/cli/config/configfile"
 	"github.com/docker/cli/cli/config/credentials"
+	"github.com/docker/cli/cli/config/types"
 	"github.com/docker/docker/registry"
 )
 
 func NewResolver(configDir string, skipTlSVerify, plainHTTP bool, roots *x509.CertPool) (remotes.Resolver, error) {
+	cfg, err := loadConfig(configDir)
+	if err != nil {
+		return nil, err
+	}
+	return newResolver(credential(cfg), skipTlSVerify, plainHTTP, roots), nil
+}
+
+func newResolver(creds func(string) (string, string, error), skipTlSVerify, plainHTTP bool, roots *x509.CertPool) remotes.Resolver {
 	transport := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
@@ -46,16 +56,11 @@ func NewResolver(configDir string, skipTlSVerify, plainHTTP bool, roots *x509.Ce
 
 	client := &http.Client{Transport: transport}
 
-	cfg, err := loadConfig(configDir)
-	if err != nil {
-		return nil, err
-	}
-
 	regopts := []docker.RegistryOpt{
 		docker.WithAuthorizer(docker.NewDockerAuthorizer(
 			docker.WithAuthClient(client),
 			docker.WithAuthHeader(headers),
-			docker.WithAuthCreds(credential(cfg)),
+			docker.WithAuthCreds(creds),
 		)),
 		docker.WithClient(client),
 	}
@@ -68,7 +73,7 @@ func NewResolver(configDir string, skipTlSVerify, plainHTTP bool, roots *x509.Ce
 		Headers: headers,
 	}
 
-	return docker.NewResolver(opts), nil
+	return docker.NewResolver(opts)
 }
 
 func credential(cfg *configfile.ConfigFile) func(string) (string, string, error) {
@@ -89,6 +94,66 @@ func credential(cfg *configfile.ConfigFile) func(string) (string, string, error)
 	}
 }
 
+// authFiles holds the docker config files credentials are merged from, in increasing order of precedence
+type authFiles struct {
+	paths   []string
+	configs []*configfile.ConfigFile
+}
+
+func loadAuthFiles(paths []string) (*authFiles, error) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	files := &authFiles{}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("load auth file %q: %v", path, err)
+		}
+		cfg, err := config.LoadFromReader(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("load auth file %q: %v", path, err)
+		}
+		cfg.Filename = path
+		files.paths = append(files.paths, path)
+		files.configs = append(files.configs, cfg)
+	}
+	return files, nil
+}
+
+// lookup returns the credentials for a host from the last file which holds any, and the path of that file
+func (a *authFiles) lookup(hostname string) (types.AuthConfig, string, error) {
+	hostname = resolveHostname(hostname)
+	for i := len(a.configs) - 1; i >= 0; i-- {
+		auth, err := a.configs[i].GetAuthConfig(hostname)
+		if err != nil {
+			return types.AuthConfig{}, a.paths[i], fmt.Errorf("auth file %q: %v", a.paths[i], err)
+		}
+		if auth.IdentityToken != "" || auth.Username != "" || auth.Password != "" {
+			return auth, a.paths[i], nil
+		}
+	}
+	return types.AuthConfig{}, "", nil
+}
+
+func (a *authFiles) credential(hostname string) (string, string, error) {
+	auth, _, err := a.lookup(hostname)
+	if err != nil {
+		return "", "", err
+	}
+	if auth.IdentityToken != "" {
+		return "", auth.IdentityToken, nil
+	}
+	return auth.Username, auth.Password, nil
+}
+
+// source returns the path of the file providing the credentials for a host, if any
+func (a *authFiles) source(hostname string) string {
+	_, path, _ := a.lookup(hostname)
+	return path
+}
+
 // protects against a data race inside the docker CLI
 // TODO: upstream issue for 20.10.x is tracked here https://github.com/docker/cli/pull/3410
 // newer versions already contain the fix
@@ -0,0 +1,69 @@
+package containerdregistry
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeAuthFile(t *testing.T, name string, auths map[string]string) string {
+	t.Helper()
+	data := `{"auths":{`
+	first := true
+	for host, userpass := range auths {
+		if !first {
+			data += ","
+		}
+		first = false
+		data += fmt.Sprintf("%q:{%q:%q}", host, "auth", base64.StdEncoding.EncodeToString([]byte(userpass)))
+	}
+	data += "}}"
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(data), 0600))
+	return path
+}
+
+func TestAuthFilesPrecedence(t *testing.T) {
+	base := writeAuthFile(t, "base.json", map[string]string{
+		"quay.io":         "base:basepass",
+		"registry.io:443": "other:otherpass",
+	})
+	ci := writeAuthFile(t, "ci.json", map[string]string{
+		"quay.io": "ci:cipass",
+	})
+
+	auth, err := loadAuthFiles([]string{base, ci})
+	require.NoError(t, err)
+
+	// later files override earlier ones
+	user, pass, err := auth.credential("quay.io")
+	require.NoError(t, err)
+	require.Equal(t, "ci", user)
+	require.Equal(t, "cipass", pass)
+	require.Equal(t, ci, auth.source("quay.io"))
+
+	// hosts only present in earlier files are still resolved
+	user, pass, err = auth.credential("registry.io:443")
+	require.NoError(t, err)
+	require.Equal(t, "other", user)
+	require.Equal(t, "otherpass", pass)
+	require.Equal(t, base, auth.source("registry.io:443"))
+
+	// unknown hosts are anonymous
+	user, pass, err = auth.credential("example.com")
+	require.NoError(t, err)
+	require.Empty(t, user)
+	require.Empty(t, pass)
+	require.Empty(t, auth.source("example.com"))
+
+	t.Run("invalid file", func(t *testing.T) {
+		invalid := filepath.Join(t.TempDir(), "invalid.json")
+		require.NoError(t, os.WriteFile(invalid, []byte("{"), 0600))
+		_, err := loadAuthFiles([]string{base, invalid})
+		require.ErrorContains(t, err, fmt.Sprintf("load auth file %q", invalid))
+	})
+}
@@ -8,9 +8,13 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+
+	"github.com/opencontainers/go-digest"
 )
 
 var _ Registry = &MockRegistry{}
+var _ DigestResolver = &MockRegistry{}
+var _ RemoteDigestResolver = &MockRegistry{}
 
 type MockRegistry struct {
 	RemoteImages map[Reference]*MockImage
@@ -21,6 +25,7 @@ type MockRegistry struct {
 type MockImage struct {
 	Labels map[string]string
 	FS     fs.FS
+	Digest digest.Digest
 }
 
 func (i *MockImage) unpack(dir string) error {
@@ -78,6 +83,30 @@ func (m *MockRegistry) Labels(_ context.Context, ref Reference) (map[string]stri
 	return image.Labels, nil
 }
 
+func (m *MockRegistry) Digest(_ context.Context, ref Reference) (digest.Digest, error) {
+	m.m.RLock()
+	defer m.m.RUnlock()
+	image, ok := m.localImages[ref]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	if image.Digest == "" {
+		return "", errors.New("no digest")
+	}
+	return image.Digest, nil
+}
+
+func (m *MockRegistry) ResolveDigest(_ context.Context, ref Reference) (digest.Digest, error) {
+	image, ok := m.RemoteImages[ref]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	if image.Digest == "" {
+		return "", errors.New("no digest")
+	}
+	return image.Digest, nil
+}
+
 func (m *MockRegistry) Destroy() error {
 	m.m.Lock()
 	defer m.m.Unlock()
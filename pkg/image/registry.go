@@ -2,6 +2,8 @@ package image
 
 import (
 	"context"
+
+	"github.com/opencontainers/go-digest"
 )
 
 // Registry knows how to Pull and Unpack Operator Bundle images to the filesystem.
@@ -29,3 +31,16 @@ type Registry interface {
 	// If it exists, it's used as the base image.
 	// Pack(ctx context.Context, ref Reference, from io.Reader) (next string, err error)
 }
+
+// DigestResolver is implemented by registries which can report the content digest of the images they store.
+type DigestResolver interface {
+	// Digest returns the digest of the manifest of an image which is already stored.
+	Digest(ctx context.Context, ref Reference) (digest.Digest, error)
+}
+
+// RemoteDigestResolver is implemented by registries which can resolve the content digest of an image from its remote
+// registry, without fetching the image.
+type RemoteDigestResolver interface {
+	// ResolveDigest returns the digest of the manifest of an image in its remote registry.
+	ResolveDigest(ctx context.Context, ref Reference) (digest.Digest, error)
+}